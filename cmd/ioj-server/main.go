@@ -2,6 +2,9 @@ package main
 
 import (
 	"flag"
+
+	// register built-in handlers
+	_ "github.com/infinity-oj/server-v2/internal/lib/handlers"
 )
 
 var configFile = flag.String("f", "configs/server.yaml", "set config file which viper will loading.")
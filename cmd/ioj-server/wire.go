@@ -10,8 +10,8 @@ import (
 	"github.com/infinity-oj/server-v2/internal/app/processes"
 	"github.com/infinity-oj/server-v2/internal/app/ranklists"
 	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/cache"
 	"github.com/infinity-oj/server-v2/internal/lib/dispatcher"
-	"github.com/infinity-oj/server-v2/internal/lib/handlers"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/internal/lib/scheduler"
 	"github.com/infinity-oj/server-v2/internal/pkg/websockets"
@@ -19,6 +19,7 @@ import (
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
 	"github.com/infinity-oj/server-v2/internal/app/problems"
 	"github.com/infinity-oj/server-v2/internal/app/programs"
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
 	"github.com/infinity-oj/server-v2/internal/app/server"
 	"github.com/infinity-oj/server-v2/internal/app/submissions"
 	"github.com/infinity-oj/server-v2/internal/app/volumes"
@@ -50,9 +51,10 @@ var providerSet = wire.NewSet(
 	volumes.ProviderSet,
 	processes.ProviderSet,
 	ranklists.ProviderSet,
+	webhooks.ProviderSet,
 
-	handlers.ProviderSet,
 	buildins.ProviderSet,
+	cache.ProviderSet,
 
 	scheduler.ProviderSet,
 	dispatcher.ProviderSet,
@@ -22,9 +22,10 @@ import (
 	"github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
 	"github.com/infinity-oj/server-v2/internal/app/volumes/services"
 	"github.com/infinity-oj/server-v2/internal/app/volumes/storages"
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
 	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/cache"
 	"github.com/infinity-oj/server-v2/internal/lib/dispatcher"
-	"github.com/infinity-oj/server-v2/internal/lib/handlers"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/internal/lib/scheduler"
 	"github.com/infinity-oj/server-v2/internal/pkg/configs"
@@ -69,25 +70,30 @@ func CreateApp(cf string) (*server.Application, error) {
 	}
 	repository := accounts.NewRepository(logger, db)
 	service := accounts.NewService(logger, repository)
-	controller := accounts.NewController(logger, service)
-	initAccountGroupFn := accounts.CreateInitControllersFn(controller)
 	judgementsRepository := judgements.NewRepository(logger, db)
 	blueprintsRepository := blueprints.NewRepository(logger, db)
 	problemsRepository := problems.NewRepository(logger, db)
 	submissionsRepository := submissions.NewRepository(logger, db)
 	programsRepository := programs.NewRepository(logger, db)
-	judgementsDispatcher := dispatcher.New(logger, problemsRepository, submissionsRepository, judgementsRepository, blueprintsRepository, programsRepository)
+	dispatcherOptions, err := dispatcher.NewOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	webhooksOptions, err := webhooks.NewOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	webhooksRepository := webhooks.NewRepository(logger, db)
+	webhooksService := webhooks.NewService(logger, webhooksOptions, webhooksRepository, problemsRepository)
+	judgementsDispatcher := dispatcher.New(logger, dispatcherOptions, problemsRepository, submissionsRepository, judgementsRepository, blueprintsRepository, programsRepository, webhooksService)
 	judgementsService := judgements.NewService(logger, judgementsRepository, blueprintsRepository, judgementsDispatcher)
 	judgementsController := judgements.NewController(logger, judgementsService)
 	initJudgementGroupFn := judgements.CreateInitControllersFn(judgementsController)
 	submissionsService := submissions.NewService(logger, submissionsRepository, problemsRepository, judgementsService)
 	submissionsController := submissions.NewController(logger, submissionsService)
 	initSubmissionGroupFn := submissions.CreateInitControllersFn(submissionsController)
-	problemsService := problems.NewService(logger, problemsRepository)
 	ranklistsRepository := ranklists.NewRepository(logger, db)
 	ranklistsService := ranklists.NewService(logger, ranklistsRepository)
-	problemsController := problems.NewController(logger, problemsService, ranklistsService)
-	initProblemGroupFn := problems.CreateInitControllersFn(problemsController)
 	filesOptions, err := files.NewOptions(viper, logger)
 	if err != nil {
 		return nil, err
@@ -98,34 +104,53 @@ func CreateApp(cf string) (*server.Application, error) {
 	}
 	storage := storages.NewFileManager(logger, fileManager)
 	repositoriesRepository := repositories.NewRepository(logger, db)
-	servicesService := services.NewVolumeService(logger, storage, repositoriesRepository)
+	servicesOptions, err := services.NewOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	gcOptions, err := services.NewGCOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	servicesService := services.NewVolumeService(logger, servicesOptions, gcOptions, storage, repositoriesRepository)
+	controller := accounts.NewController(logger, service, servicesService)
+	initAccountGroupFn := accounts.CreateInitControllersFn(controller)
 	controllersController := controllers.New(logger, servicesService)
 	initVolumeGroupFn := volumes.CreateInitControllersFn(controllersController)
 	programsService := programs.NewService(logger, programsRepository)
 	programsController := programs.NewController(logger, programsService)
 	initProgramGroupFn := programs.CreateInitControllersFn(programsController)
-	rankList := handlers.NewRankList(ranklistsRepository, repository)
-	result := handlers.NewResult(judgementsRepository)
-	constString := handlers.NewConstString()
-	constInt := handlers.NewConstInt()
-	file := handlers.NewFileHandler()
-	evaluate := handlers.NewEvaluateHandler()
-	volumeCreate := handlers.NewVolumeCreate(judgementsRepository, servicesService)
-	volumeRead := handlers.NewVolumeRead(judgementsRepository, servicesService)
-	volumeSave := handlers.NewVolumeSave(judgementsRepository, servicesService)
-	volumeFetch := handlers.NewVolumeFetch(judgementsRepository, repositoriesRepository, storage)
-	v := buildins.All(rankList, result, constString, constInt, file, evaluate, volumeCreate, volumeRead, volumeSave, volumeFetch)
-	processManager := manager.NewManager(logger, v)
-	processesService := processes.NewService(logger, processManager)
+	dependencies := &buildins.Dependencies{
+		Logger:               logger,
+		AccountRepository:    repository,
+		JudgementRepository:  judgementsRepository,
+		RankListRepository:   ranklistsRepository,
+		SubmissionRepository: submissionsRepository,
+		VolumeRepository:     repositoriesRepository,
+		VolumeService:        servicesService,
+		VolumeStorage:        storage,
+	}
+	handlers := buildins.All(dependencies)
+	cacheRepository := cache.NewRepository(logger, db)
+	cacheCache := cache.New(logger, cacheRepository, servicesService)
+	processManager := manager.NewManager(logger, handlers, cacheCache)
+	processesService := processes.NewService(logger, processManager, cacheCache)
 	processesController := processes.NewController(logger, processesService)
 	initProcessGroupFn := processes.CreateInitControllersFn(processesController)
-	blueprintsService := blueprints.NewService(logger, blueprintsRepository)
+	blueprintsValidator := scheduler.NewValidator(programsRepository)
+	blueprintsService := blueprints.NewService(logger, blueprintsRepository, blueprintsValidator)
+	problemsService := problems.NewService(logger, problemsRepository, servicesService)
+	packageService := problems.NewPackageService(logger, problemsRepository, servicesService, blueprintsService, ranklistsRepository)
+	problemsController := problems.NewController(logger, problemsService, ranklistsService, packageService, servicesService)
+	initProblemGroupFn := problems.CreateInitControllersFn(problemsController)
 	blueprintsController := blueprints.NewController(logger, blueprintsService)
 	initBlueprintGroupFn := blueprints.CreateInitControllersFn(blueprintsController)
 	ranklistsController := ranklists.NewController(logger, ranklistsService)
 	initRanklistGroupFn := ranklists.CreateInitControllersFn(ranklistsController)
+	webhooksController := webhooks.NewController(logger, webhooksService, problemsService)
+	initWebhookGroupFn := webhooks.CreateInitControllersFn(webhooksController)
 	initWebsocketGroupFn := websockets.CreateInitWebSocketFn()
-	initControllers := server.CreateInitControllersFn(initAccountGroupFn, initJudgementGroupFn, initSubmissionGroupFn, initProblemGroupFn, initVolumeGroupFn, initProgramGroupFn, initProcessGroupFn, initBlueprintGroupFn, initRanklistGroupFn, initWebsocketGroupFn)
+	initControllers := server.CreateInitControllersFn(initAccountGroupFn, initJudgementGroupFn, initSubmissionGroupFn, initProblemGroupFn, initVolumeGroupFn, initProgramGroupFn, initProcessGroupFn, initBlueprintGroupFn, initRanklistGroupFn, initWebhookGroupFn, initWebsocketGroupFn)
 	configuration, err := jaeger.NewConfiguration(viper, logger)
 	if err != nil {
 		return nil, err
@@ -148,4 +173,4 @@ func CreateApp(cf string) (*server.Application, error) {
 
 // wire.go:
 
-var providerSet = wire.NewSet(log.ProviderSet, configs.ProviderSet, http.ProviderSet, database.ProviderSet, jaeger.ProviderSet, files.ProviderSet, websockets.ProviderSet, server.ProviderSet, accounts.ProviderSet, problems.ProviderSet, submissions.ProviderSet, judgements.ProviderSet, programs.ProviderSet, blueprints.ProviderSet, volumes.ProviderSet, processes.ProviderSet, ranklists.ProviderSet, handlers.ProviderSet, buildins.ProviderSet, scheduler.ProviderSet, dispatcher.ProviderSet, manager.ProviderSet)
+var providerSet = wire.NewSet(log.ProviderSet, configs.ProviderSet, http.ProviderSet, database.ProviderSet, jaeger.ProviderSet, files.ProviderSet, websockets.ProviderSet, server.ProviderSet, accounts.ProviderSet, problems.ProviderSet, submissions.ProviderSet, judgements.ProviderSet, programs.ProviderSet, blueprints.ProviderSet, volumes.ProviderSet, processes.ProviderSet, ranklists.ProviderSet, webhooks.ProviderSet, buildins.ProviderSet, cache.ProviderSet, scheduler.ProviderSet, dispatcher.ProviderSet, manager.ProviderSet)
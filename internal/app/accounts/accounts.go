@@ -12,6 +12,7 @@ func CreateInitControllersFn(ac Controller) InitAccountGroupFn {
 		r.GET("/account/:name", ac.GetAccount)
 		r.PUT("/account/:name", ac.UpdateAccount)
 		r.PUT("/account/:name/credential/application", ac.UpdateAccountCredential)
+		r.GET("/account/:name/usage", ac.GetUsage)
 		r.POST("/account/application", ac.CreateAccount)
 
 		r.GET("/session/principal", ac.GetPrincipal)
@@ -5,7 +5,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	volumes "github.com/infinity-oj/server-v2/internal/app/volumes/services"
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
+	"github.com/infinity-oj/server-v2/pkg/models"
 	"go.uber.org/zap"
 )
 
@@ -14,6 +16,7 @@ type Controller interface {
 	GetAccount(c *gin.Context)
 	UpdateAccount(c *gin.Context)
 	UpdateAccountCredential(c *gin.Context)
+	GetUsage(c *gin.Context)
 
 	CreatePrincipal(c *gin.Context)
 	GetPrincipal(c *gin.Context)
@@ -23,14 +26,16 @@ type Controller interface {
 }
 
 type DefaultController struct {
-	logger  *zap.Logger
-	service Service
+	logger        *zap.Logger
+	service       Service
+	volumeService volumes.Service
 }
 
-func NewController(logger *zap.Logger, s Service) Controller {
+func NewController(logger *zap.Logger, s Service, volumeService volumes.Service) Controller {
 	return &DefaultController{
-		logger:  logger,
-		service: s,
+		logger:        logger,
+		service:       s,
+		volumeService: volumeService,
 	}
 }
 
@@ -87,6 +92,43 @@ func (d DefaultController) GetAccount(c *gin.Context) {
 	c.JSON(http.StatusOK, account)
 }
 
+// GetUsage reports the volumes and bytes the account stores against its quota, to the account
+// itself and admins.
+func (d DefaultController) GetUsage(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	name := c.Param("name")
+	account, err := d.service.GetAccount(name)
+	if err != nil {
+		d.logger.Error("get account", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if account == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if account.ID != session.AccountId && !session.HasRole(models.RoleAdmin) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	usage, err := d.volumeService.GetUsage(account.ID)
+	if err != nil {
+		d.logger.Error("get usage", zap.String("account name", name), zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
 func (d DefaultController) UpdateAccountCredential(c *gin.Context) {
 	name := c.Param("name")
 
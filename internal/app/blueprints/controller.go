@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
+	"github.com/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -76,6 +77,12 @@ func (pc *DefaultController) CreateBlueprint(c *gin.Context) {
 	}
 
 	problem, err := pc.service.CreateBlueprint(request.Definition)
+	if errors.Is(err, ErrInvalidDefinition) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{
 			"message": err.Error(),
@@ -2,9 +2,18 @@ package blueprints
 
 import (
 	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+// ErrInvalidDefinition is returned when a blueprint definition fails validation.
+var ErrInvalidDefinition = errors.New("invalid blueprint definition")
+
+// Validator checks a blueprint definition can be built into a graph.
+type Validator interface {
+	Validate(definition string) error
+}
+
 type Service interface {
 	CreateBlueprint(definition string) (p *models.Blueprint, err error)
 	GetBlueprint(id uint64) (p *models.Blueprint, err error)
@@ -14,6 +23,7 @@ type Service interface {
 type service struct {
 	logger     *zap.Logger
 	Repository Repository
+	Validator  Validator
 }
 
 func (s service) GetBlueprints() (p []*models.Blueprint, err error) {
@@ -26,6 +36,9 @@ func (s service) CreateBlueprint(definition string) (p *models.Blueprint, err er
 	s.logger.Debug("create blueprint",
 		zap.String("definition", definition),
 	)
+	if err = s.Validator.Validate(definition); err != nil {
+		return nil, err
+	}
 	if p, err = s.Repository.CreateBlueprint(definition); err != nil {
 		return p, err
 	}
@@ -39,9 +52,10 @@ func (s service) GetBlueprint(id uint64) (p *models.Blueprint, err error) {
 	return
 }
 
-func NewService(logger *zap.Logger, Repository Repository) Service {
+func NewService(logger *zap.Logger, Repository Repository, Validator Validator) Service {
 	return &service{
 		logger:     logger.With(zap.String("type", "service")),
 		Repository: Repository,
+		Validator:  Validator,
 	}
 }
@@ -53,11 +53,11 @@ func (d *DefaultController) CancelJudgement(c *gin.Context) {
 		return
 	}
 
-	if judgement.Status != models.Pending {
+	if judgement.Status != models.Pending && judgement.Status != models.Running {
 		c.AbortWithStatus(http.StatusForbidden)
 		return
 	}
-	judgement, err = d.service.UpdateJudgement(judgementId, models.Canceled, -1, "User cancel")
+	judgement, err = d.service.CancelJudgement(judgementId)
 	if err != nil {
 		d.logger.Error("cancel judgement", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
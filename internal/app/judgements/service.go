@@ -15,10 +15,13 @@ type Service interface {
 	GetJudgementPrerequisites(blueprintId uint64) (string, error)
 	CreateJudgement(accountId, blueprintId uint64, args map[string]interface{}) (int, *models.Judgement, error)
 	UpdateJudgement(judgementId string, status models.JudgeStatus, score float64, msg string) (*models.Judgement, error)
+	CancelJudgement(judgementId string) (*models.Judgement, error)
 }
 
 type Dispatcher interface {
 	PushJudgement(judgement *models.Judgement)
+	// CancelJudgement cancels a running judgement, it returns false if the judgement is not running.
+	CancelJudgement(judgementId string) bool
 }
 
 type service struct {
@@ -56,6 +59,17 @@ func (s service) UpdateJudgement(judgementId string, status models.JudgeStatus,
 	return judgement, err
 }
 
+func (s service) CancelJudgement(judgementId string) (*models.Judgement, error) {
+	s.logger.Debug("cancel judgement", zap.String("judgement id", judgementId))
+
+	judgement, err := s.UpdateJudgement(judgementId, models.Canceled, -1, "User cancel")
+	if err != nil {
+		return nil, err
+	}
+	s.dispatcher.CancelJudgement(judgementId)
+	return judgement, nil
+}
+
 func (s service) CreateJudgement(accountId, blueprintId uint64, args map[string]interface{}) (int, *models.Judgement, error) {
 	s.logger.Debug("create judgement",
 		zap.Uint64("account id", accountId),
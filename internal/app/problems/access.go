@@ -0,0 +1,166 @@
+package problems
+
+import (
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrInvalidState     = errors.New("invalid problem state")
+	ErrAccountNotFound  = errors.New("account not found")
+)
+
+// Principal is who accesses a problem. An anonymous principal has no account and no roles.
+type Principal struct {
+	AccountID uint64
+	Roles     []string
+}
+
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range p.Roles {
+		for _, r := range roles {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Access is what a principal may do with a problem, each access includes the lower ones.
+type Access int
+
+const (
+	AccessNone Access = iota
+	// AccessRead allows reading the problem, its pages and ranklists.
+	AccessRead
+	// AccessEdit allows editing the problem, its pages and the data in its volumes, and moving
+	// it between draft and review.
+	AccessEdit
+	// AccessManage allows managing the collaborators of the problem besides editing.
+	AccessManage
+)
+
+// GetAccess returns the problem of the name and decides what the principal may do with it:
+//   - admins may manage every problem, and are the only ones to publish and archive problems
+//   - the owner, who created the problem, may manage it
+//   - collaborators may edit it
+//   - everyone may read it once published, and after it is archived
+//
+// The problem is nil if there is none.
+func (s service) GetAccess(name string, principal *Principal) (*models.Problem, Access, error) {
+	problem, err := s.Repository.GetProblemByName(name)
+	if err != nil || problem == nil {
+		return nil, AccessNone, err
+	}
+	access, err := s.access(problem, principal)
+	return problem, access, err
+}
+
+func (s service) access(problem *models.Problem, principal *Principal) (Access, error) {
+	if principal.HasRole(models.RoleAdmin) {
+		return AccessManage, nil
+	}
+	if principal.AccountID != 0 {
+		if problem.OwnerID == principal.AccountID {
+			return AccessManage, nil
+		}
+		collaborator, err := s.Repository.IsCollaborator(problem.ID, principal.AccountID)
+		if err != nil {
+			return AccessNone, err
+		}
+		if collaborator {
+			return AccessEdit, nil
+		}
+	}
+	if problem.IsReadable(time.Now()) {
+		return AccessRead, nil
+	}
+	return AccessNone, nil
+}
+
+// SetState moves the problem to the state. A published problem is hidden until publishAt if it
+// is set. Editors may only move problems between draft and review, the rest is up to admins.
+func (s service) SetState(principal *Principal, problem *models.Problem, state string, publishAt *time.Time) (*models.Problem, error) {
+	switch state {
+	case models.ProblemDraft, models.ProblemReview:
+		if !principal.HasRole(models.RoleAdmin) && problem.State != models.ProblemDraft && problem.State != models.ProblemReview {
+			return nil, errors.Wrapf(ErrPermissionDenied, "%s problem", problem.State)
+		}
+		publishAt = nil
+	case models.ProblemPublished, models.ProblemArchived:
+		if !principal.HasRole(models.RoleAdmin) {
+			return nil, errors.Wrapf(ErrPermissionDenied, "%s problem", state)
+		}
+		if state == models.ProblemArchived {
+			publishAt = nil
+		}
+	default:
+		return nil, errors.Wrap(ErrInvalidState, state)
+	}
+
+	problem.State = state
+	problem.PublishAt = publishAt
+	if err := s.Repository.UpdateProblem(problem); err != nil {
+		s.logger.Error("set problem state",
+			zap.String("name", problem.Name),
+			zap.String("state", state),
+			zap.Error(err))
+		return nil, err
+	}
+	return problem, nil
+}
+
+func (s service) GetCollaborators(name string) ([]*models.Account, error) {
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repository.GetCollaborators(problem.ID)
+}
+
+func (s service) AddCollaborator(name, accountName string) error {
+	problem, account, err := s.collaborator(name, accountName)
+	if err != nil {
+		return err
+	}
+	if account.ID == problem.OwnerID {
+		// the owner may do more than a collaborator already
+		return nil
+	}
+	return s.Repository.AddCollaborator(problem.ID, account.ID)
+}
+
+func (s service) RemoveCollaborator(name, accountName string) error {
+	problem, account, err := s.collaborator(name, accountName)
+	if err != nil {
+		return err
+	}
+	removed, err := s.Repository.RemoveCollaborator(problem.ID, account.ID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errors.Wrapf(ErrAccountNotFound, "%s is not a collaborator", accountName)
+	}
+	return nil
+}
+
+func (s service) collaborator(name, accountName string) (*models.Problem, *models.Account, error) {
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	account, err := s.Repository.GetAccountByName(accountName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if account == nil {
+		return nil, nil, errors.Wrap(ErrAccountNotFound, accountName)
+	}
+	return problem, account, nil
+}
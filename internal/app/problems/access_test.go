@@ -0,0 +1,85 @@
+package problems
+
+import (
+	"testing"
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"go.uber.org/zap"
+)
+
+// accessRepository knows one collaborator and accepts updates, other methods are not used.
+type accessRepository struct {
+	Repository
+	collaborator uint64
+}
+
+func (r *accessRepository) IsCollaborator(problemId, accountId uint64) (bool, error) {
+	return accountId == r.collaborator, nil
+}
+
+func (r *accessRepository) UpdateProblem(p *models.Problem) error {
+	return nil
+}
+
+func TestAccess(t *testing.T) {
+	s := service{logger: zap.NewNop(), Repository: &accessRepository{collaborator: 3}}
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		state     string
+		publishAt *time.Time
+		principal *Principal
+		want      Access
+	}{
+		{name: "admin", state: models.ProblemDraft, principal: &Principal{AccountID: 1, Roles: []string{models.RoleAdmin}}, want: AccessManage},
+		{name: "owner", state: models.ProblemDraft, principal: &Principal{AccountID: 2}, want: AccessManage},
+		{name: "collaborator", state: models.ProblemReview, principal: &Principal{AccountID: 3}, want: AccessEdit},
+		{name: "draft", state: models.ProblemDraft, principal: &Principal{AccountID: 4}, want: AccessNone},
+		{name: "setter", state: models.ProblemDraft, principal: &Principal{AccountID: 4, Roles: []string{models.RoleSetter}}, want: AccessNone},
+		{name: "published", state: models.ProblemPublished, principal: &Principal{}, want: AccessRead},
+		{name: "scheduled", state: models.ProblemPublished, publishAt: &future, principal: &Principal{}, want: AccessNone},
+		{name: "archived", state: models.ProblemArchived, principal: &Principal{}, want: AccessRead},
+	}
+	for _, tt := range tests {
+		problem := &models.Problem{State: tt.state, PublishAt: tt.publishAt, OwnerID: 2}
+		got, err := s.access(problem, tt.principal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got access %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetState(t *testing.T) {
+	s := service{logger: zap.NewNop(), Repository: &accessRepository{}}
+	admin := &Principal{AccountID: 1, Roles: []string{models.RoleAdmin}}
+	owner := &Principal{AccountID: 2}
+
+	tests := []struct {
+		name      string
+		from, to  string
+		principal *Principal
+		ok        bool
+	}{
+		{name: "submit for review", from: models.ProblemDraft, to: models.ProblemReview, principal: owner, ok: true},
+		{name: "publish by owner", from: models.ProblemReview, to: models.ProblemPublished, principal: owner},
+		{name: "publish by admin", from: models.ProblemReview, to: models.ProblemPublished, principal: admin, ok: true},
+		{name: "unpublish by owner", from: models.ProblemPublished, to: models.ProblemDraft, principal: owner},
+		{name: "archive by admin", from: models.ProblemPublished, to: models.ProblemArchived, principal: admin, ok: true},
+		{name: "unknown state", from: models.ProblemDraft, to: "hidden", principal: admin},
+	}
+	for _, tt := range tests {
+		problem := &models.Problem{State: tt.from, OwnerID: 2}
+		_, err := s.SetState(tt.principal, problem, tt.to, nil)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if err == nil && problem.State != tt.to {
+			t.Errorf("%s: got state %s", tt.name, problem.State)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package problems
+
+import (
+	"io"
+	"path"
+	"strings"
+
+	volumes "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidAttachment = errors.New("invalid attachment name")
+
+// attachmentsDir is where the images and files embedded in statements are kept in the public
+// volume of a problem. A statement links them by the attachment route of the problem, which
+// follows the public volume as it changes.
+const attachmentsDir = "/attachments"
+
+// attachmentName checks an attachment is named by a single path element.
+func attachmentName(filename string) (string, error) {
+	filename = strings.Trim(filename, "/")
+	if filename == "" || filename == "." || filename == ".." || strings.Contains(filename, "/") {
+		return "", errors.Wrap(ErrInvalidAttachment, filename)
+	}
+	return filename, nil
+}
+
+func (s service) GetAttachments(name string) (models.FileRecords, error) {
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	if problem.PublicVolume == "" {
+		return models.FileRecords{}, nil
+	}
+	fileRecords, err := s.VolumeService.ListDirectory(problem.PublicVolume, attachmentsDir, false)
+	if errors.Is(err, volumes.ErrPathNotFound) {
+		return models.FileRecords{}, nil
+	}
+	return fileRecords, err
+}
+
+func (s service) GetAttachment(name, filename string) (io.ReadCloser, int64, error) {
+	filename, err := attachmentName(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if problem.PublicVolume == "" {
+		return nil, 0, volumes.ErrPathNotFound
+	}
+	return s.VolumeService.GetFile(problem.PublicVolume, path.Join(attachmentsDir, filename))
+}
+
+// AddAttachment stores the file in a new layer of the public volume of the problem, replacing an
+// attachment of the same name. A problem without a public volume gets one owned by the account.
+func (s service) AddAttachment(accountID uint64, name, filename string, file io.Reader) (*models.Problem, error) {
+	filename, err := attachmentName(filename)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	if problem.PublicVolume == "" {
+		volume, err := s.VolumeService.CreateVolume(accountID)
+		if err != nil {
+			return nil, err
+		}
+		problem.PublicVolume = volume.Name
+	}
+	volume, err := s.VolumeService.CreateFile(problem.PublicVolume, attachmentsDir, filename, file)
+	if err != nil {
+		return nil, err
+	}
+	return s.setPublicVolume(problem, volume)
+}
+
+func (s service) RemoveAttachment(name, filename string) (*models.Problem, error) {
+	filename, err := attachmentName(filename)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.GetAttachments(name)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, attachment := range attachments {
+		found = found || path.Base(attachment.FilePath) == filename && !attachment.IsDir()
+	}
+	if !found {
+		// removing would add a layer deleting nothing
+		return nil, volumes.ErrPathNotFound
+	}
+	volume, err := s.VolumeService.RemoveFile(problem.PublicVolume, attachmentsDir, filename)
+	if err != nil {
+		return nil, err
+	}
+	return s.setPublicVolume(problem, volume)
+}
+
+func (s service) setPublicVolume(problem *models.Problem, volume *models.Volume) (*models.Problem, error) {
+	problem.PublicVolume = volume.Name
+	if err := s.Repository.UpdateProblem(problem); err != nil {
+		s.logger.Error("update public volume",
+			zap.String("name", problem.Name),
+			zap.String("volume", volume.Name),
+			zap.Error(err))
+		return nil, err
+	}
+	return problem, nil
+}
@@ -1,9 +1,18 @@
 package problems
 
 import (
+	"mime"
 	"net/http"
+	"path"
+	"strconv"
+	"time"
 
+	"github.com/infinity-oj/server-v2/internal/app/blueprints"
 	"github.com/infinity-oj/server-v2/internal/app/ranklists"
+	volumes "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	ihttp "github.com/infinity-oj/server-v2/internal/pkg/http"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
@@ -17,38 +26,212 @@ type Controller interface {
 	GetProblems(c *gin.Context)
 	GetProblem(c *gin.Context)
 	GetPage(c *gin.Context)
+	GetPages(c *gin.Context)
+	SavePage(c *gin.Context)
+	DeletePage(c *gin.Context)
+	GetPageHistory(c *gin.Context)
+	GetAttachments(c *gin.Context)
+	GetAttachment(c *gin.Context)
+	CreateAttachment(c *gin.Context)
+	DeleteAttachment(c *gin.Context)
 	UpdateProblem(c *gin.Context)
+	SetState(c *gin.Context)
+	GetCollaborators(c *gin.Context)
+	AddCollaborator(c *gin.Context)
+	RemoveCollaborator(c *gin.Context)
+	GetStatistics(c *gin.Context)
 	GetRankList(c *gin.Context)
 	GetRankLists(c *gin.Context)
+	CreateRankList(c *gin.Context)
+	UpdateRankList(c *gin.Context)
+	DeleteRankList(c *gin.Context)
+
+	ImportPackage(c *gin.Context)
+	ExportPackage(c *gin.Context)
 }
 
 type DefaultController struct {
-	logger    *zap.Logger
-	service   Service
-	rlService ranklists.Service
+	logger         *zap.Logger
+	service        Service
+	rlService      ranklists.Service
+	packageService PackageService
+	volumeService  volumes.Service
 }
 
+// GetRankList returns the ranklist of the path with the accounts ranked by its metrics.
 func (pc *DefaultController) GetRankList(c *gin.Context) {
-	name := c.Param("name")
-	id := c.Param("id")
+	problem, _ := pc.authorize(c, AccessRead)
+	if problem == nil {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
 
 	pc.logger.Debug("get ranklist",
-		zap.String("problem name", name),
-		zap.String("ranklist id", id),
+		zap.String("problem name", problem.Name),
+		zap.Uint64("ranklist id", id),
+	)
+
+	ranking, err := pc.rlService.GetRanking(problem, id)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get ranklist", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, ranking)
+}
+
+// rankListRequest is the body creating or updating a ranklist. Metrics rank accounts by the
+// lowest priority first.
+type rankListRequest struct {
+	Name    string `json:"name" binding:"required,gt=0"`
+	Title   string `json:"title" binding:"required,gt=0"`
+	Metrics []struct {
+		Key      string `json:"key" binding:"required,gt=0"`
+		Priority uint   `json:"priority"`
+		Order    string `json:"order" binding:"required,oneof=asc desc inc dec"`
+	} `json:"metrics" binding:"required,gt=0,dive"`
+}
+
+func (r rankListRequest) models() []models.RankListModel {
+	var metrics []models.RankListModel
+	for _, metric := range r.Metrics {
+		metrics = append(metrics, models.RankListModel{
+			Key:      metric.Key,
+			Priority: metric.Priority,
+			Order:    metric.Order,
+		})
+	}
+	return metrics
+}
+
+func (pc *DefaultController) CreateRankList(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	pc.logger.Debug("create ranklist",
+		zap.String("problem name", problem.Name),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	request := rankListRequest{}
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	rankList, err := pc.rlService.CreateRankList(problem, request.Name, request.Title, request.models())
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("create ranklist", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, rankList)
+}
+
+// UpdateRankList renames the ranklist of the path and replaces its metrics. Records are kept.
+func (pc *DefaultController) UpdateRankList(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	pc.logger.Debug("update ranklist",
+		zap.String("problem name", problem.Name),
+		zap.Uint64("ranklist id", id),
+		zap.Uint64("account id", session.AccountId),
 	)
 
+	request := rankListRequest{}
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	rankList, err := pc.rlService.UpdateRankList(problem, id, request.Name, request.Title, request.models())
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("update ranklist", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, rankList)
 }
+
+// DeleteRankList deletes the ranklist of the path with its records.
+func (pc *DefaultController) DeleteRankList(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	pc.logger.Debug("delete ranklist",
+		zap.String("problem name", problem.Name),
+		zap.Uint64("ranklist id", id),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	if err := pc.rlService.DeleteRankList(problem, id); err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("delete ranklist", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (pc *DefaultController) GetRankLists(c *gin.Context) {
 	name := c.Param("name")
 
 	pc.logger.Debug("get ranklist", zap.String("problem name", name))
 
-	p, err := pc.service.GetProblemByName(name)
-	if err != nil {
-		pc.logger.Error("get problem", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": err.Error(),
-		})
+	p, _ := pc.authorize(c, AccessRead)
+	if p == nil {
 		return
 	}
 
@@ -69,12 +252,15 @@ func (pc *DefaultController) GetRankLists(c *gin.Context) {
 }
 
 func (pc *DefaultController) GetPage(c *gin.Context) {
+	if problem, _ := pc.authorize(c, AccessRead); problem == nil {
+		return
+	}
 	name := c.Param("name")
 
 	request := struct {
 		Locale string `form:"locale"`
 	}{
-		Locale: "*",
+		Locale: anyLocale,
 	}
 
 	if err := c.ShouldBindQuery(&request); err != nil {
@@ -99,6 +285,12 @@ func (pc *DefaultController) GetPage(c *gin.Context) {
 	)
 
 	page, err := pc.service.GetPage(name, locale)
+	if errors.Is(err, ErrInvalidLocale) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		pc.logger.Error("get page", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -113,11 +305,513 @@ func (pc *DefaultController) GetPage(c *gin.Context) {
 	c.JSON(http.StatusOK, page)
 }
 
-func (pc *DefaultController) CreateProblem(c *gin.Context) {
+// abortOnError aborts the request if err is one the client is to blame for, and reports whether
+// it did.
+func (pc *DefaultController) abortOnError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, ErrProblemNotFound), errors.Is(err, ErrPageNotFound), errors.Is(err, ErrAccountNotFound),
+		errors.Is(err, volumes.ErrPathNotFound), errors.Is(err, ranklists.ErrRankListNotFound), err.Error() == "not found":
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"msg": err.Error(),
+		})
+	case errors.Is(err, ErrInvalidLocale), errors.Is(err, ErrInvalidAttachment), errors.Is(err, ErrInvalidState),
+		errors.Is(err, ranklists.ErrInvalidMetrics):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+	case errors.Is(err, ranklists.ErrRankListExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"msg": err.Error(),
+		})
+	case errors.Is(err, volumes.ErrFileTooLarge):
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"msg": err.Error(),
+		})
+	case errors.Is(err, volumes.ErrQuotaExceeded), errors.Is(err, ErrPermissionDenied):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"msg": err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
+// principal returns who makes the request, anonymous without a session.
+func principal(session *sessions.Session) *Principal {
+	if session == nil {
+		return &Principal{}
+	}
+	return &Principal{
+		AccountID: session.AccountId,
+		Roles:     session.Roles,
+	}
+}
+
+// authorize returns the problem of the path and the session if the session has the access to
+// it. Otherwise it aborts the request and returns a nil problem, with 404 if the problem may not
+// be read at all, 401 for anonymous requests and 403 for signed in ones.
+func (pc *DefaultController) authorize(c *gin.Context, access Access) (*models.Problem, *sessions.Session) {
+	name := c.Param("name")
+	session := sessions.GetSession(c)
+	problem, granted, err := pc.service.GetAccess(name, principal(session))
+	if err != nil {
+		pc.logger.Error("get problem access", zap.String("problem name", name), zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil, nil
+	}
+	if granted >= access {
+		return problem, session
+	}
+
+	// unpublished problems are not told apart from missing ones
+	switch {
+	case granted == AccessNone:
+		c.AbortWithStatus(http.StatusNotFound)
+	case session == nil:
+		c.AbortWithStatus(http.StatusUnauthorized)
+	default:
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+	return nil, nil
+}
+
+// authorizeSetter returns the session if it may create problems, which admins and setters may.
+// Otherwise it aborts the request and returns nil.
+func (pc *DefaultController) authorizeSetter(c *gin.Context) *sessions.Session {
 	session := sessions.GetSession(c)
 	if session == nil {
 		pc.logger.Debug("get principal failed")
 		c.AbortWithStatus(http.StatusUnauthorized)
+		return nil
+	}
+	if !session.HasRole(models.RoleAdmin, models.RoleSetter) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return nil
+	}
+	return session
+}
+
+// GetPages returns the pages of the problem in every locale.
+func (pc *DefaultController) GetPages(c *gin.Context) {
+	if problem, _ := pc.authorize(c, AccessRead); problem == nil {
+		return
+	}
+	name := c.Param("name")
+
+	pc.logger.Debug("get pages", zap.String("problem name", name))
+
+	pages, err := pc.service.GetPages(name)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get pages", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, pages)
+}
+
+// SavePage creates or updates the page of the problem in the locale of the path, "default" for
+// the page without a locale.
+func (pc *DefaultController) SavePage(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+	locale := c.Param("locale")
+
+	request := struct {
+		Title        string `json:"title" binding:"required,gt=0"`
+		Description  string `json:"description"`
+		InputFormat  string `json:"input_format"`
+		OutputFormat string `json:"output_format"`
+		Example      string `json:"example"`
+		HintAndLimit string `json:"hint_and_limit"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	pc.logger.Debug("save page",
+		zap.String("problem name", name),
+		zap.String("locale", locale),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	page, err := pc.service.SavePage(session.AccountId, name, locale, &models.PageContent{
+		Title:        request.Title,
+		Description:  request.Description,
+		InputFormat:  request.InputFormat,
+		OutputFormat: request.OutputFormat,
+		Example:      request.Example,
+		HintAndLimit: request.HintAndLimit,
+	})
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("save page", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (pc *DefaultController) DeletePage(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+	locale := c.Param("locale")
+
+	pc.logger.Debug("delete page",
+		zap.String("problem name", name),
+		zap.String("locale", locale),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	if err := pc.service.DeletePage(session.AccountId, name, locale); err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("delete page", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPageHistory returns the revisions of the page of the problem in the locale, newest first.
+func (pc *DefaultController) GetPageHistory(c *gin.Context) {
+	if problem, _ := pc.authorize(c, AccessEdit); problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+	locale := c.Param("locale")
+
+	pc.logger.Debug("get page history",
+		zap.String("problem name", name),
+		zap.String("locale", locale),
+	)
+
+	revisions, err := pc.service.GetPageHistory(name, locale)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get page history", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetAttachments lists the attachments of the problem statements.
+func (pc *DefaultController) GetAttachments(c *gin.Context) {
+	if problem, _ := pc.authorize(c, AccessRead); problem == nil {
+		return
+	}
+	name := c.Param("name")
+
+	pc.logger.Debug("get attachments", zap.String("problem name", name))
+
+	attachments, err := pc.service.GetAttachments(name)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get attachments", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// GetAttachment downloads an attachment of the problem statements, which is how statements embed
+// images and link files.
+func (pc *DefaultController) GetAttachment(c *gin.Context) {
+	if problem, _ := pc.authorize(c, AccessRead); problem == nil {
+		return
+	}
+	name := c.Param("name")
+	filename := c.Param("filename")
+
+	pc.logger.Debug("get attachment",
+		zap.String("problem name", name),
+		zap.String("filename", filename),
+	)
+
+	reader, size, err := pc.service.GetAttachment(name, filename)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get attachment", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
+}
+
+// CreateAttachment uploads an attachment of the problem statements into its public volume.
+func (pc *DefaultController) CreateAttachment(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+
+	if limit := pc.volumeService.Limits().MaxFileSize; limit > 0 {
+		// stop reading the upload early rather than spooling an oversized body to disk
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+	}
+	formFile, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+
+	pc.logger.Debug("create attachment",
+		zap.String("problem name", name),
+		zap.String("filename", formFile.Filename),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	file, err := formFile.Open()
+	if err != nil {
+		pc.logger.Error("create attachment", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	problem, err = pc.service.AddAttachment(session.AccountId, name, formFile.Filename, file)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("create attachment", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, problem)
+}
+
+func (pc *DefaultController) DeleteAttachment(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+	filename := c.Param("filename")
+
+	pc.logger.Debug("delete attachment",
+		zap.String("problem name", name),
+		zap.String("filename", filename),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	problem, err := pc.service.RemoveAttachment(name, filename)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("delete attachment", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, problem)
+}
+
+// SetState moves the problem through the publishing workflow. A published problem may be
+// scheduled by publish_at.
+func (pc *DefaultController) SetState(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	request := struct {
+		State     string     `json:"state" binding:"required,oneof=draft review published archived"`
+		PublishAt *time.Time `json:"publish_at"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	pc.logger.Debug("set problem state",
+		zap.String("problem name", problem.Name),
+		zap.String("state", request.State),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	problem, err := pc.service.SetState(principal(session), problem, request.State, request.PublishAt)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("set problem state", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, problem)
+}
+
+func (pc *DefaultController) GetCollaborators(c *gin.Context) {
+	problem, _ := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	accounts, err := pc.service.GetCollaborators(problem.Name)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get collaborators", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, accounts)
+}
+
+// AddCollaborator lets the account of the path edit the problem.
+func (pc *DefaultController) AddCollaborator(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessManage)
+	if problem == nil {
+		return
+	}
+	account := c.Param("account")
+
+	pc.logger.Debug("add collaborator",
+		zap.String("problem name", problem.Name),
+		zap.String("account", account),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	if err := pc.service.AddCollaborator(problem.Name, account); err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("add collaborator", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (pc *DefaultController) RemoveCollaborator(c *gin.Context) {
+	problem, session := pc.authorize(c, AccessManage)
+	if problem == nil {
+		return
+	}
+	account := c.Param("account")
+
+	pc.logger.Debug("remove collaborator",
+		zap.String("problem name", problem.Name),
+		zap.String("account", account),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	if err := pc.service.RemoveCollaborator(problem.Name, account); err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("remove collaborator", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetStatistics returns the statistics of the problem, with a daily series of the last days.
+func (pc *DefaultController) GetStatistics(c *gin.Context) {
+	problem, _ := pc.authorize(c, AccessRead)
+	if problem == nil {
+		return
+	}
+
+	request := struct {
+		Days int `form:"days,default=30" binding:"gt=0,lte=366"`
+	}{}
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	pc.logger.Debug("get statistics",
+		zap.String("problem name", problem.Name),
+		zap.Int("days", request.Days),
+	)
+
+	statistics, err := pc.service.GetStatistics(problem.Name, request.Days)
+	if err != nil {
+		if pc.abortOnError(c, err) {
+			return
+		}
+		pc.logger.Error("get statistics", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, statistics)
+}
+
+// CreateProblem creates a draft problem owned by the admin or setter creating it.
+func (pc *DefaultController) CreateProblem(c *gin.Context) {
+	session := pc.authorizeSetter(c)
+	if session == nil {
 		return
 	}
 
@@ -141,7 +835,7 @@ func (pc *DefaultController) CreateProblem(c *gin.Context) {
 		})
 		return
 	}
-	problem, err := pc.service.CreateProblem(request.Name, request.Title)
+	problem, err := pc.service.CreateProblem(session.AccountId, request.Name, request.Title)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{
 			"message": err.Error(),
@@ -152,12 +846,21 @@ func (pc *DefaultController) CreateProblem(c *gin.Context) {
 	c.JSON(http.StatusOK, problem)
 }
 
+// GetProblems lists a page of problems, searched by keyword and filtered by tags, difficulty and
+// whether the account solved them, sorted by id, acceptance rate or recent activity.
 func (pc *DefaultController) GetProblems(c *gin.Context) {
 	request := struct {
 		Page     int `form:"page" binding:"required,gt=0"`
 		PageSize int `form:"pageSize" binding:"required,gt=0,lte=15"`
 
-		Sort string `json:"sort"`
+		Keyword       string   `form:"keyword"`
+		Tags          []string `form:"tag"`
+		MinDifficulty int      `form:"minDifficulty" binding:"gte=0"`
+		MaxDifficulty int      `form:"maxDifficulty" binding:"gte=0"`
+		Status        string   `form:"status" binding:"omitempty,oneof=solved unsolved"`
+
+		Sort  string `form:"sort" binding:"omitempty,oneof=id acceptance activity"`
+		Order string `form:"order" binding:"omitempty,oneof=asc desc"`
 	}{}
 
 	if err := c.ShouldBindQuery(&request); err != nil {
@@ -180,19 +883,41 @@ func (pc *DefaultController) GetProblems(c *gin.Context) {
 		zap.Int("pageSize", request.PageSize),
 	)
 
-	problems, err := pc.service.GetProblems(request.Page, request.PageSize)
+	query := &ProblemQuery{
+		Keyword:       request.Keyword,
+		Tags:          request.Tags,
+		MinDifficulty: request.MinDifficulty,
+		MaxDifficulty: request.MaxDifficulty,
+		Sort:          request.Sort,
+		// rates and activity are most useful highest first
+		Desc: request.Order == "desc" || request.Order == "" && request.Sort != "" && request.Sort != SortByID,
+	}
+	session := sessions.GetSession(c)
+	if request.Status != "" {
+		if session == nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		solved := request.Status == "solved"
+		query.Solved = &solved
+	}
+
+	problems, total, err := pc.service.GetProblems(principal(session), query, request.Page, request.PageSize)
 	if err != nil {
-		pc.logger.Error("get account", zap.Error(err))
+		pc.logger.Error("get problems", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
-	if problems == nil {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
-	}
-	c.JSON(http.StatusOK, problems)
+	c.JSON(http.StatusOK, ihttp.ListResult{
+		List: problems,
+		Pagination: &ihttp.PaginationResult{
+			Total:    int(total),
+			Current:  uint(request.Page),
+			PageSize: uint(request.PageSize),
+		},
+	})
 }
 
 func (pc *DefaultController) GetProblem(c *gin.Context) {
@@ -200,38 +925,46 @@ func (pc *DefaultController) GetProblem(c *gin.Context) {
 
 	pc.logger.Debug("get problem", zap.String("problem name", name))
 
-	problem, err := pc.service.GetProblemByName(name)
-	if err != nil {
-		pc.logger.Error("get account", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": err.Error(),
-		})
-		return
-	}
+	problem, _ := pc.authorize(c, AccessRead)
 	if problem == nil {
-		c.AbortWithStatus(http.StatusNotFound)
 		return
 	}
 	c.JSON(http.StatusOK, problem)
 }
 
-func (pc *DefaultController) UpdateProblem(c *gin.Context) {
-	name := c.Param("name")
-	problem, err := pc.service.GetProblemByName(name)
+// authorizeVolume reports whether the session may write the volume. Otherwise it aborts the
+// request, with 400 if there is no such volume and 403 if it may not be written.
+func (pc *DefaultController) authorizeVolume(c *gin.Context, session *sessions.Session, volumeName string) bool {
+	permission, err := pc.volumeService.GetPermission(volumeName, &volumes.Principal{
+		AccountID: session.AccountId,
+		Roles:     session.Roles,
+	})
+	if errors.Is(err, volumes.ErrVolumeNotFound) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return false
+	}
 	if err != nil {
-		c.AbortWithStatus(http.StatusInternalServerError)
-		return
+		pc.logger.Error("get volume permission", zap.String("volume", volumeName), zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return false
 	}
-
-	if problem == nil {
-		c.AbortWithStatus(http.StatusNotFound)
-		return
+	if permission < volumes.PermissionWrite {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"msg": ErrPermissionDenied.Error(),
+		})
+		return false
 	}
+	return true
+}
 
-	session := sessions.GetSession(c)
-	if session == nil {
-		pc.logger.Debug("get principal failed")
-		c.AbortWithStatus(http.StatusUnauthorized)
+func (pc *DefaultController) UpdateProblem(c *gin.Context) {
+	name := c.Param("name")
+	problem, session := pc.authorize(c, AccessEdit)
+	if problem == nil {
 		return
 	}
 
@@ -242,6 +975,11 @@ func (pc *DefaultController) UpdateProblem(c *gin.Context) {
 
 		PublicVolume  string `json:"publicVolume" binding:"required,gt=0"`
 		PrivateVolume string `json:"privateVolume" binding:"required,gt=0"`
+
+		// left as they are if absent
+		Tags       *[]string `json:"tags"`
+		Difficulty *int      `json:"difficulty" binding:"omitempty,gte=0"`
+		Source     *string   `json:"source"`
 	}{}
 
 	if err := c.ShouldBind(&request); err != nil {
@@ -259,7 +997,23 @@ func (pc *DefaultController) UpdateProblem(c *gin.Context) {
 		return
 	}
 
-	problem, err = pc.service.UpdateProblem(problem, name, request.Title, request.PublicVolume, request.PrivateVolume)
+	// the volumes are handed out as the data of the problem, so they must be the editor's to write
+	for _, volumeName := range []string{request.PublicVolume, request.PrivateVolume} {
+		if !pc.authorizeVolume(c, session, volumeName) {
+			return
+		}
+	}
+
+	if request.Tags != nil {
+		problem.Tags = *request.Tags
+	}
+	if request.Difficulty != nil {
+		problem.Difficulty = *request.Difficulty
+	}
+	if request.Source != nil {
+		problem.Source = *request.Source
+	}
+	problem, err := pc.service.UpdateProblem(problem, name, request.Title, request.PublicVolume, request.PrivateVolume)
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{
 			"message": err.Error(),
@@ -270,10 +1024,102 @@ func (pc *DefaultController) UpdateProblem(c *gin.Context) {
 	c.JSON(http.StatusOK, problem)
 }
 
-func NewController(logger *zap.Logger, s Service, rls ranklists.Service) Controller {
+// ImportPackage creates the problem named in the path from an uploaded problem package, as a
+// draft owned by the admin or setter importing it.
+func (pc *DefaultController) ImportPackage(c *gin.Context) {
+	session := pc.authorizeSetter(c)
+	if session == nil {
+		return
+	}
+
+	name := c.Param("name")
+	pc.logger.Debug("import problem package",
+		zap.String("problem name", name),
+		zap.Uint64("account id", session.AccountId),
+	)
+
+	if limit := pc.volumeService.Limits().MaxArchiveSize; limit > 0 {
+		// stop reading the upload early rather than spooling an oversized body to disk
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+1<<20)
+	}
+	formFile, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+	file, err := formFile.Open()
+	if err != nil {
+		pc.logger.Error("import problem package", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	problem, err := pc.packageService.ImportPackage(session.AccountId, name, file, formFile.Size)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrProblemExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"msg": err.Error(),
+			})
+		case errors.Is(err, ErrInvalidPackage), errors.Is(err, blueprints.ErrInvalidDefinition),
+			errors.Is(err, volumes.ErrInvalidArchive), errors.Is(err, volumes.ErrInvalidPath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		case errors.Is(err, volumes.ErrFileTooLarge), errors.Is(err, volumes.ErrArchiveTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"msg": err.Error(),
+			})
+		case errors.Is(err, volumes.ErrQuotaExceeded):
+			c.JSON(http.StatusForbidden, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			pc.logger.Error("import problem package", zap.String("problem name", name), zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, problem)
+}
+
+// ExportPackage downloads the problem as a package. It holds the private data, so only those
+// who may edit the problem may export.
+func (pc *DefaultController) ExportPackage(c *gin.Context) {
+	problem, _ := pc.authorize(c, AccessEdit)
+	if problem == nil {
+		return
+	}
+
+	name := c.Param("name")
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": problem.Name + ".zip",
+	}))
+	if err := pc.packageService.ExportPackage(name, c.Writer); err != nil {
+		pc.logger.Error("export problem package", zap.String("problem name", name), zap.Error(err))
+		if c.Writer.Written() {
+			// the package is partially sent, the client sees a truncated zip
+			c.Abort()
+		} else {
+			c.Header("Content-Type", "")
+			c.Header("Content-Disposition", "")
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+	}
+}
+
+func NewController(logger *zap.Logger, s Service, rls ranklists.Service, ps PackageService, vs volumes.Service) Controller {
 	return &DefaultController{
-		logger:    logger,
-		service:   s,
-		rlService: rls,
+		logger:         logger,
+		service:        s,
+		rlService:      rls,
+		packageService: ps,
+		volumeService:  vs,
 	}
 }
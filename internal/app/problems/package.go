@@ -0,0 +1,576 @@
+package problems
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/internal/app/blueprints"
+	"github.com/infinity-oj/server-v2/internal/app/ranklists"
+	volumes "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	ErrProblemNotFound = errors.New("problem not found")
+	ErrProblemExists   = errors.New("problem exists")
+	ErrInvalidPackage  = errors.New("invalid problem package")
+)
+
+// A problem package is a zip archive of
+//
+//	problem.yaml              the Manifest
+//	statements/<locale>.yaml  a Statement per locale, default.yaml for the page without a locale
+//	blueprint.json            the blueprint scene, if the manifest names it
+//	public/                   the public data tree
+//	private/                  the private data tree
+//
+// Polygon packages, which have a problem.xml instead, are imported as well.
+const (
+	manifestFile        = "problem.yaml"
+	manifestVersion     = 1
+	polygonManifestFile = "problem.xml"
+	blueprintFile       = "blueprint.json"
+	statementsDir       = "statements"
+	publicDir           = "public"
+	privateDir          = "private"
+	defaultLocale       = "default"
+
+	// maxMetadataSize bounds the files of a package read into memory, the data trees are streamed.
+	maxMetadataSize = 4 << 20
+)
+
+// Manifest describes the problem of a package.
+type Manifest struct {
+	Version   int                 `yaml:"version"`
+	Name      string              `yaml:"name"`
+	Title     string              `yaml:"title"`
+	Blueprint string              `yaml:"blueprint,omitempty"`
+	RankLists []*ManifestRankList `yaml:"ranklists,omitempty"`
+
+	Tags       []string `yaml:"tags,omitempty"`
+	Difficulty int      `yaml:"difficulty,omitempty"`
+	Source     string   `yaml:"source,omitempty"`
+}
+
+type ManifestRankList struct {
+	Name    string            `yaml:"name"`
+	Title   string            `yaml:"title"`
+	Metrics []*ManifestMetric `yaml:"metrics"`
+}
+
+type ManifestMetric struct {
+	Key      string `yaml:"key"`
+	Priority uint   `yaml:"priority"`
+	Order    string `yaml:"order"`
+}
+
+// Statement is the problem page in one locale.
+type Statement struct {
+	Title        string `yaml:"title"`
+	Description  string `yaml:"description"`
+	InputFormat  string `yaml:"input_format"`
+	OutputFormat string `yaml:"output_format"`
+	Example      string `yaml:"example"`
+	HintAndLimit string `yaml:"hint_and_limit"`
+}
+
+type PackageService interface {
+	// ImportPackage creates the problem named name from the package, with volumes owned by the account.
+	ImportPackage(accountID uint64, name string, archive io.ReaderAt, size int64) (*models.Problem, error)
+	// ExportPackage writes the problem as a package to w.
+	ExportPackage(name string, w io.Writer) error
+}
+
+type packageService struct {
+	logger             *zap.Logger
+	Repository         Repository
+	VolumeService      volumes.Service
+	BlueprintService   blueprints.Service
+	RankListRepository ranklists.Repository
+}
+
+// problemPackage is what a package holds besides its data trees.
+type problemPackage struct {
+	title      string
+	tags       []string
+	difficulty int
+	source     string
+	blueprint  string
+	pages      []*models.Page
+	rankLists  []*models.RankList
+
+	// public and private are the directories of the data trees in the archive, empty if absent
+	public  string
+	private string
+}
+
+func (s packageService) ImportPackage(accountID uint64, name string, archive io.ReaderAt, size int64) (*models.Problem, error) {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPackage, err.Error())
+	}
+	files := map[string]*zip.File{}
+	for _, f := range reader.File {
+		files[path.Clean(f.Name)] = f
+	}
+
+	var pkg *problemPackage
+	switch {
+	case files[manifestFile] != nil:
+		pkg, err = readPackage(reader.File, files)
+	case files[polygonManifestFile] != nil:
+		pkg, err = readPolygonPackage(reader.File, files)
+	default:
+		err = errors.Wrapf(ErrInvalidPackage, "neither %s nor %s found", manifestFile, polygonManifestFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pkg.title == "" {
+		pkg.title = name
+	}
+
+	problem, err := s.Repository.GetProblemByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if problem != nil {
+		return nil, ErrProblemExists
+	}
+
+	// volumes and the blueprint go first, if the import fails they are left unreferenced
+	var blueprintID uint64
+	if pkg.blueprint != "" {
+		blueprint, err := s.BlueprintService.CreateBlueprint(pkg.blueprint)
+		if err != nil {
+			return nil, err
+		}
+		blueprintID = blueprint.ID
+	}
+	publicVolume, err := s.importTree(accountID, pkg.public, archive, size)
+	if err != nil {
+		return nil, err
+	}
+	privateVolume, err := s.importTree(accountID, pkg.private, archive, size)
+	if err != nil {
+		return nil, err
+	}
+
+	problem = &models.Problem{
+		Name:          name,
+		Title:         pkg.title,
+		State:         models.ProblemDraft,
+		OwnerID:       accountID,
+		BlueprintID:   blueprintID,
+		Tags:          normalizeTags(pkg.tags),
+		Difficulty:    pkg.difficulty,
+		Source:        pkg.source,
+		PublicVolume:  publicVolume,
+		PrivateVolume: privateVolume,
+	}
+	if err := s.Repository.ImportProblem(accountID, problem, pkg.pages, pkg.rankLists); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("import problem package",
+		zap.String("name", name),
+		zap.Int("pages", len(pkg.pages)),
+		zap.Int("ranklists", len(pkg.rankLists)),
+	)
+	return problem, nil
+}
+
+// importTree creates a volume holding the directory from of the archive, or an empty one if from is empty.
+func (s packageService) importTree(accountID uint64, from string, archive io.ReaderAt, size int64) (string, error) {
+	volume, err := s.VolumeService.CreateVolume(accountID)
+	if err != nil {
+		return "", err
+	}
+	if from == "" {
+		return volume.Name, nil
+	}
+	if volume, err = s.VolumeService.ExtractArchiveTree(volume.Name, "/", from, archive, size); err != nil {
+		return "", err
+	}
+	return volume.Name, nil
+}
+
+func readPackage(entries []*zip.File, files map[string]*zip.File) (*problemPackage, error) {
+	manifest := &Manifest{}
+	if err := readYAML(files[manifestFile], manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Version > manifestVersion {
+		return nil, errors.Wrapf(ErrInvalidPackage, "unsupported version %d", manifest.Version)
+	}
+
+	pkg := &problemPackage{
+		title:      manifest.Title,
+		tags:       manifest.Tags,
+		difficulty: manifest.Difficulty,
+		source:     manifest.Source,
+	}
+	if manifest.Blueprint != "" {
+		f := files[path.Clean(manifest.Blueprint)]
+		if f == nil {
+			return nil, errors.Wrapf(ErrInvalidPackage, "blueprint %s not found", manifest.Blueprint)
+		}
+		definition, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pkg.blueprint = string(definition)
+	}
+
+	for _, f := range entries {
+		name := path.Clean(f.Name)
+		if path.Dir(name) != statementsDir || path.Ext(name) != ".yaml" {
+			continue
+		}
+		statement := &Statement{}
+		if err := readYAML(f, statement); err != nil {
+			return nil, err
+		}
+		locale, err := pkg.pageLocale(strings.TrimSuffix(path.Base(name), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		pkg.pages = append(pkg.pages, &models.Page{
+			Locale: locale,
+			PageContent: models.PageContent{
+				Title:        statement.Title,
+				Description:  statement.Description,
+				InputFormat:  statement.InputFormat,
+				OutputFormat: statement.OutputFormat,
+				Example:      statement.Example,
+				HintAndLimit: statement.HintAndLimit,
+			},
+		})
+	}
+
+	for _, rl := range manifest.RankLists {
+		rankList := &models.RankList{
+			Name:  rl.Name,
+			Title: rl.Title,
+		}
+		for _, metric := range rl.Metrics {
+			rankList.Models = append(rankList.Models, models.RankListModel{
+				Key:      metric.Key,
+				Priority: metric.Priority,
+				Order:    metric.Order,
+			})
+		}
+		pkg.rankLists = append(pkg.rankLists, rankList)
+	}
+
+	for name := range files {
+		if strings.HasPrefix(name, publicDir+"/") || name == publicDir {
+			pkg.public = publicDir
+		}
+		if strings.HasPrefix(name, privateDir+"/") || name == privateDir {
+			pkg.private = privateDir
+		}
+	}
+	return pkg, nil
+}
+
+type polygonProblem struct {
+	ShortName string `xml:"short-name,attr"`
+	Names     []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+}
+
+// polygonProperties is the problem-properties.json of a statement, with its sections in TeX.
+type polygonProperties struct {
+	Name        string `json:"name"`
+	Legend      string `json:"legend"`
+	Input       string `json:"input"`
+	Output      string `json:"output"`
+	Notes       string `json:"notes"`
+	SampleTests []struct {
+		Input  string `json:"input"`
+		Output string `json:"output"`
+	} `json:"sampleTests"`
+}
+
+var polygonLocales = map[string]string{
+	"english":   "en",
+	"russian":   "ru",
+	"chinese":   "zh",
+	"ukrainian": "uk",
+}
+
+// pageLocale returns the canonical locale of a statement of the package, which must be valid
+// and not taken by another statement.
+func (pkg *problemPackage) pageLocale(locale string) (string, error) {
+	canonical, err := canonicalLocale(locale)
+	if err != nil {
+		return "", errors.Wrapf(ErrInvalidPackage, "invalid statement locale %s", locale)
+	}
+	for _, page := range pkg.pages {
+		if page.Locale == canonical {
+			return "", errors.Wrapf(ErrInvalidPackage, "statements of locale %s repeat", locale)
+		}
+	}
+	return canonical, nil
+}
+
+// readPolygonPackage reads a Polygon package, whose statements become pages. The whole package
+// is the private data tree, as judges expect tests, checkers and validators where Polygon put them,
+// and the statements with their resources are the public one. It has no blueprint.
+func readPolygonPackage(entries []*zip.File, files map[string]*zip.File) (*problemPackage, error) {
+	content, err := readFile(files[polygonManifestFile])
+	if err != nil {
+		return nil, err
+	}
+	problem := &polygonProblem{}
+	if err := xml.Unmarshal(content, problem); err != nil {
+		return nil, errors.Wrap(ErrInvalidPackage, err.Error())
+	}
+
+	pkg := &problemPackage{
+		title:   problem.ShortName,
+		private: "/",
+	}
+	for i, name := range problem.Names {
+		if i == 0 || name.Language == "english" {
+			pkg.title = name.Value
+		}
+	}
+
+	for _, f := range entries {
+		name := path.Clean(f.Name)
+		if path.Base(name) != "problem-properties.json" || path.Dir(path.Dir(name)) != statementsDir {
+			continue
+		}
+		content, err := readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		properties := &polygonProperties{}
+		if err := json.Unmarshal(content, properties); err != nil {
+			return nil, errors.Wrapf(ErrInvalidPackage, "%s: %s", name, err.Error())
+		}
+
+		language := path.Base(path.Dir(name))
+		locale, ok := polygonLocales[language]
+		if !ok {
+			locale = language
+		}
+		if locale, err = pkg.pageLocale(locale); err != nil {
+			return nil, err
+		}
+		example := &strings.Builder{}
+		for _, sample := range properties.SampleTests {
+			fmt.Fprintf(example, "```\n%s```\n\n```\n%s```\n\n", sample.Input, sample.Output)
+		}
+		pkg.pages = append(pkg.pages, &models.Page{
+			Locale: locale,
+			PageContent: models.PageContent{
+				Title:        properties.Name,
+				Description:  properties.Legend,
+				InputFormat:  properties.Input,
+				OutputFormat: properties.Output,
+				Example:      strings.TrimSpace(example.String()),
+				HintAndLimit: properties.Notes,
+			},
+		})
+		pkg.public = statementsDir
+	}
+	return pkg, nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxMetadataSize {
+		return nil, errors.Wrapf(ErrInvalidPackage, "%s is too large", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPackage, err.Error())
+	}
+	defer rc.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxMetadataSize+1))
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPackage, err.Error())
+	}
+	if len(content) > maxMetadataSize {
+		return nil, errors.Wrapf(ErrInvalidPackage, "%s is too large", f.Name)
+	}
+	return content, nil
+}
+
+func readYAML(f *zip.File, v interface{}) error {
+	content, err := readFile(f)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(content, v); err != nil {
+		return errors.Wrapf(ErrInvalidPackage, "%s: %s", f.Name, err.Error())
+	}
+	return nil
+}
+
+func (s packageService) ExportPackage(name string, w io.Writer) error {
+	problem, err := s.Repository.GetProblemByName(name)
+	if err != nil {
+		return err
+	}
+	if problem == nil {
+		return ErrProblemNotFound
+	}
+
+	manifest := &Manifest{
+		Version:    manifestVersion,
+		Name:       problem.Name,
+		Title:      problem.Title,
+		Tags:       problem.Tags,
+		Difficulty: problem.Difficulty,
+		Source:     problem.Source,
+	}
+	var blueprint *models.Blueprint
+	if problem.BlueprintID != 0 {
+		if blueprint, err = s.BlueprintService.GetBlueprint(problem.BlueprintID); err != nil {
+			return err
+		}
+		if blueprint != nil {
+			manifest.Blueprint = blueprintFile
+		}
+	}
+	rankLists, err := s.RankListRepository.GetRankListsByProblem(problem)
+	if err != nil {
+		return err
+	}
+	for _, rankList := range rankLists {
+		rl := &ManifestRankList{
+			Name:  rankList.Name,
+			Title: rankList.Title,
+		}
+		sort.Slice(rankList.Models, func(i, j int) bool {
+			return rankList.Models[i].Priority < rankList.Models[j].Priority
+		})
+		for _, model := range rankList.Models {
+			rl.Metrics = append(rl.Metrics, &ManifestMetric{
+				Key:      model.Key,
+				Priority: model.Priority,
+				Order:    model.Order,
+			})
+		}
+		manifest.RankLists = append(manifest.RankLists, rl)
+	}
+	pages, err := s.Repository.GetPages(problem.ID)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+	if err := writeYAML(archive, manifestFile, manifest); err != nil {
+		return err
+	}
+	for _, page := range pages {
+		locale := page.Locale
+		if locale == "" {
+			locale = defaultLocale
+		}
+		statement := &Statement{
+			Title:        page.Title,
+			Description:  page.Description,
+			InputFormat:  page.InputFormat,
+			OutputFormat: page.OutputFormat,
+			Example:      page.Example,
+			HintAndLimit: page.HintAndLimit,
+		}
+		if err := writeYAML(archive, path.Join(statementsDir, locale+".yaml"), statement); err != nil {
+			return err
+		}
+	}
+	if blueprint != nil {
+		f, err := archive.Create(blueprintFile)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, blueprint.Definition); err != nil {
+			return err
+		}
+	}
+	if err := s.exportTree(archive, publicDir, problem.PublicVolume); err != nil {
+		return err
+	}
+	if err := s.exportTree(archive, privateDir, problem.PrivateVolume); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// exportTree writes the files of the volume to the archive under dir.
+func (s packageService) exportTree(archive *zip.Writer, dir, volumeName string) error {
+	if volumeName == "" {
+		return nil
+	}
+	fileRecords, err := s.VolumeService.ListDirectory(volumeName, "/", true)
+	if err != nil {
+		return errors.Wrapf(err, "list volume %s", volumeName)
+	}
+	for _, fileRecord := range fileRecords {
+		name := path.Join(dir, fileRecord.FilePath)
+		if fileRecord.IsDir() {
+			if _, err := archive.Create(name + "/"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.exportFile(archive, name, volumeName, fileRecord.FilePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s packageService) exportFile(archive *zip.Writer, name, volumeName, filePath string) error {
+	reader, _, err := s.VolumeService.GetFile(volumeName, filePath)
+	if err != nil {
+		return errors.Wrapf(err, "fetch %s of volume %s", filePath, volumeName)
+	}
+	defer reader.Close()
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, reader)
+	return err
+}
+
+func writeYAML(archive *zip.Writer, name string, v interface{}) error {
+	content, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+func NewPackageService(logger *zap.Logger, Repository Repository, VolumeService volumes.Service,
+	BlueprintService blueprints.Service, RankListRepository ranklists.Repository) PackageService {
+	return &packageService{
+		logger:             logger.With(zap.String("type", "PackageService")),
+		Repository:         Repository,
+		VolumeService:      VolumeService,
+		BlueprintService:   BlueprintService,
+		RankListRepository: RankListRepository,
+	}
+}
@@ -0,0 +1,130 @@
+package problems
+
+import (
+	"archive/zip"
+	"bytes"
+	"path"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func openPackage(t *testing.T, entries map[string]string) ([]*zip.File, map[string]*zip.File) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range reader.File {
+		files[path.Clean(f.Name)] = f
+	}
+	return reader.File, files
+}
+
+func TestReadPackage(t *testing.T) {
+	entries, files := openPackage(t, map[string]string{
+		"problem.yaml": `version: 1
+name: a-plus-b
+title: A + B
+blueprint: blueprint.json
+tags: [math, implementation]
+difficulty: 800
+ranklists:
+- name: default
+  title: Default
+  metrics:
+  - key: score
+    priority: 0
+    order: dec
+`,
+		"blueprint.json":          `{"blocks":[]}`,
+		"statements/EN.yaml":      "title: A + B\ndescription: Add two numbers.\n",
+		"statements/default.yaml": "title: A + B\n",
+		"public/1.in":             "1 2\n",
+		"private/1.ans":           "3\n",
+	})
+
+	pkg, err := readPackage(entries, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.title != "A + B" || pkg.blueprint != `{"blocks":[]}` || pkg.public != publicDir || pkg.private != privateDir {
+		t.Errorf("package: got %+v", pkg)
+	}
+	if len(pkg.tags) != 2 || pkg.difficulty != 800 {
+		t.Errorf("classification: got tags %v, difficulty %d", pkg.tags, pkg.difficulty)
+	}
+	locales := map[string]string{}
+	for _, page := range pkg.pages {
+		locales[page.Locale] = page.Description
+	}
+	if len(locales) != 2 || locales["en"] != "Add two numbers." || locales[""] != "" {
+		t.Errorf("pages: got %v", locales)
+	}
+	if len(pkg.rankLists) != 1 || len(pkg.rankLists[0].Models) != 1 || pkg.rankLists[0].Models[0].Order != "dec" {
+		t.Errorf("ranklists: got %+v", pkg.rankLists)
+	}
+}
+
+func TestReadPackageMissingBlueprint(t *testing.T) {
+	entries, files := openPackage(t, map[string]string{
+		"problem.yaml": "version: 1\nblueprint: blueprint.json\n",
+	})
+	if _, err := readPackage(entries, files); err == nil {
+		t.Error("package without its blueprint is read")
+	}
+}
+
+func TestReadPackageInvalidLocale(t *testing.T) {
+	for _, statement := range []string{"statements/e.yaml", "statements/*.yaml"} {
+		entries, files := openPackage(t, map[string]string{
+			"problem.yaml": "version: 1\n",
+			statement:      "title: A + B\n",
+		})
+		if _, err := readPackage(entries, files); !errors.Is(err, ErrInvalidPackage) {
+			t.Errorf("%s: got %v, want %v", statement, err, ErrInvalidPackage)
+		}
+	}
+}
+
+func TestReadPolygonPackage(t *testing.T) {
+	entries, files := openPackage(t, map[string]string{
+		"problem.xml": `<?xml version="1.0" encoding="utf-8"?>
+<problem short-name="a-plus-b">
+  <names>
+    <name language="russian" value="А + Б"/>
+    <name language="english" value="A + B"/>
+  </names>
+</problem>`,
+		"statements/english/problem-properties.json": `{"name":"A + B","legend":"Add.","input":"Two numbers.","output":"Their sum.","notes":"","sampleTests":[{"input":"1 2\n","output":"3\n"}]}`,
+		"tests/01": "1 2\n",
+	})
+
+	pkg, err := readPolygonPackage(entries, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.title != "A + B" || pkg.public != statementsDir || pkg.private != "/" || pkg.blueprint != "" {
+		t.Errorf("package: got %+v", pkg)
+	}
+	if len(pkg.pages) != 1 || pkg.pages[0].Locale != "en" || pkg.pages[0].Description != "Add." {
+		t.Fatalf("pages: got %+v", pkg.pages)
+	}
+	if want := "```\n1 2\n```\n\n```\n3\n```"; pkg.pages[0].Example != want {
+		t.Errorf("example: got %q, want %q", pkg.pages[0].Example, want)
+	}
+}
@@ -0,0 +1,226 @@
+package problems
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrPageNotFound  = errors.New("page not found")
+	ErrInvalidLocale = errors.New("invalid locale")
+)
+
+// fallbackLocale is tried after the requested locale and its parents, before the page without
+// a locale.
+const fallbackLocale = "en"
+
+// anyLocale asks for a page in whichever locale the problem has, preferring the fallback chain
+// of the page without a locale.
+const anyLocale = "*"
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,8}(-[A-Za-z0-9]{1,8})*$`)
+
+// canonicalLocale returns the locale in its canonical case, as in zh-Hant-TW, so one locale
+// names one page. The default locale is "" for the page without a locale.
+func canonicalLocale(locale string) (string, error) {
+	if locale == defaultLocale {
+		return "", nil
+	}
+	if !localePattern.MatchString(locale) {
+		return "", errors.Wrap(ErrInvalidLocale, locale)
+	}
+	subtags := strings.Split(locale, "-")
+	subtags[0] = strings.ToLower(subtags[0])
+	for i := 1; i < len(subtags); i++ {
+		switch len(subtags[i]) {
+		case 2:
+			subtags[i] = strings.ToUpper(subtags[i])
+		case 4:
+			subtags[i] = strings.ToUpper(subtags[i][:1]) + strings.ToLower(subtags[i][1:])
+		default:
+			subtags[i] = strings.ToLower(subtags[i])
+		}
+	}
+	return strings.Join(subtags, "-"), nil
+}
+
+// localeChain returns the locales tried for a page in order: the locale, its parents by dropping
+// subtags from the end, the fallback locale and the page without a locale. The page without a
+// locale is asked for by "".
+func localeChain(locale string) []string {
+	var chain []string
+	seen := map[string]bool{}
+	add := func(locale string) {
+		if !seen[locale] {
+			seen[locale] = true
+			chain = append(chain, locale)
+		}
+	}
+	for {
+		add(locale)
+		i := strings.LastIndex(locale, "-")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	add(fallbackLocale)
+	add("")
+	return chain
+}
+
+// choosePage returns the page in the first locale of the chain having one. Any page is chosen
+// after the chain if any is set, the first in order of locale.
+func choosePage(pages []*models.Page, chain []string, any bool) *models.Page {
+	for _, locale := range chain {
+		for _, page := range pages {
+			if page.Locale == locale {
+				return page
+			}
+		}
+	}
+	if any && len(pages) > 0 {
+		return pages[0]
+	}
+	return nil
+}
+
+// GetPage returns the page of the problem in the first locale of the fallback chain of locale
+// having one, nil if there is none. The locale of the page tells which one it is. Without a
+// locale, asked for by "*", a page in any locale is returned if the chain has none.
+func (s service) GetPage(name, locale string) (*models.Page, error) {
+	any := locale == anyLocale
+	if any {
+		locale = defaultLocale
+	}
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := s.GetProblemByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if problem == nil {
+		return nil, nil
+	}
+	pages, err := s.Repository.GetPages(problem.ID)
+	if err != nil {
+		return nil, err
+	}
+	return choosePage(pages, localeChain(locale), any), nil
+}
+
+func (s service) GetPages(name string) ([]*models.Page, error) {
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repository.GetPages(problem.ID)
+}
+
+// SavePage creates or updates the page of the problem in the locale, recording a revision.
+func (s service) SavePage(accountID uint64, name, locale string, content *models.PageContent) (*models.Page, error) {
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	page, err := s.Repository.GetPage(problem.ID, locale)
+	if err != nil {
+		return nil, err
+	}
+	if page == nil {
+		page = &models.Page{
+			ProblemId: problem.ID,
+			Locale:    locale,
+		}
+	}
+	page.PageContent = *content
+	if err := s.Repository.SavePage(page); err != nil {
+		s.logger.Error("save page", zap.String("name", name), zap.String("locale", locale), zap.Error(err))
+		return nil, err
+	}
+	if err := s.Repository.CreatePageRevision(revisionOf(page, accountID)); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// DeletePage deletes the page of the problem in the locale. Its history is kept, ending in a
+// revision recording the deletion.
+func (s service) DeletePage(accountID uint64, name, locale string) error {
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return err
+	}
+	page, err := s.Repository.GetPage(problem.ID, locale)
+	if err != nil {
+		return err
+	}
+	if page == nil {
+		return ErrPageNotFound
+	}
+	if err := s.Repository.DeletePage(page); err != nil {
+		s.logger.Error("delete page", zap.String("name", name), zap.String("locale", locale), zap.Error(err))
+		return err
+	}
+	return s.Repository.CreatePageRevision(&models.PageRevision{
+		ProblemId: problem.ID,
+		Locale:    locale,
+		CreatedBy: accountID,
+		Deleted:   true,
+	})
+}
+
+// GetPageHistory returns the revisions of the page of the problem in the locale, newest first.
+func (s service) GetPageHistory(name, locale string) ([]*models.PageRevision, error) {
+	locale, err := canonicalLocale(locale)
+	if err != nil {
+		return nil, err
+	}
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.Repository.GetPageRevisions(problem.ID, locale)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, ErrPageNotFound
+	}
+	return revisions, nil
+}
+
+// problem returns the problem of the name, ErrProblemNotFound if there is none.
+func (s service) problem(name string) (*models.Problem, error) {
+	problem, err := s.Repository.GetProblemByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if problem == nil {
+		return nil, ErrProblemNotFound
+	}
+	return problem, nil
+}
+
+func revisionOf(page *models.Page, accountID uint64) *models.PageRevision {
+	return &models.PageRevision{
+		ProblemId:   page.ProblemId,
+		Locale:      page.Locale,
+		CreatedBy:   accountID,
+		PageContent: page.PageContent,
+	}
+}
@@ -0,0 +1,69 @@
+package problems
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestCanonicalLocale(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+		err    bool
+	}{
+		{locale: "zh-tw", want: "zh-TW"},
+		{locale: "ZH-hant-tw", want: "zh-Hant-TW"},
+		{locale: "en", want: "en"},
+		{locale: "default", want: ""},
+		{locale: "*", err: true},
+		{locale: "../en", err: true},
+		{locale: "e", err: true},
+	}
+	for _, tt := range tests {
+		got, err := canonicalLocale(tt.locale)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("canonicalLocale(%q) = %q, %v", tt.locale, got, err)
+		}
+	}
+}
+
+func TestLocaleChain(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{locale: "zh-Hant-TW", want: "zh-Hant-TW,zh-Hant,zh,en,"},
+		{locale: "en-US", want: "en-US,en,"},
+		{locale: "", want: ",en"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(localeChain(tt.locale), ","); got != tt.want {
+			t.Errorf("localeChain(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestChoosePage(t *testing.T) {
+	pages := []*models.Page{{Locale: "ru"}, {Locale: "zh"}}
+	tests := []struct {
+		locale string
+		any    bool
+		want   string
+	}{
+		{locale: "zh-TW", want: "zh"},
+		{locale: "ja", want: "none"},
+		{locale: "", want: "none"},
+		{locale: "", any: true, want: "ru"},
+	}
+	for _, tt := range tests {
+		got := "none"
+		if page := choosePage(pages, localeChain(tt.locale), tt.any); page != nil {
+			got = page.Locale
+		}
+		if got != tt.want {
+			t.Errorf("choosePage(%q, %v) = %s, want %s", tt.locale, tt.any, got, tt.want)
+		}
+	}
+}
@@ -12,16 +12,34 @@ func CreateInitControllersFn(pc Controller) InitProblemGroupFn {
 		r.GET("/problem", pc.GetProblems)
 		r.GET("/problem/:name", pc.GetProblem)
 		r.GET("/problem/:name/page", pc.GetPage)
+		r.GET("/problem/:name/pages", pc.GetPages)
+		r.PUT("/problem/:name/page/:locale", pc.SavePage)
+		r.DELETE("/problem/:name/page/:locale", pc.DeletePage)
+		r.GET("/problem/:name/page/:locale/history", pc.GetPageHistory)
+		r.GET("/problem/:name/attachment", pc.GetAttachments)
+		r.POST("/problem/:name/attachment", pc.CreateAttachment)
+		r.GET("/problem/:name/attachment/:filename", pc.GetAttachment)
+		r.DELETE("/problem/:name/attachment/:filename", pc.DeleteAttachment)
 		r.GET("/problem/:name/ranklist", pc.GetRankLists)
+		r.GET("/problem/:name/statistics", pc.GetStatistics)
 		r.GET("/problem/:name/ranklist/:id", pc.GetRankList)
 		r.POST("/problem", pc.CreateProblem)
-		//r.POST("/problem/:name/ranklist", pc.CreateProblem)
+		r.POST("/problem/:name/ranklist", pc.CreateRankList)
+		r.PUT("/problem/:name/ranklist/:id", pc.UpdateRankList)
+		r.DELETE("/problem/:name/ranklist/:id", pc.DeleteRankList)
 		r.PUT("/problem/:name", pc.UpdateProblem)
+		r.PUT("/problem/:name/state", pc.SetState)
+		r.GET("/problem/:name/collaborator", pc.GetCollaborators)
+		r.PUT("/problem/:name/collaborator/:account", pc.AddCollaborator)
+		r.DELETE("/problem/:name/collaborator/:account", pc.RemoveCollaborator)
+		r.GET("/problem/:name/package", pc.ExportPackage)
+		r.POST("/problem/:name/package", pc.ImportPackage)
 	}
 }
 
 var ProviderSet = wire.NewSet(CreateInitControllersFn,
 	NewController,
 	NewService,
+	NewPackageService,
 	NewRepository,
 )
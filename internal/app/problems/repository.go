@@ -1,21 +1,86 @@
 package problems
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Orders of listed problems.
+const (
+	SortByID         = "id"
+	SortByAcceptance = "acceptance"
+	SortByActivity   = "activity"
 )
 
+// ProblemQuery selects the problems listed.
+type ProblemQuery struct {
+	// All lists every problem. Otherwise the problems published by now are listed, along with
+	// the ones the account owns or collaborates on.
+	All       bool
+	AccountID uint64
+	Now       time.Time
+
+	// Keyword is searched for in the name and title of problems, and in the text of their pages.
+	Keyword string
+	// Tags are all required of a listed problem.
+	Tags []string
+	// MinDifficulty and MaxDifficulty bound the difficulty, zero for no bound.
+	MinDifficulty int
+	MaxDifficulty int
+	// Solved lists only the problems the account has, or has not, an accepted submission to.
+	Solved *bool
+
+	Sort string
+	Desc bool
+}
+
+// totalJoin joins a total counter of each problem, the same the statistics of the problem show.
+const totalJoin = "LEFT JOIN problem_counters AS %[1]s ON %[1]s.problem_id = problems.id AND %[1]s.kind = ? AND %[1]s.name = ?"
+
+const solvedCondition = `EXISTS (
+	SELECT 1 FROM submissions
+	JOIN judgements ON judgements.submission_id = submissions.id
+	WHERE submissions.problem_id = problems.id AND submissions.submitter_id = ? AND judgements.status = 'Accepted'
+)`
+
+const keywordCondition = `problems.name ILIKE ? OR problems.title ILIKE ? OR EXISTS (
+	SELECT 1 FROM pages
+	WHERE pages.problem_id = problems.id AND to_tsvector('simple', concat_ws(' ',
+		pages.title, pages.description, pages.input_format, pages.output_format, pages.hint_and_limit
+	)) @@ plainto_tsquery('simple', ?)
+)`
+
 type Repository interface {
-	CreateProblem(name, title string) (p *models.Problem, err error)
+	CreateProblem(ownerID uint64, name, title string) (p *models.Problem, err error)
+	ImportProblem(accountID uint64, problem *models.Problem, pages []*models.Page, rankLists []*models.RankList) error
 	UpdateProblem(p *models.Problem) error
 	CreatePage(problemId uint64, locale, title, description string) (p *models.Page, err error)
 	GetPage(problemId uint64, locale string) (p *models.Page, err error)
+	GetPages(problemId uint64) ([]*models.Page, error)
+	SavePage(page *models.Page) error
+	DeletePage(page *models.Page) error
+	CreatePageRevision(revision *models.PageRevision) error
+	GetPageRevisions(problemId uint64, locale string) ([]*models.PageRevision, error)
 
 	GetProblemById(id uint64) (*models.Problem, error)
 	GetProblemByName(name string) (p *models.Problem, err error)
-	GetProblems(offset, limit int) (p []*models.Problem, err error)
+	GetProblems(query *ProblemQuery, offset, limit int) (p []*models.Problem, total int64, err error)
+
+	GetCollaborators(problemId uint64) ([]*models.Account, error)
+	IsCollaborator(problemId, accountId uint64) (bool, error)
+	AddCollaborator(problemId, accountId uint64) error
+	RemoveCollaborator(problemId, accountId uint64) (bool, error)
+	GetAccountByName(name string) (*models.Account, error)
+
+	RecordJudgement(problemId, accountId uint64, status models.JudgeStatus, score float64, at time.Time) error
+	GetCounters(problemId uint64, since string) ([]*models.ProblemCounter, error)
 
 	CountProblems() (count int64)
 }
@@ -25,12 +90,10 @@ type DefaultRepository struct {
 	db     *gorm.DB
 }
 
+// GetPage returns the page of the problem in the locale, "" for the page without a locale.
 func (m DefaultRepository) GetPage(problemId uint64, locale string) (p *models.Page, err error) {
-	if locale == "*" {
-		locale = ""
-	}
 	p = &models.Page{}
-	if err = m.db.Where(&models.Page{ProblemId: problemId, Locale: locale}).First(p).Error; err != nil {
+	if err = m.db.Where("problem_id = ? AND locale = ?", problemId, locale).First(p).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -40,6 +103,57 @@ func (m DefaultRepository) GetPage(problemId uint64, locale string) (p *models.P
 	return p, nil
 }
 
+func (m DefaultRepository) GetPages(problemId uint64) (pages []*models.Page, err error) {
+	if err = m.db.Where(&models.Page{ProblemId: problemId}).Order("locale").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+func (m DefaultRepository) SavePage(page *models.Page) error {
+	return m.db.Save(page).Error
+}
+
+func (m DefaultRepository) DeletePage(page *models.Page) error {
+	return m.db.Delete(page).Error
+}
+
+// pageRevisionAttempts bounds how often a revision is renumbered as concurrent saves of the page
+// took its number.
+const pageRevisionAttempts = 5
+
+// CreatePageRevision numbers the revision after the latest one of the page and creates it.
+func (m DefaultRepository) CreatePageRevision(revision *models.PageRevision) error {
+	for attempt := 0; attempt < pageRevisionAttempts; attempt++ {
+		var latest int
+		if err := m.db.Model(&models.PageRevision{}).
+			Where("problem_id = ? AND locale = ?", revision.ProblemId, revision.Locale).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		revision.Revision = latest + 1
+		result := m.db.Clauses(clause.OnConflict{DoNothing: true}).Create(revision)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+	}
+	return errors.Errorf("page revision of problem %d in locale %q taken %d times",
+		revision.ProblemId, revision.Locale, pageRevisionAttempts)
+}
+
+func (m DefaultRepository) GetPageRevisions(problemId uint64, locale string) (revisions []*models.PageRevision, err error) {
+	if err = m.db.Where("problem_id = ? AND locale = ?", problemId, locale).
+		Order("revision desc").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
 func (m DefaultRepository) GetProblemById(id uint64) (p *models.Problem, err error) {
 	p = &models.Problem{}
 	if err = m.db.First(p, id).Error; err != nil {
@@ -62,11 +176,169 @@ func (m DefaultRepository) CountProblems() (count int64) {
 	return
 }
 
-func (m DefaultRepository) GetProblems(offset, limit int) (p []*models.Problem, err error) {
-	if err = m.db.Table("problems").Limit(limit).Offset(offset).Find(&p).Error; err != nil {
+// GetProblems returns a page of the problems selected by the query, and how many there are.
+func (m DefaultRepository) GetProblems(query *ProblemQuery, offset, limit int) (p []*models.Problem, total int64, err error) {
+	if err = m.filterProblems(query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	db := m.filterProblems(query).Select("problems.*")
+	direction := "ASC"
+	if query.Desc {
+		direction = "DESC"
+	}
+	switch query.Sort {
+	case SortByAcceptance:
+		db = db.Joins(fmt.Sprintf(totalJoin, "submitted"), counterTotal, totalSubmissions).
+			Joins(fmt.Sprintf(totalJoin, "accepted"), counterTotal, totalAccepted).
+			Order("COALESCE(accepted.count::float / NULLIF(submitted.count, 0), 0) " + direction)
+	case SortByActivity:
+		// the counter is updated as the latest judgement of the problem finishes
+		db = db.Joins(fmt.Sprintf(totalJoin, "submitted"), counterTotal, totalSubmissions).
+			Order("GREATEST(submitted.updated_at, problems.updated_at) " + direction)
+	}
+	db = db.Order("problems.id " + direction)
+
+	p = []*models.Problem{}
+	if err = db.Limit(limit).Offset(offset).Find(&p).Error; err != nil {
+		return nil, 0, err
+	}
+	return p, total, nil
+}
+
+func (m DefaultRepository) filterProblems(query *ProblemQuery) *gorm.DB {
+	db := m.db.Model(&models.Problem{})
+	if !query.All {
+		visible := m.db.Where("problems.state = ? AND (problems.publish_at IS NULL OR problems.publish_at <= ?)",
+			models.ProblemPublished, query.Now)
+		if query.AccountID != 0 {
+			visible = visible.
+				Or("problems.owner_id = ?", query.AccountID).
+				Or("problems.id IN (?)", m.db.Model(&models.ProblemCollaborator{}).
+					Select("problem_id").
+					Where("account_id = ?", query.AccountID))
+		}
+		db = db.Where(visible)
+	}
+	if query.Keyword != "" {
+		pattern := "%" + likeEscaper.Replace(query.Keyword) + "%"
+		db = db.Where(keywordCondition, pattern, pattern, query.Keyword)
+	}
+	for _, tag := range query.Tags {
+		db = db.Where("EXISTS (SELECT 1 FROM json_array_elements_text(problems.tags) AS tag WHERE tag = ?)", tag)
+	}
+	if query.MinDifficulty > 0 {
+		db = db.Where("problems.difficulty >= ?", query.MinDifficulty)
+	}
+	if query.MaxDifficulty > 0 {
+		db = db.Where("problems.difficulty <= ?", query.MaxDifficulty)
+	}
+	if query.Solved != nil {
+		if *query.Solved {
+			db = db.Where(solvedCondition, query.AccountID)
+		} else {
+			db = db.Where("NOT "+solvedCondition, query.AccountID)
+		}
+	}
+	return db
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (m DefaultRepository) GetCollaborators(problemId uint64) (accounts []*models.Account, err error) {
+	if err = m.db.Model(&models.Account{}).
+		Joins("JOIN problem_collaborators ON problem_collaborators.account_id = accounts.id").
+		Where("problem_collaborators.problem_id = ?", problemId).
+		Order("accounts.name").
+		Find(&accounts).Error; err != nil {
 		return nil, err
 	}
-	return
+	return accounts, nil
+}
+
+func (m DefaultRepository) IsCollaborator(problemId, accountId uint64) (bool, error) {
+	var count int64
+	if err := m.db.Model(&models.ProblemCollaborator{}).
+		Where("problem_id = ? AND account_id = ?", problemId, accountId).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m DefaultRepository) AddCollaborator(problemId, accountId uint64) error {
+	return m.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProblemCollaborator{
+		ProblemID: problemId,
+		AccountID: accountId,
+	}).Error
+}
+
+func (m DefaultRepository) RemoveCollaborator(problemId, accountId uint64) (bool, error) {
+	result := m.db.Where("problem_id = ? AND account_id = ?", problemId, accountId).
+		Delete(&models.ProblemCollaborator{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RecordJudgement counts a judgement of a submission the account made to the problem into the
+// statistics of the problem. Counters are added to in the database, so judgements finishing at
+// the same time are all counted.
+func (m DefaultRepository) RecordJudgement(problemId, accountId uint64, status models.JudgeStatus, score float64, at time.Time) error {
+	counters := judgementCounters(status, score, at)
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if status == models.Accepted {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ProblemSolver{
+				ProblemID: problemId,
+				AccountID: accountId,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				counters = append(counters, Counter{Kind: counterTotal, Name: totalSolvers})
+			}
+		}
+		for _, counter := range counters {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "problem_id"}, {Name: "kind"}, {Name: "name"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"count":      gorm.Expr("problem_counters.count + 1"),
+					"updated_at": gorm.Expr("excluded.updated_at"),
+				}),
+			}).Create(&models.ProblemCounter{
+				ProblemID: problemId,
+				Kind:      counter.Kind,
+				Name:      counter.Name,
+				Count:     1,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetCounters returns the counters of the problem, leaving out days before since.
+func (m DefaultRepository) GetCounters(problemId uint64, since string) (counters []*models.ProblemCounter, err error) {
+	if err = m.db.Where("problem_id = ?", problemId).
+		Where("kind NOT IN ? OR name >= ?", []string{counterDay, counterDayAccepted}, since).
+		Find(&counters).Error; err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+func (m DefaultRepository) GetAccountByName(name string) (*models.Account, error) {
+	account := &models.Account{}
+	if err := m.db.Where(&models.Account{Name: name}).First(account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return account, nil
 }
 
 func (m DefaultRepository) GetProblemByName(name string) (p *models.Problem, err error) {
@@ -82,10 +354,13 @@ func (m DefaultRepository) GetProblemByName(name string) (p *models.Problem, err
 	return
 }
 
-func (m DefaultRepository) CreateProblem(name, title string) (problem *models.Problem, err error) {
+// CreateProblem creates a draft problem owned by the account.
+func (m DefaultRepository) CreateProblem(ownerID uint64, name, title string) (problem *models.Problem, err error) {
 	problem = &models.Problem{
-		Name:  name,
-		Title: title,
+		Name:    name,
+		Title:   title,
+		State:   models.ProblemDraft,
+		OwnerID: ownerID,
 	}
 	if err = m.db.Create(problem).Error; err != nil {
 		m.logger.Error("create problem", zap.String("name", name))
@@ -95,16 +370,46 @@ func (m DefaultRepository) CreateProblem(name, title string) (problem *models.Pr
 	return problem, nil
 }
 
+// ImportProblem creates the problem with its pages, their first revisions made by the account,
+// and its ranklists at once, so a failed import leaves nothing behind.
+func (m DefaultRepository) ImportProblem(accountID uint64, problem *models.Problem, pages []*models.Page, rankLists []*models.RankList) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(problem).Error; err != nil {
+			return err
+		}
+		for _, page := range pages {
+			page.ProblemId = problem.ID
+			if err := tx.Create(page).Error; err != nil {
+				return err
+			}
+			revision := revisionOf(page, accountID)
+			revision.Revision = 1
+			if err := tx.Create(revision).Error; err != nil {
+				return err
+			}
+		}
+		for _, rankList := range rankLists {
+			rankList.ProblemID = problem.ID
+			if err := tx.Omit("Records").Create(rankList).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (m DefaultRepository) CreatePage(problemId uint64, locale, title, description string) (page *models.Page, err error) {
 	m.logger.Debug("create page",
 		zap.Uint64("problemId", problemId),
 		zap.String("locale", locale),
 	)
 	page = &models.Page{
-		ProblemId:   problemId,
-		Locale:      locale,
-		Title:       title,
-		Description: description,
+		ProblemId: problemId,
+		Locale:    locale,
+		PageContent: models.PageContent{
+			Title:       title,
+			Description: description,
+		},
 	}
 	if err = m.db.Create(page).Error; err != nil {
 		m.logger.Error("create page",
@@ -1,35 +1,46 @@
 package problems
 
 import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	volumes "github.com/infinity-oj/server-v2/internal/app/volumes/services"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"go.uber.org/zap"
 )
 
 type Service interface {
-	CreateProblem(name, title string) (p *models.Problem, err error)
+	CreateProblem(ownerID uint64, name, title string) (p *models.Problem, err error)
 	UpdateProblem(p *models.Problem, name, title, publicVolume, privateVolume string) (*models.Problem, error)
 	GetProblemById(id uint64) (p *models.Problem, err error)
 	GetProblemByName(name string) (p *models.Problem, err error)
-	GetProblems(page, pageSize int) (res []*models.Problem, err error)
+	GetProblems(principal *Principal, query *ProblemQuery, page, pageSize int) (res []*models.Problem, total int64, err error)
+
+	GetAccess(name string, principal *Principal) (*models.Problem, Access, error)
+	SetState(principal *Principal, p *models.Problem, state string, publishAt *time.Time) (*models.Problem, error)
+	GetCollaborators(name string) ([]*models.Account, error)
+	AddCollaborator(name, accountName string) error
+	RemoveCollaborator(name, accountName string) error
+	GetStatistics(name string, days int) (*Statistics, error)
 
 	GetPage(name, locale string) (p *models.Page, err error)
-}
+	GetPages(name string) ([]*models.Page, error)
+	SavePage(accountID uint64, name, locale string, content *models.PageContent) (*models.Page, error)
+	DeletePage(accountID uint64, name, locale string) error
+	GetPageHistory(name, locale string) ([]*models.PageRevision, error)
 
-type service struct {
-	logger     *zap.Logger
-	Repository Repository
+	GetAttachments(name string) (models.FileRecords, error)
+	GetAttachment(name, filename string) (io.ReadCloser, int64, error)
+	AddAttachment(accountID uint64, name, filename string, file io.Reader) (*models.Problem, error)
+	RemoveAttachment(name, filename string) (*models.Problem, error)
 }
 
-func (s service) GetPage(name, locale string) (p *models.Page, err error) {
-	problem, err := s.GetProblemByName(name)
-	if err != nil {
-		return nil, err
-	}
-	page, err := s.Repository.GetPage(problem.ID, locale)
-	if err != nil {
-		return nil, err
-	}
-	return page, nil
+type service struct {
+	logger        *zap.Logger
+	Repository    Repository
+	VolumeService volumes.Service
 }
 
 func (s service) GetProblemById(id uint64) (p *models.Problem, err error) {
@@ -42,6 +53,7 @@ func (s service) UpdateProblem(p *models.Problem, name, title, publicVolume, pri
 	p.Title = title
 	p.PublicVolume = publicVolume
 	p.PrivateVolume = privateVolume
+	p.Tags = normalizeTags(p.Tags)
 	if err := s.Repository.UpdateProblem(p); err != nil {
 		s.logger.Error("update problem",
 			zap.String("name", p.Name),
@@ -54,31 +66,54 @@ func (s service) UpdateProblem(p *models.Problem, name, title, publicVolume, pri
 	return p, nil
 }
 
-func (s service) GetProblems(page, pageSize int) (res []*models.Problem, err error) {
+// GetProblems lists the problems of the query the principal may read, without archived ones
+// unless the principal may edit them, and returns how many there are in all pages. Whether the
+// problems are solved is up to the account of the principal.
+func (s service) GetProblems(principal *Principal, query *ProblemQuery, page, pageSize int) (res []*models.Problem, total int64, err error) {
 	offset := (page - 1) * pageSize
-	res, err = s.Repository.GetProblems(offset, pageSize)
+	query.All = principal.HasRole(models.RoleAdmin)
+	query.AccountID = principal.AccountID
+	query.Now = time.Now()
+	query.Tags = normalizeTags(query.Tags)
+	res, total, err = s.Repository.GetProblems(query, offset, pageSize)
 	if err != nil {
 		s.logger.Error("get problems", zap.Int("page", page), zap.Int("pageSize", pageSize), zap.Error(err))
-		return nil, err
+		return nil, 0, err
 	}
 	return
 }
 
+// normalizeTags returns the tags trimmed, in lower case, sorted and without duplicates.
+func normalizeTags(tags []string) models.Tags {
+	seen := map[string]bool{}
+	normalized := models.Tags{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
 func (s service) GetProblemByName(name string) (p *models.Problem, err error) {
 	p, err = s.Repository.GetProblemByName(name)
 	return
 }
 
-func (s service) CreateProblem(name, title string) (p *models.Problem, err error) {
-	if p, err = s.Repository.CreateProblem(name, title); err != nil {
+func (s service) CreateProblem(ownerID uint64, name, title string) (p *models.Problem, err error) {
+	if p, err = s.Repository.CreateProblem(ownerID, name, title); err != nil {
 		return p, err
 	}
 	return
 }
 
-func NewService(logger *zap.Logger, Repository Repository) Service {
+func NewService(logger *zap.Logger, Repository Repository, VolumeService volumes.Service) Service {
 	return &service{
-		logger:     logger.With(zap.String("type", "ProblemService")),
-		Repository: Repository,
+		logger:        logger.With(zap.String("type", "ProblemService")),
+		Repository:    Repository,
+		VolumeService: VolumeService,
 	}
 }
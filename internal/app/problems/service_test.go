@@ -0,0 +1,16 @@
+package problems
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags := normalizeTags([]string{" Greedy", "dp", "", "greedy", "Math "})
+	if got := strings.Join(tags, ","); got != "dp,greedy,math" {
+		t.Errorf("got %q", got)
+	}
+	if tags := normalizeTags(nil); tags == nil || len(tags) != 0 {
+		t.Errorf("no tags: got %#v", tags)
+	}
+}
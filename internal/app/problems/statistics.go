@@ -0,0 +1,159 @@
+package problems
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+// Kinds of problem counters, and the names of the totals.
+const (
+	counterTotal       = "total"
+	counterVerdict     = "verdict"
+	counterScore       = "score"
+	counterDay         = "day"
+	counterDayAccepted = "day-accepted"
+
+	totalSubmissions = "submissions"
+	totalAccepted    = "accepted"
+	totalSolvers     = "solvers"
+
+	dayLayout = "2006-01-02"
+	// scoreBucket is the width of the score histogram buckets, full marks have their own.
+	scoreBucket = 10
+	fullScore   = 100
+)
+
+// Counter names a problem counter.
+type Counter struct {
+	Kind string
+	Name string
+}
+
+// Statistics summarizes the judged submissions to a problem. A rejudged submission counts once
+// for every judgement, canceled judgements are not counted.
+type Statistics struct {
+	Submissions    int64            `json:"submissions"`
+	Accepted       int64            `json:"accepted"`
+	Solvers        int64            `json:"solvers"`
+	AcceptanceRate float64          `json:"acceptance_rate"`
+	Verdicts       map[string]int64 `json:"verdicts"`
+	Scores         []*ScoreBucket   `json:"scores"`
+	Daily          []*DailyCount    `json:"daily"`
+}
+
+// ScoreBucket counts the judgements scoring at least Min and less than Max, or exactly Max for
+// full marks.
+type ScoreBucket struct {
+	Min   int   `json:"min"`
+	Max   int   `json:"max"`
+	Count int64 `json:"count"`
+}
+
+// DailyCount counts the judgements finished on a day, in UTC.
+type DailyCount struct {
+	Day         string `json:"day"`
+	Submissions int64  `json:"submissions"`
+	Accepted    int64  `json:"accepted"`
+}
+
+// judgementCounters returns the counters a judgement finished at the time adds one to, besides
+// the solvers which depend on the account.
+func judgementCounters(status models.JudgeStatus, score float64, at time.Time) []Counter {
+	day := at.UTC().Format(dayLayout)
+	counters := []Counter{
+		{Kind: counterTotal, Name: totalSubmissions},
+		{Kind: counterVerdict, Name: string(status)},
+		{Kind: counterDay, Name: day},
+	}
+	if score >= 0 {
+		counters = append(counters, Counter{Kind: counterScore, Name: fmt.Sprint(scoreBucketOf(score))})
+	}
+	if status == models.Accepted {
+		counters = append(counters,
+			Counter{Kind: counterTotal, Name: totalAccepted},
+			Counter{Kind: counterDayAccepted, Name: day},
+		)
+	}
+	return counters
+}
+
+// scoreBucketOf returns the lower bound of the bucket of the score.
+func scoreBucketOf(score float64) int {
+	if score >= fullScore {
+		return fullScore
+	}
+	return int(score) / scoreBucket * scoreBucket
+}
+
+// statistics assembles the statistics from the counters, with a day by day series of the days
+// up to today.
+func statistics(counters []*models.ProblemCounter, days int, today time.Time) *Statistics {
+	stats := &Statistics{
+		Verdicts: map[string]int64{},
+	}
+	buckets := map[string]*ScoreBucket{}
+	for min := 0; min <= fullScore; min += scoreBucket {
+		bucket := &ScoreBucket{Min: min, Max: min + scoreBucket}
+		if min == fullScore {
+			bucket.Max = fullScore
+		}
+		buckets[fmt.Sprint(min)] = bucket
+		stats.Scores = append(stats.Scores, bucket)
+	}
+	daily := map[string]*DailyCount{}
+	start := today.UTC().AddDate(0, 0, 1-days)
+	for i := 0; i < days; i++ {
+		count := &DailyCount{Day: start.AddDate(0, 0, i).Format(dayLayout)}
+		daily[count.Day] = count
+		stats.Daily = append(stats.Daily, count)
+	}
+
+	for _, counter := range counters {
+		switch counter.Kind {
+		case counterTotal:
+			switch counter.Name {
+			case totalSubmissions:
+				stats.Submissions = counter.Count
+			case totalAccepted:
+				stats.Accepted = counter.Count
+			case totalSolvers:
+				stats.Solvers = counter.Count
+			}
+		case counterVerdict:
+			stats.Verdicts[counter.Name] = counter.Count
+		case counterScore:
+			if bucket, ok := buckets[counter.Name]; ok {
+				bucket.Count = counter.Count
+			}
+		case counterDay:
+			if count, ok := daily[counter.Name]; ok {
+				count.Submissions = counter.Count
+			}
+		case counterDayAccepted:
+			if count, ok := daily[counter.Name]; ok {
+				count.Accepted = counter.Count
+			}
+		}
+	}
+	if stats.Submissions > 0 {
+		stats.AcceptanceRate = float64(stats.Accepted) / float64(stats.Submissions)
+	}
+	return stats
+}
+
+// GetStatistics returns the statistics of the problem, with the submissions of the last days.
+func (s service) GetStatistics(name string, days int) (*Statistics, error) {
+	problem, err := s.problem(name)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now()
+	since := today.UTC().AddDate(0, 0, 1-days).Format(dayLayout)
+	counters, err := s.Repository.GetCounters(problem.ID, since)
+	if err != nil {
+		return nil, err
+	}
+	return statistics(counters, days, today), nil
+}
@@ -0,0 +1,46 @@
+package problems
+
+import (
+	"testing"
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestStatistics(t *testing.T) {
+	today := time.Date(2021, 3, 2, 12, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	// counters as the database adds them up
+	totals := map[Counter]int64{}
+	record := func(status models.JudgeStatus, score float64, at time.Time) {
+		for _, counter := range judgementCounters(status, score, at) {
+			totals[counter]++
+		}
+	}
+	record(models.Accepted, 100, today)
+	record(models.WrongAnswer, 0, today)
+	record(models.PartiallyCorrect, 45.5, yesterday)
+	record(models.SystemError, -1, today)
+	totals[Counter{Kind: counterTotal, Name: totalSolvers}] = 1
+
+	var counters []*models.ProblemCounter
+	for counter, count := range totals {
+		counters = append(counters, &models.ProblemCounter{Kind: counter.Kind, Name: counter.Name, Count: count})
+	}
+	stats := statistics(counters, 2, today)
+
+	if stats.Submissions != 4 || stats.Accepted != 1 || stats.Solvers != 1 || stats.AcceptanceRate != 0.25 {
+		t.Errorf("totals: got %+v", stats)
+	}
+	if stats.Verdicts[string(models.SystemError)] != 1 || len(stats.Verdicts) != 4 {
+		t.Errorf("verdicts: got %v", stats.Verdicts)
+	}
+	if len(stats.Scores) != 11 || stats.Scores[0].Count != 1 || stats.Scores[4].Count != 1 || stats.Scores[10].Count != 1 {
+		t.Errorf("scores: got %+v", stats.Scores)
+	}
+	if len(stats.Daily) != 2 || stats.Daily[0].Day != "2021-03-01" || stats.Daily[0].Submissions != 1 ||
+		stats.Daily[1].Submissions != 3 || stats.Daily[1].Accepted != 1 {
+		t.Errorf("daily: got %+v, %+v", stats.Daily[0], stats.Daily[1])
+	}
+}
@@ -3,6 +3,7 @@ package processes
 import (
 	"net/http"
 
+	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
 	"github.com/infinity-oj/server-v2/pkg/models"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +16,8 @@ type Controller interface {
 	GetProcess(c *gin.Context)
 	UpdateProcess(c *gin.Context)
 	ReserveProcess(c *gin.Context)
+	InvalidateCache(c *gin.Context)
+	InvalidateCacheKey(c *gin.Context)
 }
 
 type DefaultController struct {
@@ -154,6 +157,70 @@ func (d *DefaultController) ReserveProcess(c *gin.Context) {
 	})
 }
 
+func (d *DefaultController) InvalidateCache(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !session.HasRole(models.RoleAdmin) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	processType := c.Query("type")
+	d.logger.Debug("invalidate process cache",
+		zap.Uint64("account id", session.AccountId),
+		zap.String("type", processType),
+	)
+
+	count, err := d.service.InvalidateCache(processType)
+	if err != nil {
+		d.logger.Error("invalidate process cache", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"count": count,
+	})
+}
+
+func (d *DefaultController) InvalidateCacheKey(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !session.HasRole(models.RoleAdmin) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	key := c.Param("key")
+	d.logger.Debug("invalidate process cache",
+		zap.Uint64("account id", session.AccountId),
+		zap.String("key", key),
+	)
+
+	found, err := d.service.InvalidateCacheKey(key)
+	if err != nil {
+		d.logger.Error("invalidate process cache", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+	if !found {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func NewController(logger *zap.Logger, s Service) Controller {
 	return &DefaultController{
 		logger:  logger,
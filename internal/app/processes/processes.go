@@ -13,6 +13,8 @@ func CreateInitControllersFn(pc Controller) InitProcessGroupFn {
 		processGroup.GET("/", pc.GetProcesses)
 		processGroup.POST("/:processId/reservation", pc.ReserveProcess)
 		processGroup.PUT("/:processId", pc.UpdateProcess)
+		processGroup.DELETE("/cache", pc.InvalidateCache)
+		processGroup.DELETE("/cache/:key", pc.InvalidateCacheKey)
 	}
 }
 
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/infinity-oj/server-v2/internal/lib/cache"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 
 	"github.com/google/uuid"
@@ -16,12 +17,16 @@ type Service interface {
 	GetProcess(processId string) (process *models.Process, err error)
 	UpdateProcess(processId, warning, error string, outputs *models.Slots) (process *models.Process, err error)
 	ReserveProcess(processId string) (token string, locked bool, err error)
+
+	InvalidateCache(processType string) (count int64, err error)
+	InvalidateCacheKey(key string) (found bool, err error)
 }
 
 type service struct {
 	logger *zap.Logger
 
 	manager manager.ProcessManager
+	cache   *cache.Cache
 }
 
 func (d service) GetProcesses(processType string) (processes []*models.Process, err error) {
@@ -134,10 +139,21 @@ func (d service) ReserveProcess(processId string) (token string, locked bool, er
 	return token, true, nil
 }
 
-func NewService(logger *zap.Logger, manager manager.ProcessManager) Service {
+func (d service) InvalidateCache(processType string) (count int64, err error) {
+	d.logger.Info("invalidate process cache", zap.String("type", processType))
+	return d.cache.Invalidate(processType)
+}
+
+func (d service) InvalidateCacheKey(key string) (found bool, err error) {
+	d.logger.Info("invalidate process cache", zap.String("key", key))
+	return d.cache.InvalidateKey(key)
+}
+
+func NewService(logger *zap.Logger, manager manager.ProcessManager, cache *cache.Cache) Service {
 	return &service{
 		logger: logger.With(zap.String("type", "Process service")),
 
 		manager: manager,
+		cache:   cache,
 	}
 }
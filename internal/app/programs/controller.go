@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/validator/v10"
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
+	"github.com/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -50,6 +51,18 @@ func (pc *DefaultController) CreateProgram(c *gin.Context) {
 		return
 	}
 	problem, err := pc.service.CreateProgram(request.Definition)
+	if errors.Is(err, ErrInvalidDefinition) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, ErrBuiltinProgram) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.AbortWithStatusJSON(http.StatusInternalServerError, &gin.H{
 			"message": err.Error(),
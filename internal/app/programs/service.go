@@ -1,10 +1,20 @@
 package programs
 
 import (
+	"encoding/json"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+var (
+	ErrInvalidDefinition = errors.New("invalid program definition")
+	ErrBuiltinProgram    = errors.New("program name is taken by a built-in")
+)
+
 type Service interface {
 	CreateProgram(definition string) (p *models.Program, err error)
 	GetProgram(id uint64) (p *models.Program, err error)
@@ -16,16 +26,47 @@ type service struct {
 	Repository Repository
 }
 
+// GetPrograms returns the programs followed by the built-in handlers. Programs stored before
+// names of built-ins were rejected are left out, as the built-in is what blueprints run.
 func (s service) GetPrograms() (p []*models.Program, err error) {
 	s.logger.Debug("get programs")
-	p, err = s.Repository.GetPrograms()
+	programs, err := s.Repository.GetPrograms()
+	if err != nil {
+		return nil, err
+	}
+	for _, program := range programs {
+		if b := scene.NewBlockDefinition(program.Definition); b != nil && buildins.Definition(b.Name) != nil {
+			continue
+		}
+		p = append(p, program)
+	}
+	for _, definition := range buildins.Definitions() {
+		bytes, err := json.Marshal(definition)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, &models.Program{
+			Name:        definition.Name,
+			Title:       definition.Title,
+			Description: definition.Description,
+			Definition:  string(bytes),
+		})
+	}
 	return
 }
 
+// CreateProgram stores the block definition, unless it is named after a built-in handler.
 func (s service) CreateProgram(definition string) (p *models.Program, err error) {
 	s.logger.Debug("create program",
 		zap.String("definition", definition),
 	)
+	b := scene.NewBlockDefinition(definition)
+	if b == nil {
+		return nil, ErrInvalidDefinition
+	}
+	if buildins.Definition(b.Name) != nil {
+		return nil, errors.Wrapf(ErrBuiltinProgram, "program %s", b.Name)
+	}
 	if p, err = s.Repository.CreateProgram(definition); err != nil {
 		return p, err
 	}
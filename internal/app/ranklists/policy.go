@@ -0,0 +1,55 @@
+package ranklists
+
+import (
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+)
+
+// Policy decides which record is kept when an account already has one for a ranklist key.
+type Policy string
+
+const (
+	// PolicyBest keeps the better value according to the order of the ranklist model of the key.
+	PolicyBest Policy = "best"
+	// PolicyLatest keeps the latest record.
+	PolicyLatest Policy = "latest"
+	// PolicyFirstAccepted keeps the first accepted record, and the latest one until then.
+	PolicyFirstAccepted Policy = "first_accepted"
+)
+
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case "":
+		return PolicyBest, nil
+	case PolicyBest, PolicyLatest, PolicyFirstAccepted:
+		return p, nil
+	}
+	return "", errors.Errorf("unknown ranklist policy %s", s)
+}
+
+// Replace reports whether record should replace old in the ranklist.
+// A record of the same submission always replaces the old one, so re-judges take effect.
+func (p Policy) Replace(rl *models.RankList, old, record *models.RankListRecord) bool {
+	if old == nil || (record.SubmissionID != 0 && old.SubmissionID == record.SubmissionID) {
+		return true
+	}
+
+	switch p {
+	case PolicyLatest:
+		return true
+	case PolicyFirstAccepted:
+		return !old.Accepted
+	}
+
+	order := "dec"
+	for _, m := range rl.Models {
+		if m.Key == record.Key {
+			order = m.Order
+			break
+		}
+	}
+	if ascending(order) {
+		return record.Value < old.Value
+	}
+	return record.Value > old.Value
+}
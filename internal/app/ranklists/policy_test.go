@@ -0,0 +1,51 @@
+package ranklists
+
+import (
+	"testing"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestPolicyReplace(t *testing.T) {
+	type test struct {
+		name   string
+		policy Policy
+		order  string
+		old    *models.RankListRecord
+		record *models.RankListRecord
+		want   bool
+	}
+
+	tests := []test{
+		{name: "no record", policy: PolicyBest, record: &models.RankListRecord{Value: 1}, want: true},
+		{name: "best higher", policy: PolicyBest, order: "dec",
+			old: &models.RankListRecord{Value: 60}, record: &models.RankListRecord{Value: 80}, want: true},
+		{name: "best lower", policy: PolicyBest, order: "dec",
+			old: &models.RankListRecord{Value: 80}, record: &models.RankListRecord{Value: 60}},
+		{name: "best equal keeps old", policy: PolicyBest,
+			old: &models.RankListRecord{Value: 80}, record: &models.RankListRecord{Value: 80}},
+		{name: "best inc", policy: PolicyBest, order: "inc",
+			old: &models.RankListRecord{Value: 80}, record: &models.RankListRecord{Value: 60}, want: true},
+		{name: "rejudge", policy: PolicyBest, order: "dec",
+			old: &models.RankListRecord{Value: 80, SubmissionID: 3}, record: &models.RankListRecord{Value: 60, SubmissionID: 3}, want: true},
+		{name: "latest", policy: PolicyLatest,
+			old: &models.RankListRecord{Value: 80}, record: &models.RankListRecord{Value: 60}, want: true},
+		{name: "first accepted kept", policy: PolicyFirstAccepted,
+			old: &models.RankListRecord{Accepted: true}, record: &models.RankListRecord{Accepted: true}},
+		{name: "first accepted replaces rejected", policy: PolicyFirstAccepted,
+			old: &models.RankListRecord{}, record: &models.RankListRecord{Accepted: true}, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rl := &models.RankList{}
+			if tc.order != "" {
+				rl.Models = []models.RankListModel{{Key: "score", Order: tc.order}}
+			}
+			tc.record.Key = "score"
+			if got := tc.policy.Replace(rl, tc.old, tc.record); got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package ranklists
+
+import (
+	"sort"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+// Ranking is a ranklist with its accounts ranked by the metrics.
+type Ranking struct {
+	RankList *models.RankList `json:"ranklist"`
+	Rows     []*RankingRow    `json:"rows"`
+}
+
+// RankingRow is the place of an account in a ranking. Accounts equal in every metric share a
+// rank, and the next rank is skipped for each of them.
+type RankingRow struct {
+	Rank    int                `json:"rank"`
+	Account models.Account     `json:"account"`
+	Values  map[string]float64 `json:"values"`
+
+	accountID uint64
+}
+
+// ascending reports whether lower values of a metric rank higher.
+func ascending(order string) bool {
+	return order == "inc" || order == "asc"
+}
+
+// rank orders the accounts with records in the ranklist by its metrics, the metric with the
+// lowest priority first. Accounts without a record of a metric rank after those with one.
+func rank(rl *models.RankList) []*RankingRow {
+	metrics := append([]models.RankListModel(nil), rl.Models...)
+	sort.SliceStable(metrics, func(i, j int) bool {
+		return metrics[i].Priority < metrics[j].Priority
+	})
+
+	rowsByAccount := map[uint64]*RankingRow{}
+	rows := []*RankingRow{}
+	for _, record := range rl.Records {
+		row, ok := rowsByAccount[record.AccountID]
+		if !ok {
+			row = &RankingRow{
+				Account:   record.Account,
+				Values:    map[string]float64{},
+				accountID: record.AccountID,
+			}
+			rowsByAccount[record.AccountID] = row
+			rows = append(rows, row)
+		}
+		row.Values[record.Key] = record.Value
+	}
+
+	// compare returns a negative number if a ranks higher than b, zero if they are equal
+	compare := func(a, b *RankingRow) int {
+		for _, metric := range metrics {
+			va, oka := a.Values[metric.Key]
+			vb, okb := b.Values[metric.Key]
+			switch {
+			case !oka && !okb:
+				continue
+			case !okb:
+				return -1
+			case !oka:
+				return 1
+			case va == vb:
+				continue
+			case (va < vb) == ascending(metric.Order):
+				return -1
+			default:
+				return 1
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if c := compare(rows[i], rows[j]); c != 0 {
+			return c < 0
+		}
+		return rows[i].accountID < rows[j].accountID
+	})
+	for i, row := range rows {
+		row.Rank = i + 1
+		if i > 0 && compare(rows[i-1], row) == 0 {
+			row.Rank = rows[i-1].Rank
+		}
+	}
+	return rows
+}
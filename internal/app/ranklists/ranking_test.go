@@ -0,0 +1,37 @@
+package ranklists
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestRank(t *testing.T) {
+	record := func(accountID uint64, key string, value float64) models.RankListRecord {
+		return models.RankListRecord{AccountID: accountID, Key: key, Value: value}
+	}
+	rl := &models.RankList{
+		Models: []models.RankListModel{
+			{Key: "time", Priority: 1, Order: "asc"},
+			{Key: "score", Priority: 0, Order: "desc"},
+		},
+		Records: []models.RankListRecord{
+			record(1, "score", 80), record(1, "time", 20),
+			record(2, "score", 100), record(2, "time", 30),
+			record(3, "score", 80), record(3, "time", 10),
+			record(4, "score", 80), record(4, "time", 20),
+			// ranks after all accounts with a score
+			record(5, "time", 1),
+		},
+	}
+
+	var got []string
+	for _, row := range rank(rl) {
+		got = append(got, fmt.Sprintf("%d:%d", row.Rank, row.accountID))
+	}
+	if want := "1:2,2:3,3:1,3:4,5:5"; strings.Join(got, ",") != want {
+		t.Errorf("rank: got %s, want %s", strings.Join(got, ","), want)
+	}
+}
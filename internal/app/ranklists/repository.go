@@ -1,19 +1,22 @@
 package ranklists
 
 import (
+	"errors"
+
 	"github.com/infinity-oj/server-v2/pkg/models"
-	"github.com/spf13/cast"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
-	CreateRankList() (p *models.RankList, err error)
-	CreateRankListModel(id uint64) (*models.RankListModel, error)
-	CreateRankListRecord(rl *models.RankList, account *models.Account, key string, value interface{}) (*models.RankListRecord, error)
+	CreateRankList(problemID uint64, name, title string, metrics []models.RankListModel) (*models.RankList, error)
+	UpsertRankListRecord(rl *models.RankList, record *models.RankListRecord, policy Policy) (*models.RankListRecord, error)
 	GetRankList(id uint64) (*models.RankList, error)
+	GetRankListMetrics(id uint64) (*models.RankList, error)
 	GetRankListsByProblem(problem *models.Problem) ([]*models.RankList, error)
+	UpdateRankList(rl *models.RankList) error
+	DeleteRankList(id uint64) error
 }
 
 type repository struct {
@@ -23,65 +26,114 @@ type repository struct {
 
 func (m repository) GetRankListsByProblem(problem *models.Problem) ([]*models.RankList, error) {
 	var rl []*models.RankList
-	if err := m.db.Model(&models.RankList{}).Where("problem_id = ?", problem.ID).Find(&rl).Error; err != nil {
+	if err := m.db.Model(&models.RankList{}).Preload("Models").Where("problem_id = ?", problem.ID).Find(&rl).Error; err != nil {
 		return nil, err
 	}
 	return rl, nil
 }
 
-func (m repository) CreateRankListModel(id uint64) (*models.RankListModel, error) {
-	rm := &models.RankListModel{
-		RankListID: 0,
-		Key:        "key",
-		Priority:   1,
-		Order:      "dec",
-	}
-	if err := m.db.Create(&rm).Error; err != nil {
+// UpsertRankListRecord stores record as the record of its account and key in the ranklist,
+// replacing the existing one only if the policy prefers the new record.
+// It returns the record kept in the ranklist. The record is inserted unless one exists, so of
+// records stored at the same time one is inserted and the others are compared with it.
+func (m repository) UpsertRankListRecord(rl *models.RankList, record *models.RankListRecord, policy Policy) (*models.RankListRecord, error) {
+	record.RankListID = rl.ID
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Omit("Account").Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+
+		old := &models.RankListRecord{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where(map[string]interface{}{
+				"rank_list_id": rl.ID,
+				"account_id":   record.AccountID,
+				"key":          record.Key,
+			}).
+			First(old).Error; err != nil {
+			return err
+		}
+
+		if !policy.Replace(rl, old, record) {
+			*record = *old
+			return nil
+		}
+		record.Model = old.Model
+		return tx.Omit("Account").Save(record).Error
+	})
+	if err != nil {
+		m.logger.Error("upsert rank list record error",
+			zap.Any("rank list", rl), zap.Any("record", record), zap.Error(err))
 		return nil, err
 	}
-	return rm, nil
+	return record, nil
 }
 
-func (m repository) CreateRankListRecord(rl *models.RankList, account *models.Account, key string, value interface{}) (*models.RankListRecord, error) {
-	rlc := &models.RankListRecord{
-		RankListID: rl.ID,
-		AccountID:  account.ID,
-		Account:    *account,
-		Key:        key,
-		Value:      cast.ToFloat64(value),
-	}
-	if err := m.db.Model(rlc).Create(rlc).Error; err != nil {
-		m.logger.Error("create rank list record error",
-			zap.Any("rank list", rl), zap.Any("record", rlc), zap.Error(err))
+// GetRankList returns the ranklist of the id with its metrics and records, nil if there is none.
+func (m repository) GetRankList(id uint64) (*models.RankList, error) {
+	rl := &models.RankList{}
+	if err := m.db.Model(rl).Preload("Records.Account").Preload(clause.Associations).First(rl, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
-	return rlc, nil
+	return rl, nil
 }
 
-func (m repository) GetRankList(id uint64) (*models.RankList, error) {
+// GetRankListMetrics returns the ranklist of the id with its metrics but not its records, nil if
+// there is none.
+func (m repository) GetRankListMetrics(id uint64) (*models.RankList, error) {
 	rl := &models.RankList{}
-	if err := m.db.Model(rl).Preload("Records.Account").Preload(clause.Associations).First(rl, id).Error; err != nil {
+	if err := m.db.Preload("Models").First(rl, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
 		return nil, err
 	}
 	return rl, nil
 }
 
-func (m repository) CreateRankList() (rl *models.RankList, err error) {
+// CreateRankList creates a ranklist of the problem ranking by the metrics.
+func (m repository) CreateRankList(problemID uint64, name, title string, metrics []models.RankListModel) (rl *models.RankList, err error) {
 	rl = &models.RankList{
-		Model:     models.Model{},
-		ProblemID: 0,
-		Name:      "",
-		Title:     "",
-		Models: []models.RankListModel{
-			{
-				Key:      "score",
-				Priority: 0,
-				Order:    "inc",
-			},
-		},
-		Records: nil,
+		ProblemID: problemID,
+		Name:      name,
+		Title:     title,
+		Models:    metrics,
 	}
-	return rl, m.db.Create(rl).Error
+	return rl, m.db.Omit("Records").Create(rl).Error
+}
+
+// UpdateRankList saves the ranklist, replacing its metrics with the ones it holds. Records are
+// kept, so a metric renamed starts over.
+func (m repository) UpdateRankList(rl *models.RankList) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rank_list_id = ?", rl.ID).Delete(&models.RankListModel{}).Error; err != nil {
+			return err
+		}
+		for i := range rl.Models {
+			rl.Models[i].Model = models.Model{}
+		}
+		return tx.Omit("Records").Save(rl).Error
+	})
+}
+
+// DeleteRankList deletes the ranklist of the id with its metrics and records.
+func (m repository) DeleteRankList(id uint64) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rank_list_id = ?", id).Delete(&models.RankListRecord{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rank_list_id = ?", id).Delete(&models.RankListModel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.RankList{}, id).Error
+	})
 }
 
 func NewRepository(logger *zap.Logger, db *gorm.DB) Repository {
@@ -2,12 +2,23 @@ package ranklists
 
 import (
 	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+var (
+	ErrRankListNotFound = errors.New("ranklist not found")
+	ErrRankListExists   = errors.New("ranklist exists")
+	ErrInvalidMetrics   = errors.New("invalid ranklist metrics")
+)
+
 type Service interface {
 	GetRankList(id uint64) (*models.RankList, error)
 	GetRankListsByProblem(problem *models.Problem) ([]*models.RankList, error)
+	GetRanking(problem *models.Problem, id uint64) (*Ranking, error)
+	CreateRankList(problem *models.Problem, name, title string, metrics []models.RankListModel) (*models.RankList, error)
+	UpdateRankList(problem *models.Problem, id uint64, name, title string, metrics []models.RankListModel) (*models.RankList, error)
+	DeleteRankList(problem *models.Problem, id uint64) error
 }
 
 type service struct {
@@ -22,7 +33,7 @@ func (s service) GetRankListsByProblem(problem *models.Problem) ([]*models.RankL
 
 func (s service) GetRankList(id uint64) (*models.RankList, error) {
 	rl, err := s.Repository.GetRankList(id)
-	if err != nil {
+	if err != nil || rl == nil {
 		return nil, err
 	}
 	latestRecords := make(map[uint64]map[string]*models.RankListRecord)
@@ -44,6 +55,125 @@ func (s service) GetRankList(id uint64) (*models.RankList, error) {
 	return rl, nil
 }
 
+// problemRankList returns the ranklist of the id if it belongs to the problem.
+func (s service) problemRankList(problem *models.Problem, id uint64) (*models.RankList, error) {
+	rl, err := s.GetRankList(id)
+	if err != nil {
+		return nil, err
+	}
+	if rl == nil || rl.ProblemID != problem.ID {
+		return nil, ErrRankListNotFound
+	}
+	return rl, nil
+}
+
+// GetRanking returns the ranklist of the problem with its accounts ranked. The records are
+// left out, as the rows hold their values.
+func (s service) GetRanking(problem *models.Problem, id uint64) (*Ranking, error) {
+	rl, err := s.problemRankList(problem, id)
+	if err != nil {
+		return nil, err
+	}
+	rows := rank(rl)
+	rl.Records = nil
+	return &Ranking{
+		RankList: rl,
+		Rows:     rows,
+	}, nil
+}
+
+// checkRankList validates the metrics and that no other ranklist of the problem has the name.
+func (s service) checkRankList(problem *models.Problem, id uint64, name string, metrics []models.RankListModel) error {
+	if len(metrics) == 0 {
+		return errors.Wrap(ErrInvalidMetrics, "no metrics")
+	}
+	keys := map[string]bool{}
+	for _, metric := range metrics {
+		if metric.Key == "" {
+			return errors.Wrap(ErrInvalidMetrics, "empty key")
+		}
+		if keys[metric.Key] {
+			return errors.Wrapf(ErrInvalidMetrics, "duplicate key %s", metric.Key)
+		}
+		keys[metric.Key] = true
+		switch metric.Order {
+		case "asc", "desc", "inc", "dec":
+		default:
+			return errors.Wrapf(ErrInvalidMetrics, "unknown order %s of key %s", metric.Order, metric.Key)
+		}
+	}
+
+	rls, err := s.Repository.GetRankListsByProblem(problem)
+	if err != nil {
+		return err
+	}
+	for _, rl := range rls {
+		if rl.Name == name && rl.ID != id {
+			return errors.Wrapf(ErrRankListExists, "ranklist %s", name)
+		}
+	}
+	return nil
+}
+
+func (s service) CreateRankList(problem *models.Problem, name, title string, metrics []models.RankListModel) (*models.RankList, error) {
+	if err := s.checkRankList(problem, 0, name, metrics); err != nil {
+		return nil, err
+	}
+	rl, err := s.Repository.CreateRankList(problem.ID, name, title, metrics)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("create ranklist",
+		zap.String("problem", problem.Name),
+		zap.Uint64("ranklist id", rl.ID),
+	)
+	return rl, nil
+}
+
+// UpdateRankList renames the ranklist of the problem and replaces its metrics.
+func (s service) UpdateRankList(problem *models.Problem, id uint64, name, title string, metrics []models.RankListModel) (*models.RankList, error) {
+	rl, err := s.Repository.GetRankListMetrics(id)
+	if err != nil {
+		return nil, err
+	}
+	if rl == nil || rl.ProblemID != problem.ID {
+		return nil, ErrRankListNotFound
+	}
+	if err := s.checkRankList(problem, id, name, metrics); err != nil {
+		return nil, err
+	}
+
+	rl.Name = name
+	rl.Title = title
+	rl.Models = metrics
+	if err := s.Repository.UpdateRankList(rl); err != nil {
+		return nil, err
+	}
+	s.logger.Info("update ranklist",
+		zap.String("problem", problem.Name),
+		zap.Uint64("ranklist id", rl.ID),
+	)
+	return rl, nil
+}
+
+func (s service) DeleteRankList(problem *models.Problem, id uint64) error {
+	rl, err := s.Repository.GetRankListMetrics(id)
+	if err != nil {
+		return err
+	}
+	if rl == nil || rl.ProblemID != problem.ID {
+		return ErrRankListNotFound
+	}
+	if err := s.Repository.DeleteRankList(id); err != nil {
+		return err
+	}
+	s.logger.Info("delete ranklist",
+		zap.String("problem", problem.Name),
+		zap.Uint64("ranklist id", id),
+	)
+	return nil
+}
+
 func NewService(logger *zap.Logger, Repository Repository) Service {
 	return &service{
 		logger:     logger.With(zap.String("type", "program service")),
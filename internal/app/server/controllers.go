@@ -12,6 +12,7 @@ import (
 	"github.com/infinity-oj/server-v2/internal/app/ranklists"
 	"github.com/infinity-oj/server-v2/internal/app/submissions"
 	"github.com/infinity-oj/server-v2/internal/app/volumes"
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
 	"github.com/infinity-oj/server-v2/internal/pkg/http"
 	"github.com/infinity-oj/server-v2/internal/pkg/websockets"
 )
@@ -26,6 +27,7 @@ func CreateInitControllersFn(
 	processesInit processes.InitProcessGroupFn,
 	blueprintsInit blueprints.InitBlueprintGroupFn,
 	ranklistsInit ranklists.InitRanklistGroupFn,
+	webhooksInit webhooks.InitWebhookGroupFn,
 
 	websocketInit websockets.InitWebsocketGroupFn,
 ) http.InitControllers {
@@ -44,6 +46,7 @@ func CreateInitControllersFn(
 		processesInit(v1)
 		blueprintsInit(v1)
 		ranklistsInit(v1)
+		webhooksInit(v1)
 
 		res.LoadHTMLFiles("index.html")
 
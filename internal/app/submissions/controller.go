@@ -1,10 +1,12 @@
 package submissions
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/infinity-oj/server-v2/internal/app/problems"
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
 	"go.uber.org/zap"
 )
@@ -54,7 +56,16 @@ func (d *controller) CreateSubmission(c *gin.Context) {
 		zap.String("user space", request.UserSpace),
 	)
 
-	code, submission, judgement, err := d.service.Create(session.AccountId, request.ProblemId, request.UserSpace)
+	code, submission, judgement, err := d.service.Create(&problems.Principal{
+		AccountID: session.AccountId,
+		Roles:     session.Roles,
+	}, request.ProblemId, request.UserSpace)
+	if errors.Is(err, ErrProblemNotPublished) {
+		c.JSON(code, gin.H{
+			"msg": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		d.logger.Error("create submission", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -3,16 +3,18 @@ package submissions
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
 	"github.com/infinity-oj/server-v2/internal/app/problems"
-	"github.com/infinity-oj/server-v2/internal/lib/scheduler"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"go.uber.org/zap"
 )
 
+var ErrProblemNotPublished = errors.New("problem not published")
+
 type Service interface {
-	Create(submitterID uint64, problemName string, userSpace string) (code int, s *models.Submission, j *models.Judgement, err error)
+	Create(submitter *problems.Principal, problemName string, userSpace string) (code int, s *models.Submission, j *models.Judgement, err error)
 	GetSubmission(submissionId string) (s *models.Submission, err error)
 	GetSubmissions(problemId string, page, pageSize int) (res []*models.Submission, err error)
 	GetSubmissionsByAccountId(accountId uint64, page, pageSize int) (res []*models.Submission, err error)
@@ -24,8 +26,6 @@ type service struct {
 	ProblemRepository    problems.Repository
 
 	JudgementService judgements.Service
-
-	scheduler scheduler.Scheduler
 }
 
 func (d service) GetSubmissionsByAccountId(accountId uint64, page, pageSize int) (res []*models.Submission, err error) {
@@ -57,7 +57,10 @@ func (d service) GetSubmission(submissionId string) (s *models.Submission, err e
 	return
 }
 
-func (d service) Create(submitterID uint64, problemName, userSpace string) (code int, s *models.Submission, j *models.Judgement, err error) {
+// Create submits the user space to the problem and starts judging it. Problems not published
+// yet only take submissions from admins, their owner and collaborators.
+func (d service) Create(submitter *problems.Principal, problemName, userSpace string) (code int, s *models.Submission, j *models.Judgement, err error) {
+	submitterID := submitter.AccountID
 	d.logger.Debug("create submission",
 		zap.Uint64("submitter Id", submitterID),
 		zap.String("problem name", problemName),
@@ -72,6 +75,17 @@ func (d service) Create(submitterID uint64, problemName, userSpace string) (code
 		d.logger.Error("create submission: unknown problem")
 		return http.StatusInternalServerError, nil, nil, errors.New("unknown problem")
 	}
+	if !problem.IsPublished(time.Now()) && problem.OwnerID != submitterID && !submitter.HasRole(models.RoleAdmin) {
+		// collaborators try their problems out before publishing
+		collaborator, err := d.ProblemRepository.IsCollaborator(problem.ID, submitterID)
+		if err != nil {
+			d.logger.Error("create submission", zap.Error(err))
+			return http.StatusInternalServerError, nil, nil, err
+		}
+		if !collaborator {
+			return http.StatusForbidden, nil, nil, ErrProblemNotPublished
+		}
+	}
 	d.logger.Debug("create submission",
 		zap.Uint64("submitter Id", submitterID),
 		zap.Uint64("problem id", problem.ID),
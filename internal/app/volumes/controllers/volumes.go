@@ -1,36 +1,118 @@
 package controllers
 
 import (
-	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/pkg/errors"
 
 	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
 
 	"github.com/gin-gonic/gin"
 	"github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	"github.com/infinity-oj/server-v2/pkg/models"
 	"go.uber.org/zap"
 )
 
 type Controller interface {
 	CreateVolume(c *gin.Context)
 	CreateFile(c *gin.Context)
+	ExtractArchive(c *gin.Context)
 	CreateDirectory(c *gin.Context)
+	DeleteDirectory(c *gin.Context)
+	Move(c *gin.Context)
+	ListDirectory(c *gin.Context)
 
 	DeleteFile(c *gin.Context)
 
 	GetVolume(c *gin.Context)
 	DownloadDirectory(c *gin.Context)
 	DownloadFile(c *gin.Context)
+
+	CollectBlobs(c *gin.Context)
+	CollectVolumes(c *gin.Context)
+
+	SquashVolume(c *gin.Context)
+	GetHistory(c *gin.Context)
+
+	CreateTag(c *gin.Context)
+	DeleteTag(c *gin.Context)
+	GetTags(c *gin.Context)
+
+	DiffVolumes(c *gin.Context)
+	MergeVolumes(c *gin.Context)
 }
 type DefaultController struct {
 	logger  *zap.Logger
 	service services.Service
 }
 
+// authorize checks the session may access the volume with the permission. Otherwise it aborts
+// the request and returns false, with 401 for anonymous requests and 403 for signed in ones.
+func (d DefaultController) authorize(c *gin.Context, session *sessions.Session, volumeName string, permission services.Permission) bool {
+	principal := &services.Principal{}
+	if session != nil {
+		principal.AccountID = session.AccountId
+		principal.Roles = session.Roles
+	}
+
+	granted, err := d.service.GetPermission(volumeName, principal)
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return false
+		}
+		d.logger.Error("get volume permission failed", zap.String("volume", volumeName), zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+	if granted >= permission {
+		return true
+	}
+
+	d.logger.Debug("volume access denied",
+		zap.String("volume", volumeName),
+		zap.Uint64("account id", principal.AccountID),
+	)
+	if session == nil {
+		c.AbortWithStatus(http.StatusUnauthorized)
+	} else {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+	return false
+}
+
+// multipartOverhead is the room left for multipart headers and boundaries in an upload request,
+// on top of the file size limit.
+const multipartOverhead = 1 << 20
+
+// abortOnLimit aborts the request if err is a size limit or quota hit, and reports whether it did.
+func (d DefaultController) abortOnLimit(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrFileTooLarge), errors.Is(err, services.ErrArchiveTooLarge):
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"msg": err.Error(),
+		})
+	case errors.Is(err, services.ErrQuotaExceeded):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"msg": err.Error(),
+		})
+	default:
+		return false
+	}
+	return true
+}
+
 func (d DefaultController) DownloadDirectory(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
 	request := struct {
 		Dirname string `form:"dirname"`
 	}{}
@@ -56,25 +138,32 @@ func (d DefaultController) DownloadDirectory(c *gin.Context) {
 
 	volume := c.Param("name")
 
-	file, err := d.service.GetDirectory(volume, "/")
-	if err != nil {
+	c.Header("Content-Type", "application/zip")
+	if err := d.service.GetDirectory(volume, request.Dirname, c.Writer); err != nil {
+		if errors.Is(err, services.ErrPathNotFound) || errors.Is(err, services.ErrVolumeNotFound) {
+			c.Header("Content-Type", "")
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
 		d.logger.Error("Download directory", zap.Error(err))
-		c.AbortWithStatus(http.StatusInternalServerError)
+		if c.Writer.Written() {
+			// the archive is partially sent, the client sees a truncated zip
+			c.Abort()
+		} else {
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
 		return
 	}
-	c.File(file.Name())
 }
 
 func (d DefaultController) CreateFile(c *gin.Context) {
 	session := sessions.GetSession(c)
-	if session == nil {
-		d.logger.Debug("get principal failed")
-		//c.AbortWithStatus(http.StatusUnauthorized)
-		//return
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
 	}
 
 	// single formFile
-	formFile, err := c.FormFile("file")
+	formFile, err := d.formFile(c, d.service.Limits().MaxFileSize)
 	if err != nil {
 		d.logger.Error("create file failed", zap.Error(err))
 		return
@@ -90,9 +179,13 @@ func (d DefaultController) CreateFile(c *gin.Context) {
 		d.logger.Error("create file failed", zap.Error(err))
 		return
 	}
-	fileData, _ := ioutil.ReadAll(file)
-	volume, err := d.service.CreateFile(volumeName, "/", formFile.Filename, fileData)
+	defer file.Close()
+	dirname := c.DefaultPostForm("dirname", "/")
+	volume, err := d.service.CreateFile(volumeName, dirname, formFile.Filename, file)
 	if err != nil {
+		if d.abortOnLimit(c, err) {
+			return
+		}
 		d.logger.Error("create file failed", zap.Error(err))
 		return
 	}
@@ -100,11 +193,97 @@ func (d DefaultController) CreateFile(c *gin.Context) {
 	c.JSON(http.StatusOK, volume)
 }
 
+// formFile returns the uploaded file of the request, whose body may be at most limit bytes
+// besides the multipart overhead, zero if unlimited. It aborts the request on failure.
+func (d DefaultController) formFile(c *gin.Context, limit int64) (*multipart.FileHeader, error) {
+	if limit > 0 {
+		// stop reading the upload early rather than spooling an oversized body to disk
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+	}
+	formFile, err := c.FormFile("file")
+	if err != nil {
+		if err.Error() == "http: request body too large" {
+			c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		}
+		return nil, err
+	}
+	return formFile, nil
+}
+
+// ExtractArchive unpacks an uploaded zip or tar.gz archive into dirname of the volume in one new
+// layer. With replace set, what was in dirname is deleted first.
+func (d DefaultController) ExtractArchive(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	// the body is bounded before binding, which parses the multipart form
+	formFile, err := d.formFile(c, d.service.Limits().MaxArchiveSize)
+	if err != nil {
+		d.logger.Debug("extract archive failed", zap.Error(err))
+		return
+	}
+
+	request := struct {
+		Dirname string `form:"dirname"`
+		Replace bool   `form:"replace"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	file, err := formFile.Open()
+	if err != nil {
+		d.logger.Error("extract archive failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	volumeName := c.Param("name")
+
+	volume, err := d.service.ExtractArchive(volumeName, request.Dirname, file, formFile.Size, request.Replace)
+	if err != nil {
+		if d.abortOnLimit(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidArchive), errors.Is(err, services.ErrInvalidPath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("extract archive failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, volume)
+}
+
 func (d DefaultController) DeleteFile(c *gin.Context) {
 	session := sessions.GetSession(c)
-	if session == nil {
-		d.logger.Debug("get principal failed")
-		c.AbortWithStatus(http.StatusUnauthorized)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
 		return
 	}
 
@@ -144,9 +323,7 @@ func (d DefaultController) DeleteFile(c *gin.Context) {
 
 func (d DefaultController) CreateDirectory(c *gin.Context) {
 	session := sessions.GetSession(c)
-	if session == nil {
-		d.logger.Debug("get principal failed")
-		c.AbortWithStatus(http.StatusUnauthorized)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
 		return
 	}
 
@@ -173,25 +350,221 @@ func (d DefaultController) CreateDirectory(c *gin.Context) {
 
 	volume, err := d.service.CreateDirectory(volumeName, request.Dirname)
 	if err != nil {
-		d.logger.Error("create volume failed")
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrFileExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"msg": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidPath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("create directory failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, volume)
+}
+
+func (d DefaultController) CollectBlobs(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !session.HasRole(models.RoleAdmin) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	count, err := d.service.CollectBlobs()
+	if err != nil {
+		d.logger.Error("collect blobs failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collected": count,
+	})
+}
+
+// CollectVolumes deletes unreachable volumes now. A GET request only reports what would be deleted.
+func (d DefaultController) CollectVolumes(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !session.HasRole(models.RoleAdmin) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	dryRun := c.Request.Method == http.MethodGet
+	report, err := d.service.CollectVolumes(dryRun)
+	if err != nil {
+		d.logger.Error("collect volumes failed", zap.Bool("dry run", dryRun), zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (d DefaultController) DeleteDirectory(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	request := struct {
+		Dirname string `form:"dirname" binding:"required"`
+	}{}
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	volume, err := d.service.RemoveDirectory(c.Param("name"), request.Dirname)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound), errors.Is(err, services.ErrPathNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidPath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("remove directory failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, volume)
 }
 
+func (d DefaultController) Move(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	request := struct {
+		From string `json:"from" binding:"required"`
+		To   string `json:"to" binding:"required"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	volume, err := d.service.Move(c.Param("name"), request.From, request.To)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound), errors.Is(err, services.ErrPathNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrFileExists):
+			c.JSON(http.StatusConflict, gin.H{
+				"msg": err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidPath):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("move failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, volume)
+}
+
+func (d DefaultController) ListDirectory(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
+	request := struct {
+		Dirname   string `form:"dirname"`
+		Recursive bool   `form:"recursive"`
+	}{}
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	fileRecords, err := d.service.ListDirectory(c.Param("name"), request.Dirname, request.Recursive)
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) || errors.Is(err, services.ErrPathNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("list directory failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, fileRecords)
+}
+
 func (d DefaultController) CreateVolume(c *gin.Context) {
 	session := sessions.GetSession(c)
-	createBy := uint64(0)
-	if session != nil {
-		//d.logger.Debug("get principal failed")
-		//c.AbortWithStatus(http.StatusUnauthorized)
-		//return
-		createBy = session.AccountId
+	if session == nil {
+		d.logger.Debug("get principal failed")
+		c.AbortWithStatus(http.StatusUnauthorized)
+		return
 	}
 
-	volume, err := d.service.CreateVolume(createBy)
+	volume, err := d.service.CreateVolume(session.AccountId)
 	if err != nil {
+		if d.abortOnLimit(c, err) {
+			return
+		}
 		d.logger.Error("create volume failed")
 		return
 	}
@@ -200,6 +573,11 @@ func (d DefaultController) CreateVolume(c *gin.Context) {
 }
 
 func (d DefaultController) DownloadFile(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
 	request := struct {
 		Filename string `form:"filename" binding:"required,gt=0"`
 	}{}
@@ -222,7 +600,7 @@ func (d DefaultController) DownloadFile(c *gin.Context) {
 	filename := strings.ReplaceAll(request.Filename, "%2f", "/")
 	filename = strings.ReplaceAll(request.Filename, "%2F", "/")
 
-	file, err := d.service.GetFile(volume, filename)
+	reader, size, err := d.service.GetFile(volume, filename)
 	if err != nil {
 		if err.Error() == "not found" {
 			c.AbortWithStatus(http.StatusNotFound)
@@ -232,14 +610,18 @@ func (d DefaultController) DownloadFile(c *gin.Context) {
 		}
 		return
 	}
-	c.File(file.Name())
+	defer reader.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
 }
 
 func (d DefaultController) GetVolume(c *gin.Context) {
 	session := sessions.GetSession(c)
-	if session == nil {
-		d.logger.Debug("get principal failed")
-		c.AbortWithStatus(http.StatusUnauthorized)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
 		return
 	}
 
@@ -254,6 +636,215 @@ func (d DefaultController) GetVolume(c *gin.Context) {
 	c.JSON(http.StatusOK, volume)
 }
 
+func (d DefaultController) SquashVolume(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	volume, err := d.service.SquashVolume(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("squash volume failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, volume)
+}
+
+func (d DefaultController) GetHistory(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
+	volumes, err := d.service.GetHistory(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("get volume history failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, volumes)
+}
+
+func (d DefaultController) CreateTag(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	request := struct {
+		Tag string `json:"tag" binding:"required,max=64"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	// moving an existing tag changes what its users see, so it needs write access to its volume
+	if _, err := d.service.GetPermission(request.Tag, &services.Principal{}); err == nil {
+		if !d.authorize(c, session, request.Tag, services.PermissionWrite) {
+			return
+		}
+	}
+
+	tag, err := d.service.TagVolume(c.Param("name"), request.Tag, session.AccountId)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrInvalidTag):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("tag volume failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+func (d DefaultController) DeleteTag(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	if err := d.service.UntagVolume(c.Param("name"), c.Param("tag")); err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) || errors.Is(err, services.ErrTagNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("untag volume failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (d DefaultController) GetTags(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
+	tags, err := d.service.GetTags(c.Param("name"))
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("get volume tags failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func (d DefaultController) DiffVolumes(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionRead) {
+		return
+	}
+
+	if !d.authorize(c, session, c.Param("other"), services.PermissionRead) {
+		return
+	}
+
+	diff, err := d.service.DiffVolumes(c.Param("name"), c.Param("other"))
+	if err != nil {
+		if errors.Is(err, services.ErrVolumeNotFound) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		d.logger.Error("diff volumes failed", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func (d DefaultController) MergeVolumes(c *gin.Context) {
+	session := sessions.GetSession(c)
+	if !d.authorize(c, session, c.Param("name"), services.PermissionWrite) {
+		return
+	}
+
+	request := struct {
+		Theirs string `json:"theirs" binding:"required"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	if !d.authorize(c, session, request.Theirs, services.PermissionRead) {
+		return
+	}
+
+	volume, conflicts, err := d.service.MergeVolumes(c.Param("name"), request.Theirs)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrVolumeNotFound):
+			c.AbortWithStatus(http.StatusNotFound)
+		case errors.Is(err, services.ErrNoCommonBase):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"msg": err.Error(),
+			})
+		default:
+			d.logger.Error("merge volumes failed", zap.Error(err))
+			c.AbortWithStatus(http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"msg":       "merge conflicts",
+			"conflicts": conflicts,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, volume)
+}
+
 func New(logger *zap.Logger, s services.Service) Controller {
 	return &DefaultController{
 		logger:  logger,
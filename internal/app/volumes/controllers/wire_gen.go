@@ -31,7 +31,15 @@ func CreateVolumesController(cf string, sto storages.Storage, rep repositories.R
 	if err != nil {
 		return nil, err
 	}
-	service := services.NewVolumeService(logger, sto, rep)
+	servicesOptions, err := services.NewOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	gcOptions, err := services.NewGCOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	service := services.NewVolumeService(logger, servicesOptions, gcOptions, sto, rep)
 	controller := New(logger, service)
 	return controller, nil
 }
@@ -1,16 +1,38 @@
 package repositories
 
 import (
+	"time"
+
 	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository interface {
-	CreateVolume(baseVolume *models.Volume, accountId uint64, volumeName string) (*models.Volume, error)
+	CreateVolume(baseVolume *models.Volume, accountId uint64, volumeName string, fileRecords models.FileRecords) (*models.Volume, error)
 	UpdateVolume(volume *models.Volume) (*models.Volume, error)
 	GetVolume(volumeName string) (*models.Volume, error)
 	GetVolumeByID(volumeID uint64) (*models.Volume, error)
+	GetVolumeChain(volumeID uint64) ([]*models.Volume, error)
+	IsPublicVolume(volumeName string) (bool, error)
+	IsProblemVolumeEditor(volumeID, accountID uint64) (bool, error)
+	CountVolumes(accountID uint64) (int64, error)
+	CountStoredBytes(accountID uint64) (int64, error)
+	GetUnreachableVolumes(createdBefore time.Time) ([]*models.Volume, error)
+	DeleteVolume(volumeID uint64) error
+
+	SaveTag(tag *models.VolumeTag) error
+	GetTag(name string) (*models.VolumeTag, error)
+	GetTags(volumeIDs []uint64) ([]*models.VolumeTag, error)
+	DeleteTag(name string) (bool, error)
+
+	AcquireBlob(hash string, size int64) error
+	ReleaseBlob(hash string) error
+	GetUnreferencedBlobs() ([]*models.Blob, error)
+	GetBlobs(hashes []string) ([]*models.Blob, error)
+	DeleteBlob(hash string) (bool, error)
 }
 
 type repository struct {
@@ -33,6 +55,22 @@ func (r repository) GetVolumeByID(volumeID uint64) (*models.Volume, error) {
 	return volume, nil
 }
 
+// GetVolumeChain returns the volume and all its base layers, the bottom layer first, in one query.
+func (r repository) GetVolumeChain(volumeID uint64) ([]*models.Volume, error) {
+	var volumes []*models.Volume
+	err := r.db.Raw(`WITH RECURSIVE chain AS (
+		SELECT volumes.*, 0 AS depth FROM volumes WHERE id = ?
+		UNION ALL
+		SELECT volumes.*, chain.depth + 1 FROM volumes JOIN chain ON volumes.id = chain.base
+	)
+	SELECT id, created_at, updated_at, deleted_at, base, squashed_from, created_by, name, file_records
+	FROM chain ORDER BY depth DESC`, volumeID).Scan(&volumes).Error
+	if err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
 func (r repository) GetVolume(volumeName string) (*models.Volume, error) {
 	volume := &models.Volume{}
 	if err := r.db.Where("name = ?", volumeName).Limit(1).Find(volume).Error; err != nil {
@@ -41,16 +79,20 @@ func (r repository) GetVolume(volumeName string) (*models.Volume, error) {
 	return volume, nil
 }
 
-func (r repository) CreateVolume(baseVolume *models.Volume, accountId uint64, volumeName string) (*models.Volume, error) {
+// CreateVolume creates the volume as a layer on top of the base volume, if any, with the file records.
+func (r repository) CreateVolume(baseVolume *models.Volume, accountId uint64, volumeName string, fileRecords models.FileRecords) (*models.Volume, error) {
 	var baseVolumeID uint64 = 0
 	if baseVolume != nil {
 		baseVolumeID = baseVolume.ID
 	}
+	if fileRecords == nil {
+		fileRecords = models.FileRecords{}
+	}
 	volume := &models.Volume{
 		Base:        baseVolumeID,
 		CreatedBy:   accountId,
 		Name:        volumeName,
-		FileRecords: models.FileRecords{},
+		FileRecords: fileRecords,
 	}
 
 	if err := r.db.Create(volume).Error; err != nil {
@@ -61,6 +103,193 @@ func (r repository) CreateVolume(baseVolume *models.Volume, accountId uint64, vo
 	return volume, nil
 }
 
+// AcquireBlob adds a reference to the blob, creating it on first reference.
+func (r repository) AcquireBlob(hash string, size int64) error {
+	blob := &models.Blob{
+		Hash:     hash,
+		Size:     size,
+		RefCount: 1,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"ref_count":  gorm.Expr("blobs.ref_count + 1"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).Create(blob).Error
+}
+
+func (r repository) ReleaseBlob(hash string) error {
+	return r.db.Model(&models.Blob{}).
+		Where("hash = ? AND ref_count > 0", hash).
+		Update("ref_count", gorm.Expr("ref_count - 1")).Error
+}
+
+func (r repository) GetUnreferencedBlobs() ([]*models.Blob, error) {
+	var blobs []*models.Blob
+	if err := r.db.Where("ref_count <= 0").Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// GetBlobs returns the blobs with the hashes, skipping hashes which have none.
+func (r repository) GetBlobs(hashes []string) ([]*models.Blob, error) {
+	var blobs []*models.Blob
+	if len(hashes) == 0 {
+		return blobs, nil
+	}
+	if err := r.db.Where("hash IN ?", hashes).Find(&blobs).Error; err != nil {
+		return nil, err
+	}
+	return blobs, nil
+}
+
+// DeleteBlob deletes the blob if it is still unreferenced, and reports whether it was deleted.
+func (r repository) DeleteBlob(hash string) (bool, error) {
+	result := r.db.Unscoped().Where("hash = ? AND ref_count <= 0", hash).Delete(&models.Blob{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// SaveTag creates the tag, or moves it if a tag with the name exists.
+func (r repository) SaveTag(tag *models.VolumeTag) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"volume_id", "created_by", "updated_at"}),
+	}).Create(tag).Error
+}
+
+func (r repository) GetTag(name string) (*models.VolumeTag, error) {
+	tag := &models.VolumeTag{}
+	if err := r.db.Where(map[string]interface{}{"name": name}).First(tag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (r repository) GetTags(volumeIDs []uint64) ([]*models.VolumeTag, error) {
+	var tags []*models.VolumeTag
+	if err := r.db.Where("volume_id IN ?", volumeIDs).Order("name").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (r repository) DeleteTag(name string) (bool, error) {
+	result := r.db.Unscoped().Where(map[string]interface{}{"name": name}).Delete(&models.VolumeTag{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsPublicVolume reports whether the volume is the public volume of a problem everyone may read,
+// which is one published by now or archived.
+func (r repository) IsPublicVolume(volumeName string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.Problem{}).
+		Where("public_volume = ?", volumeName).
+		Where("state = ? OR state = ? AND (publish_at IS NULL OR publish_at <= ?)",
+			models.ProblemArchived, models.ProblemPublished, time.Now()).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsProblemVolumeEditor reports whether the account owns or collaborates on a problem whose public
+// or private volume is the volume or one of its base layers, so it may edit the data of the problem.
+func (r repository) IsProblemVolumeEditor(volumeID, accountID uint64) (bool, error) {
+	var count int64
+	err := r.db.Raw(`WITH RECURSIVE chain AS (
+		SELECT id, base, name FROM volumes WHERE id = ?
+		UNION ALL
+		SELECT volumes.id, volumes.base, volumes.name FROM volumes JOIN chain ON volumes.id = chain.base
+	)
+	SELECT COUNT(*) FROM problems
+	WHERE deleted_at IS NULL
+		AND (public_volume IN (SELECT name FROM chain) OR private_volume IN (SELECT name FROM chain))
+		AND (owner_id = ? OR id IN (SELECT problem_id FROM problem_collaborators WHERE account_id = ?))`,
+		volumeID, accountID, accountID).Scan(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CountVolumes returns how many volumes the account created. Layers on top of a volume are
+// part of it, so only bottom layers are counted, and a squashed layer counts as the bottom layer
+// it was squashed from.
+func (r repository) CountVolumes(accountID uint64) (int64, error) {
+	var count int64
+	err := r.db.Raw(`SELECT COUNT(DISTINCT CASE squashed_from WHEN 0 THEN id ELSE squashed_from END)
+	FROM volumes WHERE created_by = ? AND base = 0 AND deleted_at IS NULL`, accountID).Scan(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountStoredBytes sums the sizes of the files added to volumes of the account, counting each
+// content once. Records created before blobs have no hash and are told apart by their path.
+// Volumes without records store null rather than an empty array.
+func (r repository) CountStoredBytes(accountID uint64) (int64, error) {
+	var bytes int64
+	err := r.db.Raw(`SELECT COALESCE(SUM(size), 0) FROM (
+		SELECT DISTINCT
+			COALESCE(record->>'hash', (record->>'volume') || ':' || (record->>'volumePath')) AS content,
+			COALESCE((record->>'size')::bigint, 0) AS size
+		FROM volumes, json_array_elements(
+			CASE json_typeof(volumes.file_records) WHEN 'array' THEN volumes.file_records ELSE '[]' END
+		) AS record
+		WHERE volumes.created_by = ? AND volumes.deleted_at IS NULL
+			AND record->>'opt' = 'add' AND record->>'file_type' = 'f'
+	) AS contents`, accountID).Scan(&bytes).Error
+	if err != nil {
+		return 0, err
+	}
+	return bytes, nil
+}
+
+// volumeRoots selects the names of volumes referenced by problems, submissions, judgements and
+// cached process outputs, which would otherwise be handed out after their volume is gone.
+// Cached files are referenced as volume/path. Outputs of cache entries without slots hold null.
+const volumeRoots = `
+	SELECT public_volume AS name FROM problems WHERE deleted_at IS NULL
+	UNION SELECT private_volume FROM problems WHERE deleted_at IS NULL
+	UNION SELECT user_volume FROM submissions WHERE deleted_at IS NULL
+	UNION SELECT args->>'volume' FROM judgements WHERE deleted_at IS NULL
+	UNION SELECT CASE slot->>'type' WHEN 'file' THEN split_part(slot->>'value', '/', 1) ELSE slot->>'value' END
+	FROM process_caches, json_array_elements(
+		CASE json_typeof(process_caches.outputs) WHEN 'array' THEN process_caches.outputs ELSE '[]' END
+	) AS slot WHERE slot->>'type' IN ('volume', 'file')`
+
+// GetUnreachableVolumes returns the volumes created before the time which are neither referenced
+// by name or tag, nor a base layer of a volume which is, or of one created since.
+func (r repository) GetUnreachableVolumes(createdBefore time.Time) ([]*models.Volume, error) {
+	var volumes []*models.Volume
+	err := r.db.Raw(`WITH RECURSIVE roots AS (`+volumeRoots+`
+	), reachable AS (
+		SELECT id, base FROM volumes
+		WHERE name IN (SELECT name FROM roots)
+			OR id IN (SELECT volume_id FROM volume_tags WHERE deleted_at IS NULL)
+			OR created_at >= ?
+		UNION
+		SELECT volumes.id, volumes.base FROM volumes JOIN reachable ON volumes.id = reachable.base
+	)
+	SELECT id, created_at, updated_at, deleted_at, base, squashed_from, created_by, name, file_records
+	FROM volumes WHERE deleted_at IS NULL AND id NOT IN (SELECT id FROM reachable)
+	ORDER BY id`, createdBefore).Scan(&volumes).Error
+	if err != nil {
+		return nil, err
+	}
+	return volumes, nil
+}
+
+func (r repository) DeleteVolume(volumeID uint64) error {
+	return r.db.Delete(&models.Volume{}, volumeID).Error
+}
+
 func NewRepository(logger *zap.Logger, db *gorm.DB) Repository {
 	return &repository{
 		logger: logger.With(zap.String("type", "repository")),
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+// Permission is what a principal may do with a volume, each permission includes the lower ones.
+type Permission int
+
+const (
+	PermissionNone Permission = iota
+	PermissionRead
+	PermissionWrite
+)
+
+// Principal is who accesses a volume. An anonymous principal has no account and no roles.
+type Principal struct {
+	AccountID uint64
+	Roles     []string
+}
+
+func (p *Principal) HasRole(roles ...string) bool {
+	for _, role := range p.Roles {
+		for _, r := range roles {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetPermission decides what the principal may do with the volume, a layer or a tag:
+//   - staff, admins and setters, may write every volume, including private volumes of problems
+//   - the owner, who created the volume, may write it and every layer built on it
+//   - owners and collaborators of a problem may write its volumes and every layer built on them
+//   - the judging pipeline may read every volume
+//   - everyone may read public volumes of problems
+//
+// Anything else, such as submission volumes of other users, is denied.
+func (d DefaultService) GetPermission(volumeName string, principal *Principal) (Permission, error) {
+	volume, err := d.lookupVolume(volumeName)
+	if err != nil {
+		return PermissionNone, err
+	}
+	if volume.ID == 0 {
+		return PermissionNone, ErrVolumeNotFound
+	}
+
+	if principal.HasRole(models.RoleAdmin, models.RoleSetter) {
+		return PermissionWrite, nil
+	}
+	if principal.AccountID != 0 {
+		if volume.CreatedBy == principal.AccountID {
+			return PermissionWrite, nil
+		}
+		editor, err := d.Repository.IsProblemVolumeEditor(volume.ID, principal.AccountID)
+		if err != nil {
+			return PermissionNone, err
+		}
+		if editor {
+			return PermissionWrite, nil
+		}
+	}
+	if principal.HasRole(models.RoleJudge) {
+		return PermissionRead, nil
+	}
+	public, err := d.Repository.IsPublicVolume(volume.Name)
+	if err != nil {
+		return PermissionNone, err
+	}
+	if public {
+		return PermissionRead, nil
+	}
+	return PermissionNone, nil
+}
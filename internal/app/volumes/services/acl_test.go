@@ -0,0 +1,90 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"go.uber.org/zap"
+)
+
+// aclRepository serves volumes and public volumes from memory, other methods are not used.
+type aclRepository struct {
+	repositories.Repository
+	volumes map[string]*models.Volume
+	public  map[string]bool
+	editors map[uint64]uint64
+}
+
+func (r *aclRepository) GetVolume(volumeName string) (*models.Volume, error) {
+	if volume, ok := r.volumes[volumeName]; ok {
+		return volume, nil
+	}
+	return &models.Volume{}, nil
+}
+
+func (r *aclRepository) GetTag(name string) (*models.VolumeTag, error) {
+	return nil, nil
+}
+
+func (r *aclRepository) IsPublicVolume(volumeName string) (bool, error) {
+	return r.public[volumeName], nil
+}
+
+func (r *aclRepository) IsProblemVolumeEditor(volumeID, accountID uint64) (bool, error) {
+	return r.editors[volumeID] == accountID, nil
+}
+
+func TestGetPermission(t *testing.T) {
+	repo := &aclRepository{
+		volumes: map[string]*models.Volume{
+			"private":    {Model: models.Model{ID: 1}, Name: "private", CreatedBy: 10},
+			"public":     {Model: models.Model{ID: 2}, Name: "public", CreatedBy: 10},
+			"submission": {Model: models.Model{ID: 3}, Name: "submission", CreatedBy: 20},
+		},
+		public: map[string]bool{"public": true},
+		// the account 60 collaborates on the problem of the private volume
+		editors: map[uint64]uint64{1: 60},
+	}
+	service := NewVolumeService(zap.NewNop(), &Options{}, &GCOptions{}, nil, repo)
+
+	type test struct {
+		volume    string
+		principal *Principal
+		want      Permission
+	}
+
+	anonymous := &Principal{}
+	setter := &Principal{AccountID: 10, Roles: []string{models.RoleSetter}}
+	judge := &Principal{AccountID: 30, Roles: []string{models.RoleJudge}}
+	user := &Principal{AccountID: 20}
+	other := &Principal{AccountID: 40}
+
+	tests := []test{
+		{"private", anonymous, PermissionNone},
+		{"private", user, PermissionNone},
+		{"private", setter, PermissionWrite},
+		{"private", judge, PermissionRead},
+		{"private", &Principal{AccountID: 60}, PermissionWrite},
+		{"public", anonymous, PermissionRead},
+		{"public", user, PermissionRead},
+		{"submission", user, PermissionWrite},
+		{"submission", other, PermissionNone},
+		{"submission", judge, PermissionRead},
+		{"submission", &Principal{AccountID: 50, Roles: []string{models.RoleAdmin}}, PermissionWrite},
+	}
+
+	for _, tt := range tests {
+		got, err := service.GetPermission(tt.volume, tt.principal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s for %+v: got %d, want %d", tt.volume, tt.principal, got, tt.want)
+		}
+	}
+
+	if _, err := service.GetPermission("missing", setter); err != ErrVolumeNotFound {
+		t.Errorf("missing volume: got %v, want %v", err, ErrVolumeNotFound)
+	}
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidArchive  = errors.New("invalid archive")
+	ErrArchiveTooLarge = errors.New("archive too large")
+)
+
+// walkFunc is called for each file and directory of an archive in order. The reader of a
+// directory is nil.
+type walkFunc func(name string, dir bool, r io.Reader) error
+
+// archiveWalker calls fn for the entries of an archive, stopping at the first error.
+type archiveWalker func(fn walkFunc) error
+
+// ExtractArchive creates a new layer on top of the base volume holding all files of the zip or
+// tar.gz archive, placed under dirname with their paths preserved. If replace is set, the layer
+// deletes everything in dirname first. Entries escaping dirname are rejected, and the extracted
+// content is bounded by the archive limits besides the file size limit and quota.
+func (d DefaultService) ExtractArchive(baseVolumeName, dirname string, archive io.ReaderAt, size int64, replace bool) (*models.Volume, error) {
+	return d.extractArchive(baseVolumeName, dirname, "/", archive, size, replace)
+}
+
+// ExtractArchiveTree is ExtractArchive for the entries in the directory from of the archive,
+// placed under dirname relative to from.
+func (d DefaultService) ExtractArchiveTree(baseVolumeName, dirname, from string, archive io.ReaderAt, size int64) (*models.Volume, error) {
+	return d.extractArchive(baseVolumeName, dirname, path.Join("/", from), archive, size, false)
+}
+
+func (d DefaultService) extractArchive(baseVolumeName, dirname, from string, archive io.ReaderAt, size int64, replace bool) (*models.Volume, error) {
+	dirname = path.Join("/", dirname)
+	walk, err := d.openArchive(archive, size)
+	if err != nil {
+		return nil, err
+	}
+
+	baseVolume, err := d.resolveExisting(baseVolumeName)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := d.remainingBytes(baseVolume.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	extracted := int64(0)
+
+	var stored, fileRecords models.FileRecords
+	err = walk(func(name string, dir bool, r io.Reader) error {
+		entryPath, err := archivePath("/", name)
+		if err != nil {
+			return err
+		}
+		if !under(entryPath, from) {
+			return nil
+		}
+		filePath := path.Join(dirname, strings.TrimPrefix(entryPath, from))
+		if dir {
+			if filePath != dirname {
+				fileRecords = append(fileRecords, &models.FileRecord{
+					Opt:      "add",
+					FileType: "d",
+					FilePath: filePath,
+				})
+			}
+			return nil
+		}
+
+		if maxArchiveSize := d.options.MaxArchiveSize; maxArchiveSize > 0 {
+			r = &limitedReader{reader: r, n: maxArchiveSize - extracted, err: ErrArchiveTooLarge}
+		}
+		fileRecord, err := d.storeFile(filePath, r, remaining)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, fileRecord)
+		fileRecords = append(fileRecords, fileRecord)
+		extracted += fileRecord.FileSize
+		if remaining != unlimited {
+			remaining -= fileRecord.FileSize
+		}
+		return nil
+	})
+	if err != nil {
+		d.releaseFiles(stored)
+		return nil, err
+	}
+
+	if replace {
+		fileRecords = append(deletions(baseVolume.FileRecords, dirname), fileRecords...)
+	}
+	volume, err := d.createVolume(baseVolume, baseVolume.CreatedBy, fileRecords)
+	if err != nil {
+		d.releaseFiles(stored)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// archivePath returns where an archive entry is extracted to in dirname. Entries with ".."
+// elements are rejected rather than cleaned, as the archive is not what it claims to be.
+func archivePath(dirname, name string) (string, error) {
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return "", errors.Wrapf(ErrInvalidPath, "archive entry %s", name)
+		}
+	}
+	return path.Join(dirname, path.Clean("/"+name)), nil
+}
+
+// openArchive detects the format of the archive by its leading bytes, and checks the entry count
+// and declared sizes against the archive limits where the format allows it up front.
+func (d DefaultService) openArchive(archive io.ReaderAt, size int64) (archiveWalker, error) {
+	magic := make([]byte, 4)
+	n, err := archive.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK")):
+		return d.openZip(archive, size)
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return d.openTarGz(io.NewSectionReader(archive, 0, size))
+	default:
+		return nil, errors.Wrap(ErrInvalidArchive, "not a zip or tar.gz archive")
+	}
+}
+
+func (d DefaultService) openZip(archive io.ReaderAt, size int64) (archiveWalker, error) {
+	reader, err := zip.NewReader(archive, size)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidArchive, err.Error())
+	}
+	if maxEntries := d.options.MaxArchiveEntries; maxEntries > 0 && int64(len(reader.File)) > maxEntries {
+		return nil, ErrArchiveTooLarge
+	}
+	if maxArchiveSize := d.options.MaxArchiveSize; maxArchiveSize > 0 {
+		declared := uint64(0)
+		for _, f := range reader.File {
+			declared += f.UncompressedSize64
+			if declared > uint64(maxArchiveSize) {
+				return nil, ErrArchiveTooLarge
+			}
+		}
+	}
+
+	return func(fn walkFunc) error {
+		for _, f := range reader.File {
+			mode := f.Mode()
+			if mode.IsDir() {
+				if err := fn(f.Name, true, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			if mode&os.ModeType != 0 {
+				// links and devices have no content to extract
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return errors.Wrap(ErrInvalidArchive, err.Error())
+			}
+			err = fn(f.Name, false, &archiveReader{reader: rc})
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (d DefaultService) openTarGz(archive io.Reader) (archiveWalker, error) {
+	return func(fn walkFunc) error {
+		gz, err := gzip.NewReader(archive)
+		if err != nil {
+			return errors.Wrap(ErrInvalidArchive, err.Error())
+		}
+		defer gz.Close()
+
+		reader := tar.NewReader(gz)
+		entries := int64(0)
+		for {
+			header, err := reader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return errors.Wrap(ErrInvalidArchive, err.Error())
+			}
+			entries++
+			if maxEntries := d.options.MaxArchiveEntries; maxEntries > 0 && entries > maxEntries {
+				return ErrArchiveTooLarge
+			}
+
+			switch header.Typeflag {
+			case tar.TypeDir:
+				err = fn(header.Name, true, nil)
+			case tar.TypeReg:
+				err = fn(header.Name, false, &archiveReader{reader: reader})
+			default:
+				// links and devices have no content to extract
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}, nil
+}
+
+// archiveReader reports failures to decompress the content as ErrInvalidArchive, telling a
+// corrupt upload from a failure to store it.
+type archiveReader struct {
+	reader io.Reader
+}
+
+func (a *archiveReader) Read(p []byte) (int, error) {
+	n, err := a.reader.Read(p)
+	if err != nil && err != io.EOF {
+		err = errors.Wrap(ErrInvalidArchive, err.Error())
+	}
+	return n, err
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func zipArchive(t *testing.T, entries ...string) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for _, entry := range entries {
+		name, content := entry, ""
+		if i := strings.Index(entry, "="); i >= 0 {
+			name, content = entry[:i], entry[i+1:]
+		}
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, entries ...string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	w := tar.NewWriter(gz)
+	for _, entry := range entries {
+		name, content := entry, ""
+		if i := strings.Index(entry, "="); i >= 0 {
+			name, content = entry[:i], entry[i+1:]
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(name, "/") {
+			header.Typeflag = tar.TypeDir
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpenArchive(t *testing.T) {
+	entries := []string{"data/", "data/1.in=1 2", "data/1.out=3"}
+	tests := []struct {
+		name    string
+		archive []byte
+		options Options
+		want    string
+		err     error
+	}{
+		{"zip", zipArchive(t, entries...), Options{}, "/p/data/,/p/data/1.in=1 2,/p/data/1.out=3", nil},
+		{"tar.gz", tarGzArchive(t, entries...), Options{}, "/p/data/,/p/data/1.in=1 2,/p/data/1.out=3", nil},
+		{"unknown format", []byte("plain text"), Options{}, "", ErrInvalidArchive},
+		{"zip traversal", zipArchive(t, "../escape=x"), Options{}, "", ErrInvalidPath},
+		{"tar.gz traversal", tarGzArchive(t, "data/../../escape=x"), Options{}, "", ErrInvalidPath},
+		{"zip entries", zipArchive(t, entries...), Options{MaxArchiveEntries: 2}, "", ErrArchiveTooLarge},
+		{"tar.gz entries", tarGzArchive(t, entries...), Options{MaxArchiveEntries: 2}, "", ErrArchiveTooLarge},
+		{"zip declared size", zipArchive(t, entries...), Options{MaxArchiveSize: 3}, "", ErrArchiveTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := DefaultService{options: &test.options}
+			var got []string
+			walk, err := d.openArchive(bytes.NewReader(test.archive), int64(len(test.archive)))
+			if err == nil {
+				err = walk(func(name string, dir bool, r io.Reader) error {
+					filePath, err := archivePath("/p", name)
+					if err != nil {
+						return err
+					}
+					if dir {
+						got = append(got, filePath+"/")
+						return nil
+					}
+					content, err := ioutil.ReadAll(r)
+					got = append(got, filePath+"="+string(content))
+					return err
+				})
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("walk: got %v, want %v", err, test.err)
+			}
+			if err == nil && strings.Join(got, ",") != test.want {
+				t.Errorf("walk: got %v", got)
+			}
+		})
+	}
+}
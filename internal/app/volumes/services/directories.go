@@ -0,0 +1,216 @@
+package services
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+// under reports whether the cleaned rooted path p is dir or inside it.
+func under(p, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// directories returns the directories of resolved file records, which are directory records
+// and every ancestor of a record, as cleaned rooted paths.
+func directories(fileRecords models.FileRecords) map[string]bool {
+	dirs := map[string]bool{"/": true}
+	for _, fileRecord := range fileRecords {
+		p := path.Join("/", fileRecord.FilePath)
+		if fileRecord.IsDir() {
+			dirs[p] = true
+		}
+		for p = path.Dir(p); !dirs[p]; p = path.Dir(p) {
+			dirs[p] = true
+		}
+	}
+	return dirs
+}
+
+// deletions returns the records deleting everything in dirname from resolved file records,
+// sorted by path, including the record of the directory itself.
+func deletions(fileRecords models.FileRecords, dirname string) models.FileRecords {
+	deleted := models.FileRecords{}
+	for _, fileRecord := range fileRecords {
+		if !under(path.Join("/", fileRecord.FilePath), dirname) {
+			continue
+		}
+		// the deletion names the path as it was added, which is how layers are resolved
+		deleted = append(deleted, &models.FileRecord{
+			Opt:      "del",
+			FileType: fileRecord.FileType,
+			FilePath: fileRecord.FilePath,
+		})
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		return deleted[i].FilePath < deleted[j].FilePath
+	})
+	return deleted
+}
+
+// resolveExisting returns the base volume with its resolved file records, which must exist.
+func (d DefaultService) resolveExisting(volumeName string) (*models.Volume, error) {
+	volume, err := d.GetVolume(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	if volume.ID == 0 {
+		return nil, ErrVolumeNotFound
+	}
+	return volume, nil
+}
+
+// createLayer creates a volume layer on top of the base volume with the file records,
+// referencing their blobs. Records without content are attributed to the new layer.
+func (d DefaultService) createLayer(baseVolume *models.Volume, fileRecords models.FileRecords) (*models.Volume, error) {
+	if err := d.acquireFiles(fileRecords); err != nil {
+		return nil, err
+	}
+	volume, err := d.createVolume(baseVolume, baseVolume.CreatedBy, fileRecords)
+	if err != nil {
+		d.releaseFiles(fileRecords)
+		return nil, err
+	}
+	return volume, nil
+}
+
+// CreateDirectory creates the directory and its missing parents in a new layer. If the directory
+// already exists, the base volume is returned as it is.
+func (d DefaultService) CreateDirectory(baseVolumeName, dirname string) (*models.Volume, error) {
+	dirname = path.Join("/", dirname)
+	if dirname == "/" {
+		return nil, ErrInvalidPath
+	}
+	baseVolume, err := d.resolveExisting(baseVolumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := directories(baseVolume.FileRecords)
+	files := fileMap(baseVolume.FileRecords)
+	fileRecords := models.FileRecords{}
+	for p := dirname; !dirs[p]; p = path.Dir(p) {
+		if _, ok := files[p]; ok {
+			return nil, ErrFileExists
+		}
+		fileRecords = append(models.FileRecords{
+			&models.FileRecord{
+				Opt:      "add",
+				FileType: "d",
+				FilePath: p,
+			},
+		}, fileRecords...)
+	}
+	if len(fileRecords) == 0 {
+		return baseVolume, nil
+	}
+	return d.createLayer(baseVolume, fileRecords)
+}
+
+// RemoveDirectory deletes the directory and everything in it in a new layer.
+func (d DefaultService) RemoveDirectory(baseVolumeName, dirname string) (*models.Volume, error) {
+	dirname = path.Join("/", dirname)
+	if dirname == "/" {
+		return nil, ErrInvalidPath
+	}
+	baseVolume, err := d.resolveExisting(baseVolumeName)
+	if err != nil {
+		return nil, err
+	}
+	if !directories(baseVolume.FileRecords)[dirname] {
+		return nil, ErrPathNotFound
+	}
+
+	return d.createLayer(baseVolume, deletions(baseVolume.FileRecords, dirname))
+}
+
+// Move renames a file or a directory with everything in it in a new layer.
+// The destination must not exist and must not be inside the source.
+func (d DefaultService) Move(baseVolumeName, from, to string) (*models.Volume, error) {
+	from, to = path.Join("/", from), path.Join("/", to)
+	if from == "/" || to == "/" || under(to, from) {
+		return nil, ErrInvalidPath
+	}
+	baseVolume, err := d.resolveExisting(baseVolumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := directories(baseVolume.FileRecords)
+	files := fileMap(baseVolume.FileRecords)
+	if _, ok := files[from]; !ok && !dirs[from] {
+		return nil, ErrPathNotFound
+	}
+	if _, ok := files[to]; ok || dirs[to] {
+		return nil, ErrFileExists
+	}
+	if file, ok := files[path.Dir(to)]; ok && !file.IsDir() {
+		return nil, ErrFileExists
+	}
+
+	var moved models.FileRecords
+	for _, fileRecord := range baseVolume.FileRecords {
+		if under(path.Join("/", fileRecord.FilePath), from) {
+			moved = append(moved, fileRecord)
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool {
+		return moved[i].FilePath < moved[j].FilePath
+	})
+
+	fileRecords := models.FileRecords{}
+	for _, fileRecord := range moved {
+		fileRecords = append(fileRecords, &models.FileRecord{
+			Opt:      "del",
+			FileType: fileRecord.FileType,
+			FilePath: fileRecord.FilePath,
+		})
+	}
+	for _, fileRecord := range moved {
+		target := *fileRecord
+		target.Opt = "add"
+		target.FilePath = to + strings.TrimPrefix(path.Join("/", fileRecord.FilePath), from)
+		fileRecords = append(fileRecords, &target)
+	}
+	return d.createLayer(baseVolume, fileRecords)
+}
+
+// ListDirectory returns the files and directories in dirname sorted by path, including directories
+// which only exist as parents of files. Entries in subdirectories are included only if recursive is set.
+func (d DefaultService) ListDirectory(volumeName, dirname string, recursive bool) (models.FileRecords, error) {
+	dirname = path.Join("/", dirname)
+	volume, err := d.resolveExisting(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	dirs := directories(volume.FileRecords)
+	if !dirs[dirname] {
+		return nil, ErrPathNotFound
+	}
+
+	inside := func(p string) bool {
+		return p != dirname && under(p, dirname) && (recursive || path.Dir(p) == dirname)
+	}
+	files := fileMap(volume.FileRecords)
+	fileRecords := models.FileRecords{}
+	for p, fileRecord := range files {
+		if inside(p) {
+			fileRecords = append(fileRecords, fileRecord)
+		}
+	}
+	for p := range dirs {
+		if _, ok := files[p]; !ok && inside(p) {
+			fileRecords = append(fileRecords, &models.FileRecord{
+				Opt:      "add",
+				FileType: "d",
+				FilePath: p,
+			})
+		}
+	}
+	sort.Slice(fileRecords, func(i, j int) bool {
+		return path.Join("/", fileRecords[i].FilePath) < path.Join("/", fileRecords[j].FilePath)
+	})
+	return fileRecords, nil
+}
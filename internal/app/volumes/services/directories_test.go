@@ -0,0 +1,23 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestDirectories(t *testing.T) {
+	dirs := directories(files("/data/1.in=a", "/data/sub/2.in=b", "empty/"))
+
+	var got []string
+	for dir := range dirs {
+		got = append(got, dir)
+	}
+	sort.Strings(got)
+	if strings.Join(got, ",") != "/,/data,/data/sub,/empty" {
+		t.Errorf("directories: got %v", got)
+	}
+	if dirs["/data/1.in"] {
+		t.Error("file is a directory")
+	}
+}
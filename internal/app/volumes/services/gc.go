@@ -0,0 +1,178 @@
+package services
+
+import (
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var (
+	gcRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "volume_gc",
+		Name:      "runs_total",
+		Help:      "Collections of unreachable volumes, by result.",
+	}, []string{"result"})
+	gcVolumes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "volume_gc",
+		Name:      "volumes_deleted_total",
+		Help:      "Volume layers deleted as unreachable.",
+	})
+	gcBlobs = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "volume_gc",
+		Name:      "blobs_deleted_total",
+		Help:      "Blobs deleted as no file record references them.",
+	})
+	gcBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "volume_gc",
+		Name:      "bytes_reclaimed_total",
+		Help:      "Bytes of storage reclaimed by deleting blobs.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gcRuns, gcVolumes, gcBlobs, gcBytes)
+}
+
+// GCOptions configures the collection of volumes no longer reachable.
+type GCOptions struct {
+	// Interval is the time between background collections, zero disables them.
+	Interval time.Duration `yaml:"interval"`
+	// Grace is how long a volume is kept after it is created, so drafts being worked on
+	// and judgements in progress are not collected before they are referenced.
+	Grace time.Duration `yaml:"grace"`
+}
+
+func NewGCOptions(v *viper.Viper, logger *zap.Logger) (*GCOptions, error) {
+	o := &GCOptions{
+		Interval: 6 * time.Hour,
+		Grace:    7 * 24 * time.Hour,
+	}
+	if err := v.UnmarshalKey("volumes.gc", o); err != nil {
+		return nil, errors.Wrap(err, "unmarshal volume gc option error")
+	}
+	if o.Interval < 0 || o.Grace < 0 {
+		return nil, errors.New("invalid volume gc options")
+	}
+
+	logger.Info("load volume gc options success",
+		zap.Duration("interval", o.Interval),
+		zap.Duration("grace", o.Grace),
+	)
+
+	return o, nil
+}
+
+// GCReport lists the volumes a collection deleted, or would delete on a dry run, with the blobs
+// and bytes reclaimed. A dry run only counts blobs referenced by nothing but the volumes listed,
+// while a collection also removes blobs left unreferenced before.
+type GCReport struct {
+	DryRun  bool     `json:"dry_run"`
+	Volumes []string `json:"volumes"`
+	Blobs   int      `json:"blobs"`
+	Bytes   int64    `json:"bytes"`
+}
+
+// CollectVolumes finds volumes older than the grace period which no problem, submission,
+// judgement, cached process output or tag references, directly or through a layer on top.
+// Unless dryRun is set, they are deleted along with their references to blobs, and unreferenced
+// blobs are removed.
+func (d DefaultService) CollectVolumes(dryRun bool) (report *GCReport, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	defer func() {
+		result := "success"
+		if err != nil {
+			result = "error"
+		}
+		if !dryRun {
+			gcRuns.WithLabelValues(result).Inc()
+		}
+	}()
+
+	volumes, err := d.Repository.GetUnreachableVolumes(time.Now().Add(-d.gcOptions.Grace))
+	if err != nil {
+		return nil, err
+	}
+	report = &GCReport{
+		DryRun:  dryRun,
+		Volumes: []string{},
+	}
+	for _, volume := range volumes {
+		report.Volumes = append(report.Volumes, volume.Name)
+	}
+
+	if dryRun {
+		report.Blobs, report.Bytes, err = d.reclaimable(volumes)
+		return report, err
+	}
+
+	for _, volume := range volumes {
+		// the layer goes first, a failure in between leaves a blob referenced rather than missing
+		if err := d.Repository.DeleteVolume(volume.ID); err != nil {
+			return report, err
+		}
+		gcVolumes.Inc()
+		d.releaseFiles(volume.FileRecords)
+		if err := d.Storage.RemoveVolume(volume.Name); err != nil {
+			d.logger.Debug("remove volume directory", zap.String("volume", volume.Name), zap.Error(err))
+		}
+	}
+	report.Blobs, report.Bytes, err = d.collectBlobs()
+	if err != nil {
+		return report, err
+	}
+
+	d.logger.Info("collect volumes",
+		zap.Int("volumes", len(volumes)),
+		zap.Int("blobs", report.Blobs),
+		zap.Int64("bytes", report.Bytes),
+	)
+	return report, nil
+}
+
+// reclaimable returns the blobs, and their total size, which nothing but the volumes references.
+func (d DefaultService) reclaimable(volumes []*models.Volume) (int, int64, error) {
+	references := map[string]int64{}
+	var hashes []string
+	for _, volume := range volumes {
+		for _, fileRecord := range volume.FileRecords {
+			if fileRecord.Hash == "" {
+				continue
+			}
+			if references[fileRecord.Hash] == 0 {
+				hashes = append(hashes, fileRecord.Hash)
+			}
+			references[fileRecord.Hash]++
+		}
+	}
+
+	blobs, err := d.Repository.GetBlobs(hashes)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, size := 0, int64(0)
+	for _, blob := range blobs {
+		if blob.RefCount <= references[blob.Hash] {
+			count++
+			size += blob.Size
+		}
+	}
+	return count, size, nil
+}
+
+// runGC collects volumes every interval for the lifetime of the process.
+func (d DefaultService) runGC() {
+	ticker := time.NewTicker(d.gcOptions.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := d.CollectVolumes(false); err != nil {
+			d.logger.Error("collect volumes", zap.Error(err))
+		}
+	}
+}
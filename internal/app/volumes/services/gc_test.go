@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
+	"github.com/infinity-oj/server-v2/internal/app/volumes/storages"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// gcRepository keeps volumes and blob reference counts in memory, other methods are not used.
+type gcRepository struct {
+	repositories.Repository
+	unreachable []*models.Volume
+	blobs       map[string]*models.Blob
+	deleted     []uint64
+}
+
+func (r *gcRepository) GetUnreachableVolumes(createdBefore time.Time) ([]*models.Volume, error) {
+	return r.unreachable, nil
+}
+
+func (r *gcRepository) DeleteVolume(volumeID uint64) error {
+	r.deleted = append(r.deleted, volumeID)
+	return nil
+}
+
+func (r *gcRepository) GetBlobs(hashes []string) ([]*models.Blob, error) {
+	var blobs []*models.Blob
+	for _, hash := range hashes {
+		if blob, ok := r.blobs[hash]; ok {
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs, nil
+}
+
+func (r *gcRepository) ReleaseBlob(hash string) error {
+	r.blobs[hash].RefCount--
+	return nil
+}
+
+func (r *gcRepository) GetUnreferencedBlobs() ([]*models.Blob, error) {
+	var blobs []*models.Blob
+	for _, blob := range r.blobs {
+		if blob.RefCount <= 0 {
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs, nil
+}
+
+func (r *gcRepository) DeleteBlob(hash string) (bool, error) {
+	delete(r.blobs, hash)
+	return true, nil
+}
+
+// gcStorage accepts removals, other methods are not used.
+type gcStorage struct {
+	storages.Storage
+	removed []string
+}
+
+func (s *gcStorage) RemoveVolume(volume string) error {
+	return nil
+}
+
+func (s *gcStorage) DeleteBlob(hash string) error {
+	s.removed = append(s.removed, hash)
+	return nil
+}
+
+func TestCollectVolumes(t *testing.T) {
+	newRepository := func() *gcRepository {
+		return &gcRepository{
+			unreachable: []*models.Volume{
+				{Model: models.Model{ID: 1}, Name: "draft", FileRecords: files("/a=shared", "/b=only")},
+				{Model: models.Model{ID: 2}, Name: "layer", FileRecords: files("/c=only")},
+			},
+			blobs: map[string]*models.Blob{
+				// shared is also referenced by a reachable volume
+				"shared": {Hash: "shared", Size: 10, RefCount: 2},
+				"only":   {Hash: "only", Size: 5, RefCount: 2},
+			},
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		repo := newRepository()
+		service := NewVolumeService(zap.NewNop(), &Options{}, &GCOptions{}, &gcStorage{}, repo)
+
+		report, err := service.CollectVolumes(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(report.Volumes, ",") != "draft,layer" || report.Blobs != 1 || report.Bytes != 5 {
+			t.Errorf("report: got %+v", report)
+		}
+		if len(repo.deleted) != 0 || len(repo.blobs) != 2 {
+			t.Errorf("dry run deleted volumes %v", repo.deleted)
+		}
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		repo := newRepository()
+		sto := &gcStorage{}
+		service := NewVolumeService(zap.NewNop(), &Options{}, &GCOptions{}, sto, repo)
+
+		report, err := service.CollectVolumes(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Blobs != 1 || report.Bytes != 5 {
+			t.Errorf("report: got %+v", report)
+		}
+		if len(repo.deleted) != 2 || strings.Join(sto.removed, ",") != "only" {
+			t.Errorf("deleted volumes %v, blobs %v", repo.deleted, sto.removed)
+		}
+		if repo.blobs["shared"].RefCount != 1 {
+			t.Errorf("shared blob: got %d references", repo.blobs["shared"].RefCount)
+		}
+	})
+}
+
+// recordingDriver records the queries run on its connections, which return no rows.
+type recordingDriver struct {
+	queries []string
+}
+
+func (d *recordingDriver) Open(name string) (driver.Conn, error) {
+	return recordingConn{d}, nil
+}
+
+type recordingConn struct {
+	d *recordingDriver
+}
+
+func (c recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c recordingConn) Close() error {
+	return nil
+}
+
+func (c recordingConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c recordingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.queries = append(c.d.queries, query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string {
+	return nil
+}
+
+func (emptyRows) Close() error {
+	return nil
+}
+
+func (emptyRows) Next(dest []driver.Value) error {
+	return io.EOF
+}
+
+var gcDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("gc-recording", gcDriver)
+}
+
+func TestCollectVolumesRoots(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: "gc-recording"}), &gorm.Config{
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := repositories.NewRepository(zap.NewNop(), db)
+	service := NewVolumeService(zap.NewNop(), &Options{}, &GCOptions{}, &gcStorage{}, repo)
+
+	if _, err := service.CollectVolumes(true); err != nil {
+		t.Fatal(err)
+	}
+	if len(gcDriver.queries) == 0 {
+		t.Fatal("no query run")
+	}
+	// a volume packed into a cached archive is referenced as volume/archive.zip
+	query := gcDriver.queries[0]
+	for _, root := range []string{"split_part(slot->>'value', '/', 1)", "IN ('volume', 'file')"} {
+		if !strings.Contains(query, root) {
+			t.Errorf("cached file outputs are not roots: %s", query)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"path"
+	"sort"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var ErrNoCommonBase = errors.New("volumes share no base")
+
+// FileChange is a path which differs between two volumes.
+type FileChange struct {
+	Path     string `json:"path"`
+	FileType string `json:"file_type"`
+	OldHash  string `json:"old_hash,omitempty"`
+	NewHash  string `json:"new_hash,omitempty"`
+}
+
+// Diff lists paths added, removed and modified from one volume to another, sorted by path.
+type Diff struct {
+	Added    []*FileChange `json:"added"`
+	Removed  []*FileChange `json:"removed"`
+	Modified []*FileChange `json:"modified"`
+}
+
+// MergeConflict is a path both sides of a merge changed differently since their common base.
+// An empty hash of a side means the path is absent there.
+type MergeConflict struct {
+	Path       string `json:"path"`
+	BaseHash   string `json:"base_hash,omitempty"`
+	OursHash   string `json:"ours_hash,omitempty"`
+	TheirsHash string `json:"theirs_hash,omitempty"`
+}
+
+// fileMap indexes file records by their cleaned path.
+func fileMap(fileRecords models.FileRecords) map[string]*models.FileRecord {
+	mp := make(map[string]*models.FileRecord, len(fileRecords))
+	for _, fileRecord := range fileRecords {
+		mp[path.Join("/", fileRecord.FilePath)] = fileRecord
+	}
+	return mp
+}
+
+// sameFile reports whether two records, either of which may be nil, have the same type and content.
+func sameFile(a, b *models.FileRecord) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.FileType != b.FileType {
+		return false
+	}
+	if a.IsDir() {
+		return true
+	}
+	if ha, hb := a.ContentHash(), b.ContentHash(); ha != "" || hb != "" {
+		return ha == hb
+	}
+	return a.VolumeName == b.VolumeName && a.VolumePath == b.VolumePath
+}
+
+func hashOf(fileRecord *models.FileRecord) string {
+	if fileRecord == nil {
+		return ""
+	}
+	return fileRecord.ContentHash()
+}
+
+func sortedPaths(maps ...map[string]*models.FileRecord) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, mp := range maps {
+		for p := range mp {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// diffFileRecords compares the files of volume a to the files of volume b.
+func diffFileRecords(a, b models.FileRecords) *Diff {
+	diff := &Diff{
+		Added:    []*FileChange{},
+		Removed:  []*FileChange{},
+		Modified: []*FileChange{},
+	}
+	am, bm := fileMap(a), fileMap(b)
+	for _, p := range sortedPaths(am, bm) {
+		old, cur := am[p], bm[p]
+		switch {
+		case old == nil:
+			diff.Added = append(diff.Added, &FileChange{Path: p, FileType: cur.FileType, NewHash: hashOf(cur)})
+		case cur == nil:
+			diff.Removed = append(diff.Removed, &FileChange{Path: p, FileType: old.FileType, OldHash: hashOf(old)})
+		case !sameFile(old, cur):
+			diff.Modified = append(diff.Modified, &FileChange{Path: p, FileType: cur.FileType, OldHash: hashOf(old), NewHash: hashOf(cur)})
+		}
+	}
+	return diff
+}
+
+// mergeFileRecords merges the changes theirs made since base into ours. It returns the file records
+// of a layer on top of ours applying those changes, or the conflicts if both sides changed a path differently.
+func mergeFileRecords(base, ours, theirs models.FileRecords) (models.FileRecords, []*MergeConflict) {
+	bm, om, tm := fileMap(base), fileMap(ours), fileMap(theirs)
+
+	fileRecords := models.FileRecords{}
+	var conflicts []*MergeConflict
+	for _, p := range sortedPaths(bm, om, tm) {
+		b, o, t := bm[p], om[p], tm[p]
+		if sameFile(b, t) || sameFile(o, t) {
+			// theirs did not change it, or both made the same change
+			continue
+		}
+		if !sameFile(b, o) {
+			conflicts = append(conflicts, &MergeConflict{
+				Path:       p,
+				BaseHash:   hashOf(b),
+				OursHash:   hashOf(o),
+				TheirsHash: hashOf(t),
+			})
+			continue
+		}
+		if t == nil {
+			fileRecords = append(fileRecords, &models.FileRecord{
+				Opt:      "del",
+				FileType: o.FileType,
+				FilePath: o.FilePath,
+			})
+			continue
+		}
+		fileRecord := *t
+		fileRecord.Opt = "add"
+		fileRecords = append(fileRecords, &fileRecord)
+	}
+	if len(conflicts) > 0 {
+		return nil, conflicts
+	}
+	return fileRecords, nil
+}
+
+// DiffVolumes compares the files of volume a to the files of volume b.
+func (d DefaultService) DiffVolumes(a, b string) (*Diff, error) {
+	va, err := d.GetHistory(a)
+	if err != nil {
+		return nil, err
+	}
+	vb, err := d.GetHistory(b)
+	if err != nil {
+		return nil, err
+	}
+	return diffFileRecords(resolveFileRecords(va), resolveFileRecords(vb)), nil
+}
+
+// MergeVolumes creates a layer on top of ours with the changes theirs made since their nearest
+// common base. If both changed a path differently, no layer is created and the conflicts are returned.
+// If theirs made no change ours does not have, ours is returned as it is.
+func (d DefaultService) MergeVolumes(ours, theirs string) (*models.Volume, []*MergeConflict, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	oursChain, err := d.GetHistory(ours)
+	if err != nil {
+		return nil, nil, err
+	}
+	theirsChain, err := d.GetHistory(theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	index := make(map[uint64]int, len(oursChain))
+	for i, volume := range oursChain {
+		index[volume.ID] = i
+	}
+	base := -1
+	for i := len(theirsChain) - 1; i >= 0 && base < 0; i-- {
+		if j, ok := index[theirsChain[i].ID]; ok {
+			base = j
+		}
+	}
+	if base < 0 {
+		return nil, nil, ErrNoCommonBase
+	}
+
+	fileRecords, conflicts := mergeFileRecords(
+		resolveFileRecords(oursChain[:base+1]),
+		resolveFileRecords(oursChain),
+		resolveFileRecords(theirsChain),
+	)
+	oursVolume := oursChain[len(oursChain)-1]
+	if len(conflicts) > 0 {
+		return nil, conflicts, nil
+	}
+	if len(fileRecords) == 0 {
+		return oursVolume, nil, nil
+	}
+
+	volume, err := d.createLayer(oursVolume, fileRecords)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	d.logger.Info("merge volumes",
+		zap.String("ours", oursVolume.Name),
+		zap.String("theirs", theirsChain[len(theirsChain)-1].Name),
+		zap.Int("changes", len(fileRecords)),
+	)
+	return volume, nil, nil
+}
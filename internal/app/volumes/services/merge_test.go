@@ -0,0 +1,122 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+// files builds file records from "path=hash" entries, a path ending with a slash is a directory.
+func files(entries ...string) models.FileRecords {
+	fileRecords := models.FileRecords{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if strings.HasSuffix(parts[0], "/") {
+			fileRecords = append(fileRecords, &models.FileRecord{Opt: "add", FileType: "d", FilePath: strings.TrimSuffix(parts[0], "/")})
+			continue
+		}
+		fileRecords = append(fileRecords, &models.FileRecord{Opt: "add", FileType: "f", FilePath: parts[0], Hash: parts[1]})
+	}
+	return fileRecords
+}
+
+func changes(fileChanges []*FileChange) string {
+	var res []string
+	for _, c := range fileChanges {
+		res = append(res, c.Path+":"+c.OldHash+">"+c.NewHash)
+	}
+	return strings.Join(res, ",")
+}
+
+func TestDiffFileRecords(t *testing.T) {
+	diff := diffFileRecords(
+		files("/1.in=a", "/1.ans=b", "/2.in=c", "/data/"),
+		files("/1.in=a", "/1.ans=x", "/3.in=d", "/data/"),
+	)
+	if got := changes(diff.Added); got != "/3.in:>d" {
+		t.Errorf("added: got %s", got)
+	}
+	if got := changes(diff.Removed); got != "/2.in:c>" {
+		t.Errorf("removed: got %s", got)
+	}
+	if got := changes(diff.Modified); got != "/1.ans:b>x" {
+		t.Errorf("modified: got %s", got)
+	}
+}
+
+func TestMergeFileRecords(t *testing.T) {
+	type test struct {
+		name      string
+		base      models.FileRecords
+		ours      models.FileRecords
+		theirs    models.FileRecords
+		records   string
+		conflicts string
+	}
+
+	tests := []test{
+		{
+			name:    "theirs adds and modifies",
+			base:    files("/1.in=a"),
+			ours:    files("/1.in=a", "/2.in=b"),
+			theirs:  files("/1.in=x", "/3.in=c"),
+			records: "add /1.in x,add /3.in c",
+		},
+		{
+			name:    "theirs deletes",
+			base:    files("/1.in=a", "/2.in=b"),
+			ours:    files("/1.in=a", "/2.in=b"),
+			theirs:  files("/1.in=a"),
+			records: "del /2.in ",
+		},
+		{
+			name:   "ours changes only",
+			base:   files("/1.in=a"),
+			ours:   files("/1.in=x"),
+			theirs: files("/1.in=a"),
+		},
+		{
+			name:   "same change on both sides",
+			base:   files("/1.in=a"),
+			ours:   files("/1.in=x"),
+			theirs: files("/1.in=x"),
+		},
+		{
+			name:      "both modify",
+			base:      files("/1.in=a", "/2.in=b"),
+			ours:      files("/1.in=x", "/2.in=b"),
+			theirs:    files("/1.in=y", "/2.in=c"),
+			conflicts: "/1.in a x y",
+		},
+		{
+			name:      "ours deletes, theirs modifies",
+			base:      files("/1.in=a"),
+			ours:      files(),
+			theirs:    files("/1.in=y"),
+			conflicts: "/1.in a  y",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileRecords, conflicts := mergeFileRecords(tt.base, tt.ours, tt.theirs)
+
+			var records []string
+			for _, r := range fileRecords {
+				records = append(records, r.Opt+" "+r.FilePath+" "+r.Hash)
+			}
+			if got := strings.Join(records, ","); got != tt.records {
+				t.Errorf("records: got %q, want %q", got, tt.records)
+			}
+
+			var cs []string
+			for _, c := range conflicts {
+				cs = append(cs, strings.Join([]string{c.Path, c.BaseHash, c.OursHash, c.TheirsHash}, " "))
+			}
+			if got := strings.Join(cs, ","); got != tt.conflicts {
+				t.Errorf("conflicts: got %q, want %q", got, tt.conflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrFileTooLarge  = errors.New("file too large")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// unlimited is the remaining bytes of an account without a quota.
+const unlimited = -1
+
+// Options limits what an account may store in volumes. A limit of zero means unlimited.
+// Volumes created by the system, with no owner, are not limited.
+type Options struct {
+	// MaxFileSize is the size limit in bytes of a single stored file.
+	MaxFileSize int64 `yaml:"max_file_size" mapstructure:"max_file_size"`
+	// MaxBytes is the limit of bytes stored in the volumes of an account.
+	MaxBytes int64 `yaml:"max_bytes" mapstructure:"max_bytes"`
+	// MaxVolumes is the limit of volumes an account creates.
+	MaxVolumes int64 `yaml:"max_volumes" mapstructure:"max_volumes"`
+
+	// MaxArchiveSize is the limit of bytes extracted from an uploaded archive, also for the system.
+	MaxArchiveSize int64 `yaml:"max_archive_size" mapstructure:"max_archive_size"`
+	// MaxArchiveEntries is the limit of files and directories in an uploaded archive.
+	MaxArchiveEntries int64 `yaml:"max_archive_entries" mapstructure:"max_archive_entries"`
+}
+
+func NewOptions(v *viper.Viper, logger *zap.Logger) (*Options, error) {
+	// archives are bounded unless configured otherwise, as a small one may unpack to anything
+	v.SetDefault("volumes.quota.max_archive_size", 1<<30)
+	v.SetDefault("volumes.quota.max_archive_entries", 10000)
+
+	o := new(Options)
+	if err := v.UnmarshalKey("volumes.quota", o); err != nil {
+		return nil, err
+	}
+
+	logger.Info("load volume quota configuration success")
+
+	return o, nil
+}
+
+// Usage is what an account stores in volumes, along with its limits.
+type Usage struct {
+	Volumes int64 `json:"volumes"`
+	Bytes   int64 `json:"bytes"`
+
+	MaxVolumes  int64 `json:"max_volumes"`
+	MaxBytes    int64 `json:"max_bytes"`
+	MaxFileSize int64 `json:"max_file_size"`
+}
+
+// GetUsage returns the volumes and bytes stored by the account. Bytes count each distinct content
+// once, from the sizes in file records, so copies and layers of the same file are free.
+func (d DefaultService) GetUsage(accountID uint64) (*Usage, error) {
+	volumes, err := d.Repository.CountVolumes(accountID)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := d.Repository.CountStoredBytes(accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &Usage{
+		Volumes:     volumes,
+		Bytes:       bytes,
+		MaxVolumes:  d.options.MaxVolumes,
+		MaxBytes:    d.options.MaxBytes,
+		MaxFileSize: d.options.MaxFileSize,
+	}, nil
+}
+
+func (d DefaultService) Limits() Options {
+	return *d.options
+}
+
+// checkVolumeQuota fails with ErrQuotaExceeded if the account may not create another volume.
+func (d DefaultService) checkVolumeQuota(accountID uint64) error {
+	if accountID == 0 || d.options.MaxVolumes <= 0 {
+		return nil
+	}
+	volumes, err := d.Repository.CountVolumes(accountID)
+	if err != nil {
+		return err
+	}
+	if volumes >= d.options.MaxVolumes {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// remainingBytes returns how many bytes the account may still store, or -1 if it is unlimited.
+func (d DefaultService) remainingBytes(accountID uint64) (int64, error) {
+	if accountID == 0 || d.options.MaxBytes <= 0 {
+		return unlimited, nil
+	}
+	bytes, err := d.Repository.CountStoredBytes(accountID)
+	if err != nil {
+		return 0, err
+	}
+	if bytes >= d.options.MaxBytes {
+		return 0, ErrQuotaExceeded
+	}
+	return d.options.MaxBytes - bytes, nil
+}
+
+// limit bounds the reader by the file size limit and the remaining bytes, -1 if unlimited,
+// so an oversized file fails while it is streamed rather than after it is stored.
+func (d DefaultService) limit(reader io.Reader, remaining int64) io.Reader {
+	maxFileSize := d.options.MaxFileSize
+	if maxFileSize > 0 && (remaining < 0 || maxFileSize <= remaining) {
+		return &limitedReader{reader: reader, n: maxFileSize, err: ErrFileTooLarge}
+	}
+	if remaining >= 0 {
+		return &limitedReader{reader: reader, n: remaining, err: ErrQuotaExceeded}
+	}
+	return reader
+}
+
+// limitedReader fails with err once more than n bytes are read.
+type limitedReader struct {
+	reader io.Reader
+	n      int64
+	err    error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// read one byte beyond the limit to tell a file of exactly n bytes from a larger one
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.reader.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, l.err
+	}
+	return n, err
+}
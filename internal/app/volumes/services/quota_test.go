@@ -0,0 +1,104 @@
+package services
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
+	"github.com/infinity-oj/server-v2/internal/app/volumes/storages"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxFileSize int64
+		remaining   int64
+		content     string
+		err         error
+	}{
+		{"unlimited", 0, unlimited, "abcdef", nil},
+		{"within file size", 6, unlimited, "abcdef", nil},
+		{"file too large", 5, unlimited, "abcdef", ErrFileTooLarge},
+		{"within quota", 0, 6, "abcdef", nil},
+		{"quota exceeded", 0, 5, "abcdef", ErrQuotaExceeded},
+		{"quota below file size", 10, 3, "abcdef", ErrQuotaExceeded},
+		{"file size below quota", 3, 10, "abcdef", ErrFileTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := DefaultService{options: &Options{MaxFileSize: test.maxFileSize}}
+			content, err := ioutil.ReadAll(d.limit(strings.NewReader(test.content), test.remaining))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("read: got %v, want %v", err, test.err)
+			}
+			if err == nil && string(content) != test.content {
+				t.Errorf("read: got %q", content)
+			}
+		})
+	}
+}
+
+// quotaRepository holds a volume of an account which stored bytes already, other methods are not used.
+type quotaRepository struct {
+	repositories.Repository
+	bytes   int64
+	created int
+}
+
+func (r *quotaRepository) GetVolume(volumeName string) (*models.Volume, error) {
+	return &models.Volume{Model: models.Model{ID: 1}, CreatedBy: 7, Name: volumeName}, nil
+}
+
+func (r *quotaRepository) CountStoredBytes(accountID uint64) (int64, error) {
+	return r.bytes, nil
+}
+
+func (r *quotaRepository) CreateVolume(baseVolume *models.Volume, accountId uint64, volumeName string, fileRecords models.FileRecords) (*models.Volume, error) {
+	r.created++
+	return &models.Volume{Base: baseVolume.ID, CreatedBy: accountId, Name: volumeName, FileRecords: fileRecords}, nil
+}
+
+// quotaStorage reads blobs without storing them, other methods are not used.
+type quotaStorage struct {
+	storages.Storage
+}
+
+func (s quotaStorage) PutBlob(r io.Reader) (string, int64, error) {
+	n, err := io.Copy(ioutil.Discard, r)
+	return "hash", n, err
+}
+
+func TestCreateFileQuota(t *testing.T) {
+	tests := []struct {
+		name  string
+		bytes int64
+		err   error
+	}{
+		{"quota used up", 10, ErrQuotaExceeded},
+		{"file exceeds quota", 7, ErrQuotaExceeded},
+		{"file too large", 0, ErrFileTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &quotaRepository{bytes: test.bytes}
+			options := &Options{MaxFileSize: 5, MaxBytes: 10}
+			service := NewVolumeService(zap.NewNop(), options, &GCOptions{}, quotaStorage{}, repo)
+
+			_, err := service.CreateFile("draft", "/", "a", strings.NewReader("abcdef"))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("create file: got %v, want %v", err, test.err)
+			}
+			// a rejected upload must not leave a layer counted as a volume
+			if repo.created != 0 {
+				t.Errorf("created %d volumes", repo.created)
+			}
+		})
+	}
+}
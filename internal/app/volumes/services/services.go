@@ -2,4 +2,4 @@ package services
 
 import "github.com/google/wire"
 
-var ProviderSet = wire.NewSet(NewVolumeService)
+var ProviderSet = wire.NewSet(NewOptions, NewGCOptions, NewVolumeService)
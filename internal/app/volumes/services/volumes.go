@@ -2,15 +2,15 @@ package services
 
 import (
 	"fmt"
-	"os"
+	"io"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/pkg/errors"
 
-	"github.com/infinity-oj/server-v2/internal/pkg/crypto"
-
 	"github.com/google/uuid"
 	"github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
 	"github.com/infinity-oj/server-v2/internal/app/volumes/storages"
@@ -22,25 +22,67 @@ type Service interface {
 	CreateVolume(createdBy uint64) (*models.Volume, error)
 
 	CreateDirectory(baseVolumeName, dirname string) (*models.Volume, error)
-	CreateFile(baseVolumeName, dirname, filename string, file []byte) (*models.Volume, error)
+	CreateFile(baseVolumeName, dirname, filename string, file io.Reader) (*models.Volume, error)
 	RemoveFile(baseVolumeName, dirname, filename string) (*models.Volume, error)
 	CopyFile(ov, od, of, nv, nd, nf string) (*models.Volume, error)
+	ExtractArchive(baseVolumeName, dirname string, archive io.ReaderAt, size int64, replace bool) (*models.Volume, error)
+	ExtractArchiveTree(baseVolumeName, dirname, from string, archive io.ReaderAt, size int64) (*models.Volume, error)
 
 	GetVolume(volumeName string) (*models.Volume, error)
-	GetDirectory(volumeName, dirname string) (file *os.File, err error)
-	GetFile(volumeName, filename string) (*os.File, error)
+	// GetDirectory writes a zip archive of the volume directory to w.
+	GetDirectory(volumeName, dirname string, w io.Writer) error
+	// GetFile opens a file of the volume and returns its size. The caller closes the reader.
+	GetFile(volumeName, filename string) (io.ReadCloser, int64, error)
+	ListFiles(volumeName, dirname, pattern string, recursive bool) (models.FileRecords, error)
+
+	CollectBlobs() (int, error)
+	// CollectVolumes deletes volumes which are no longer reachable, or reports them if dryRun is set.
+	CollectVolumes(dryRun bool) (*GCReport, error)
+
+	SquashVolume(volumeName string) (*models.Volume, error)
+	GetHistory(volumeName string) ([]*models.Volume, error)
+
+	TagVolume(volumeName, tag string, createdBy uint64) (*models.VolumeTag, error)
+	UntagVolume(volumeName, tag string) error
+	GetTags(volumeName string) ([]*models.VolumeTag, error)
+
+	RemoveDirectory(baseVolumeName, dirname string) (*models.Volume, error)
+	Move(baseVolumeName, from, to string) (*models.Volume, error)
+	ListDirectory(volumeName, dirname string, recursive bool) (models.FileRecords, error)
+
+	GetPermission(volumeName string, principal *Principal) (Permission, error)
+
+	DiffVolumes(a, b string) (*Diff, error)
+	MergeVolumes(ours, theirs string) (*models.Volume, []*MergeConflict, error)
+
+	GetUsage(accountID uint64) (*Usage, error)
+	// Limits returns the configured size limits and quotas.
+	Limits() Options
 }
 
+var (
+	ErrVolumeNotFound = errors.New("volume not found")
+	ErrTagNotFound    = errors.New("tag not found")
+	ErrInvalidTag     = errors.New("tag is the name of a volume")
+	ErrPathNotFound   = errors.New("no such file or directory")
+	ErrFileExists     = errors.New("file or directory exists")
+	ErrInvalidPath    = errors.New("invalid path")
+)
+
 type DefaultService struct {
 	logger     *zap.Logger
+	options    *Options
+	gcOptions  *GCOptions
 	Repository repositories.Repository
 	Storage    storages.Storage
 
 	mutex *sync.Mutex
+	// blobMutex keeps the garbage collector from removing a blob while it is being referenced.
+	blobMutex *sync.RWMutex
 }
 
 func (d DefaultService) GetVolume(volumeName string) (*models.Volume, error) {
-	volume, err := d.Repository.GetVolume(volumeName)
+	volume, err := d.lookupVolume(volumeName)
 	if err != nil {
 		return nil, err
 	}
@@ -49,6 +91,14 @@ func (d DefaultService) GetVolume(volumeName string) (*models.Volume, error) {
 		return nil, err
 	}
 
+	volume.FileRecords = resolveFileRecords(volumes)
+
+	return volume, nil
+}
+
+// resolveFileRecords applies the file records of layers, the bottom layer first,
+// and returns the files and directories present in the top layer.
+func resolveFileRecords(volumes []*models.Volume) models.FileRecords {
 	mp := make(map[string]*models.FileRecord)
 
 	for i, _ := range volumes {
@@ -81,31 +131,60 @@ func (d DefaultService) GetVolume(volumeName string) (*models.Volume, error) {
 	for _, v := range mp {
 		fileRecords = append(fileRecords, v)
 	}
-	volume.FileRecords = fileRecords
+	return fileRecords
+}
 
-	return volume, nil
+// lookupVolume finds a volume layer by its name or by a tag pointing at it.
+// Like the repository, it returns an empty volume if neither exists.
+func (d DefaultService) lookupVolume(volumeName string) (*models.Volume, error) {
+	volume, err := d.Repository.GetVolume(volumeName)
+	if err != nil || volume.ID != 0 {
+		return volume, err
+	}
+	tag, err := d.Repository.GetTag(volumeName)
+	if err != nil || tag == nil {
+		return volume, err
+	}
+	return d.Repository.GetVolumeByID(tag.VolumeID)
 }
 
+// GetVolumeChain returns the volume and all its base layers, the bottom layer first.
 func (d DefaultService) GetVolumeChain(volume *models.Volume) ([]*models.Volume, error) {
 	if volume.Base == 0 {
 		// have no previous volume
 		return []*models.Volume{volume}, nil
 	}
-	baseVolume, err := d.Repository.GetVolumeByID(volume.Base)
+	volumes, err := d.Repository.GetVolumeChain(volume.ID)
 	if err != nil {
 		return nil, err
 	}
-	previous, err := d.GetVolumeChain(baseVolume)
-	if err != nil {
-		return nil, err
+	if len(volumes) == 0 {
+		return nil, ErrVolumeNotFound
 	}
-	res := append(previous, volume)
-	return res, nil
+	// the top layer is the given volume, which may carry changes not saved yet
+	volumes[len(volumes)-1] = volume
+	return volumes, nil
 }
 
+// CreateVolume creates an empty volume owned by the account, within its volume quota.
 func (d DefaultService) CreateVolume(accountID uint64) (*models.Volume, error) {
+	if err := d.checkVolumeQuota(accountID); err != nil {
+		return nil, err
+	}
+	return d.createVolume(nil, accountID, nil)
+}
+
+// createVolume creates a volume regardless of quotas, for layers on top of the base volume, with
+// the file records whose blobs are referenced already. Records without content are attributed
+// to the new volume.
+func (d DefaultService) createVolume(baseVolume *models.Volume, accountID uint64, fileRecords models.FileRecords) (*models.Volume, error) {
 	volumeName := uuid.New().String()
-	volume, err := d.Repository.CreateVolume(nil, accountID, volumeName)
+	for _, fileRecord := range fileRecords {
+		if fileRecord.Hash == "" && fileRecord.VolumeName == "" {
+			fileRecord.VolumeName = volumeName
+		}
+	}
+	volume, err := d.Repository.CreateVolume(baseVolume, accountID, volumeName, fileRecords)
 	if err != nil {
 		d.logger.Error("create volume", zap.Error(err))
 		return nil, err
@@ -129,19 +208,25 @@ func (d DefaultService) CopyFile(ov, od, of, nv, nd, nf string) (*models.Volume,
 	oldFilePath := filepath.Join(od, of)
 	for _, fileRecord := range oldVolume.FileRecords {
 		if fileRecord.FilePath == oldFilePath {
-			newVolume, err := d.Repository.GetVolume(nv)
+			newVolume, err := d.lookupVolume(nv)
 			if err != nil {
 				return nil, err
 			}
-			newVolume.FileRecords = append(newVolume.FileRecords,
-				&models.FileRecord{
-					Opt:        "add",
-					FileType:   "f",
-					FilePath:   filepath.Join("/", nd, nf),
-					VolumeName: fileRecord.VolumeName,
-					VolumePath: fileRecord.VolumePath,
-				})
+			newRecord := &models.FileRecord{
+				Opt:        "add",
+				FileType:   "f",
+				FilePath:   filepath.Join("/", nd, nf),
+				VolumeName: fileRecord.VolumeName,
+				VolumePath: fileRecord.VolumePath,
+				Hash:       fileRecord.Hash,
+				FileSize:   fileRecord.FileSize,
+			}
+			if err := d.acquireFiles(models.FileRecords{newRecord}); err != nil {
+				return nil, err
+			}
+			newVolume.FileRecords = append(newVolume.FileRecords, newRecord)
 			if newVolume, err = d.Repository.UpdateVolume(newVolume); err != nil {
+				d.releaseFiles(models.FileRecords{newRecord})
 				return nil, err
 			}
 			return newVolume, err
@@ -150,107 +235,331 @@ func (d DefaultService) CopyFile(ov, od, of, nv, nd, nf string) (*models.Volume,
 	return nil, errors.New("failed")
 }
 
-func (d DefaultService) CreateFile(baseVolumeName, dirname, filename string, file []byte) (*models.Volume, error) {
-	baseVolume, err := d.Repository.GetVolume(baseVolumeName)
+func (d DefaultService) CreateFile(baseVolumeName, dirname, filename string, file io.Reader) (*models.Volume, error) {
+	baseVolume, err := d.lookupVolume(baseVolumeName)
 	if err != nil {
 		return baseVolume, err
 	}
-	volume, err := d.CreateVolume(1)
+	remaining, err := d.remainingBytes(baseVolume.CreatedBy)
 	if err != nil {
 		return nil, err
 	}
-	volume.Base = baseVolume.ID
-	volumePath := time.Now().Format("20060102150405") + crypto.Sha256Bytes(file)
-	volume.FileRecords = models.FileRecords{
-		&models.FileRecord{
-			Opt:        "add",
-			FileType:   "f",
-			FilePath:   filepath.Join("/", dirname, filename),
-			VolumeName: volume.Name,
-			VolumePath: volumePath,
-		},
-	}
-	if volume, err = d.Repository.UpdateVolume(volume); err != nil {
+	fileRecord, err := d.storeFile(filepath.Join("/", dirname, filename), file, remaining)
+	if err != nil {
 		return nil, err
 	}
-	if err := d.Storage.CreateFile(volume.Name, volumePath, file); err != nil {
+	volume, err := d.createVolume(baseVolume, baseVolume.CreatedBy, models.FileRecords{fileRecord})
+	if err != nil {
+		d.releaseFiles(models.FileRecords{fileRecord})
 		return nil, err
 	}
 	return volume, nil
 }
 
 func (d DefaultService) RemoveFile(baseVolumeName, dirname, filename string) (*models.Volume, error) {
-	baseVolume, err := d.Repository.GetVolume(baseVolumeName)
+	baseVolume, err := d.lookupVolume(baseVolumeName)
 	if err != nil {
 		return baseVolume, err
 	}
-	volume, err := d.CreateVolume(1)
+	return d.createVolume(baseVolume, baseVolume.CreatedBy, models.FileRecords{
+		&models.FileRecord{
+			Opt:      "del",
+			FileType: "f",
+			FilePath: filepath.Join("/", dirname, filename),
+		},
+	})
+}
+
+// storeFile stores the content as a blob and returns a file record referencing it. The content
+// may not exceed the file size limit nor the remaining bytes of the owner, -1 if unlimited.
+func (d DefaultService) storeFile(filePath string, file io.Reader, remaining int64) (*models.FileRecord, error) {
+	d.blobMutex.RLock()
+	defer d.blobMutex.RUnlock()
+
+	hash, size, err := d.Storage.PutBlob(d.limit(file, remaining))
+	if errors.Is(err, ErrFileTooLarge) || errors.Is(err, ErrQuotaExceeded) ||
+		errors.Is(err, ErrArchiveTooLarge) || errors.Is(err, ErrInvalidArchive) {
+		return nil, err
+	}
 	if err != nil {
+		d.logger.Error("put blob", zap.Error(err))
 		return nil, err
 	}
-	volume.Base = baseVolume.ID
-	volume.FileRecords = models.FileRecords{
-		&models.FileRecord{
-			Opt:        "del",
-			FileType:   "f",
-			FilePath:   filepath.Join("/", dirname, filename),
-			VolumeName: volume.Name,
-		},
+	if err := d.Repository.AcquireBlob(hash, size); err != nil {
+		d.logger.Error("acquire blob", zap.String("hash", hash), zap.Error(err))
+		return nil, err
 	}
-	if volume, err = d.Repository.UpdateVolume(volume); err != nil {
+	return &models.FileRecord{
+		Opt:      "add",
+		FileType: "f",
+		FilePath: filePath,
+		Hash:     hash,
+		FileSize: size,
+	}, nil
+}
+
+// acquireFiles adds a reference to the blobs of file records, which are about to be stored in a volume.
+func (d DefaultService) acquireFiles(fileRecords models.FileRecords) error {
+	d.blobMutex.RLock()
+	defer d.blobMutex.RUnlock()
+
+	for i, fileRecord := range fileRecords {
+		if fileRecord.Hash == "" {
+			continue
+		}
+		if err := d.Repository.AcquireBlob(fileRecord.Hash, fileRecord.FileSize); err != nil {
+			d.releaseFiles(fileRecords[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseFiles drops references to the blobs of file records which are not stored in a volume.
+func (d DefaultService) releaseFiles(fileRecords models.FileRecords) {
+	for _, fileRecord := range fileRecords {
+		if fileRecord.Hash == "" {
+			continue
+		}
+		if err := d.Repository.ReleaseBlob(fileRecord.Hash); err != nil {
+			d.logger.Error("release blob", zap.String("hash", fileRecord.Hash), zap.Error(err))
+		}
+	}
+}
+
+// CollectBlobs removes blobs no file record references, and returns how many were removed.
+func (d DefaultService) CollectBlobs() (int, error) {
+	count, _, err := d.collectBlobs()
+	return count, err
+}
+
+// collectBlobs removes blobs no file record references, and returns how many were removed and
+// their total size.
+func (d DefaultService) collectBlobs() (int, int64, error) {
+	d.blobMutex.Lock()
+	defer d.blobMutex.Unlock()
+
+	blobs, err := d.Repository.GetUnreferencedBlobs()
+	if err != nil {
+		return 0, 0, err
+	}
+	count, size := 0, int64(0)
+	defer func() {
+		gcBlobs.Add(float64(count))
+		gcBytes.Add(float64(size))
+	}()
+	for _, blob := range blobs {
+		deleted, err := d.Repository.DeleteBlob(blob.Hash)
+		if err != nil {
+			return count, size, err
+		}
+		if !deleted {
+			continue
+		}
+		if err := d.Storage.DeleteBlob(blob.Hash); err != nil {
+			return count, size, err
+		}
+		count++
+		size += blob.Size
+	}
+	d.logger.Info("collect blobs", zap.Int("count", count), zap.Int64("size", size))
+	return count, size, nil
+}
+
+// ListFiles returns files of the volume in dirname whose names match the glob pattern, sorted by path.
+// Files in subdirectories are included only if recursive is set. A pattern containing a slash is
+// matched against the path relative to dirname, otherwise against the file name; an empty pattern
+// matches everything.
+func (d DefaultService) ListFiles(volumeName, dirname, pattern string, recursive bool) (models.FileRecords, error) {
+	if pattern != "" {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrap(err, "invalid pattern")
+		}
+	}
+
+	volume, err := d.GetVolume(volumeName)
+	if err != nil {
 		return nil, err
 	}
-	return volume, nil
+
+	prefix := strings.TrimSuffix(path.Join("/", dirname), "/") + "/"
+	fileRecords := models.FileRecords{}
+	for _, fileRecord := range volume.FileRecords {
+		filePath := path.Join("/", fileRecord.FilePath)
+		if fileRecord.IsDir() || !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		rel := strings.TrimPrefix(filePath, prefix)
+		if !recursive && strings.Contains(rel, "/") {
+			continue
+		}
+		if pattern != "" {
+			name := rel
+			if !strings.Contains(pattern, "/") {
+				name = path.Base(rel)
+			}
+			if ok, _ := path.Match(pattern, name); !ok {
+				continue
+			}
+		}
+		fileRecords = append(fileRecords, fileRecord)
+	}
+	sort.Slice(fileRecords, func(i, j int) bool {
+		return fileRecords[i].FilePath < fileRecords[j].FilePath
+	})
+	return fileRecords, nil
 }
 
-func (d DefaultService) CreateDirectory(baseVolumeName, dirname string) (*models.Volume, error) {
-	baseVolume, err := d.Repository.GetVolume(baseVolumeName)
+func (d DefaultService) GetFile(volumeName, filename string) (io.ReadCloser, int64, error) {
+	volume, err := d.GetVolume(volumeName)
 	if err != nil {
-		return baseVolume, err
+		return nil, 0, err
 	}
-	volume, err := d.CreateVolume(1)
+	return d.Storage.FetchFile(volume, filename)
+}
+
+func (d DefaultService) GetDirectory(volumeName, dirname string, w io.Writer) error {
+	volume, err := d.GetVolume(volumeName)
+	if err != nil {
+		return err
+	}
+	if dirname = path.Join("/", dirname); dirname != "/" && !directories(volume.FileRecords)[dirname] {
+		return ErrPathNotFound
+	}
+	return d.Storage.FetchDirectory(volume, dirname, w)
+}
+
+// SquashVolume flattens the volume and its base layers into a single layer. The content of the
+// volume does not change, so layers built on top of it and tags pointing at it stay valid. The
+// layer keeps counting as the volume it was squashed from against the volume quota.
+func (d DefaultService) SquashVolume(volumeName string) (*models.Volume, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	volume, err := d.lookupVolume(volumeName)
 	if err != nil {
 		return nil, err
 	}
-	volume.Base = baseVolume.ID
+	if volume.ID == 0 {
+		return nil, ErrVolumeNotFound
+	}
+	volumes, err := d.GetVolumeChain(volume)
 	if err != nil {
 		return nil, err
 	}
-	volume.FileRecords = models.FileRecords{
-		&models.FileRecord{
-			Opt:      "add",
-			FilePath: dirname,
-			FileType: "d",
-		},
+	if len(volumes) == 1 {
+		return volume, nil
+	}
+
+	fileRecords := resolveFileRecords(volumes)
+	sort.Slice(fileRecords, func(i, j int) bool {
+		return fileRecords[i].FilePath < fileRecords[j].FilePath
+	})
+	if err := d.acquireFiles(fileRecords); err != nil {
+		return nil, err
 	}
+
+	previous := volume.FileRecords
+	volume.SquashedFrom = volumes[0].ID
+	if volumes[0].SquashedFrom != 0 {
+		volume.SquashedFrom = volumes[0].SquashedFrom
+	}
+	volume.Base = 0
+	volume.FileRecords = fileRecords
 	if volume, err = d.Repository.UpdateVolume(volume); err != nil {
+		d.releaseFiles(fileRecords)
 		return nil, err
 	}
+	d.releaseFiles(previous)
+
+	d.logger.Info("squash volume", zap.String("name", volume.Name), zap.Int("layers", len(volumes)))
 	return volume, nil
 }
 
-func (d DefaultService) GetFile(volumeName, filename string) (*os.File, error) {
-	volume, err := d.GetVolume(volumeName)
+// GetHistory returns the layers of the volume, the bottom layer first, each with the changes it made.
+func (d DefaultService) GetHistory(volumeName string) ([]*models.Volume, error) {
+	volume, err := d.lookupVolume(volumeName)
 	if err != nil {
 		return nil, err
 	}
-	return d.Storage.FetchFile(volume, filename)
+	if volume.ID == 0 {
+		return nil, ErrVolumeNotFound
+	}
+	return d.GetVolumeChain(volume)
 }
 
-func (d DefaultService) GetDirectory(volumeName, dirname string) (file *os.File, err error) {
-	volume, err := d.GetVolume(volumeName)
+// TagVolume points the tag at the volume layer, moving it if it already exists.
+func (d DefaultService) TagVolume(volumeName, tag string, createdBy uint64) (*models.VolumeTag, error) {
+	volume, err := d.lookupVolume(volumeName)
+	if err != nil {
+		return nil, err
+	}
+	if volume.ID == 0 {
+		return nil, ErrVolumeNotFound
+	}
+	// a tag named like a volume would never be looked up
+	if shadowed, err := d.Repository.GetVolume(tag); err != nil {
+		return nil, err
+	} else if shadowed.ID != 0 {
+		return nil, ErrInvalidTag
+	}
+
+	volumeTag := &models.VolumeTag{
+		Name:      tag,
+		VolumeID:  volume.ID,
+		CreatedBy: createdBy,
+	}
+	if err := d.Repository.SaveTag(volumeTag); err != nil {
+		return nil, err
+	}
+	return volumeTag, nil
+}
+
+// UntagVolume deletes a tag pointing at the volume or one of its base layers.
+func (d DefaultService) UntagVolume(volumeName, tag string) error {
+	tags, err := d.GetTags(volumeName)
+	if err != nil {
+		return err
+	}
+	for _, t := range tags {
+		if t.Name != tag {
+			continue
+		}
+		if deleted, err := d.Repository.DeleteTag(tag); err != nil {
+			return err
+		} else if deleted {
+			return nil
+		}
+	}
+	return ErrTagNotFound
+}
+
+// GetTags returns tags pointing at the volume or one of its base layers.
+func (d DefaultService) GetTags(volumeName string) ([]*models.VolumeTag, error) {
+	volumes, err := d.GetHistory(volumeName)
 	if err != nil {
 		return nil, err
 	}
-	return d.Storage.FetchDirectory(volume, dirname)
+	var volumeIDs []uint64
+	for _, volume := range volumes {
+		volumeIDs = append(volumeIDs, volume.ID)
+	}
+	return d.Repository.GetTags(volumeIDs)
 }
 
-func NewVolumeService(logger *zap.Logger, Storage storages.Storage, Repository repositories.Repository) Service {
-	return &DefaultService{
+func NewVolumeService(logger *zap.Logger, options *Options, gcOptions *GCOptions, Storage storages.Storage, Repository repositories.Repository) Service {
+	service := &DefaultService{
 		logger:     logger.With(zap.String("type", "Account Storage")),
+		options:    options,
+		gcOptions:  gcOptions,
 		Storage:    Storage,
 		Repository: Repository,
 
-		mutex: &sync.Mutex{},
+		mutex:     &sync.Mutex{},
+		blobMutex: &sync.RWMutex{},
+	}
+	if gcOptions.Interval > 0 {
+		go service.runGC()
 	}
+	return service
 }
@@ -30,7 +30,15 @@ func CreateVolumesService(cf string, sto storages.Storage, repository repositori
 	if err != nil {
 		return nil, err
 	}
-	service := NewVolumeService(logger, sto, repository)
+	servicesOptions, err := NewOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	gcOptions, err := NewGCOptions(viper, logger)
+	if err != nil {
+		return nil, err
+	}
+	service := NewVolumeService(logger, servicesOptions, gcOptions, sto, repository)
 	return service, nil
 }
 
@@ -2,13 +2,11 @@ package storages
 
 import (
 	"archive/zip"
-	"bytes"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -20,89 +18,93 @@ import (
 
 type Storage interface {
 	CreateDirectory(volume, directory string) error
-	CreateFile(volume, fileName string, data []byte) error
+	CreateFile(volume, fileName string, data io.Reader) error
 	IsFileExists(volume, fileName string) bool
+	// RemoveVolume removes the directory of a deleted volume. It fails if files created before
+	// blobs are still in it, as other volumes may reference them.
+	RemoveVolume(volume string) error
 
-	FetchFile(volume *models.Volume, fileName string) (*os.File, error)
-	FetchDirectory(volume *models.Volume, directory string) (file *os.File, err error)
+	PutBlob(data io.Reader) (string, int64, error)
+	DeleteBlob(hash string) error
+
+	// FetchFile opens a file of the volume and returns its size. The caller closes the reader.
+	FetchFile(volume *models.Volume, fileName string) (io.ReadCloser, int64, error)
+	// FetchDirectory writes a zip archive of the volume directory to w.
+	FetchDirectory(volume *models.Volume, directory string, w io.Writer) error
 }
 
 type FileManager struct {
 	logger *zap.Logger
 	fm     files.FileManager
+	blobs  *files.BlobStore
 }
 
-func (m *FileManager) FetchFile(volume *models.Volume, fileName string) (*os.File, error) {
-	tmpFile, err := ioutil.TempFile("", volume.Name+"*")
-	if err != nil {
-		m.logger.Error("new temp file error", zap.Error(err))
-		return nil, err
+// open opens the content of a file record, from its blob if it has one.
+func (m *FileManager) open(fileRecord *models.FileRecord) (io.ReadCloser, error) {
+	if fileRecord.Hash != "" {
+		return m.blobs.Get(fileRecord.Hash)
 	}
-	defer tmpFile.Close()
+	return m.fm.FetchFile(filepath.Join(fileRecord.VolumeName, fileRecord.VolumePath))
+}
 
+// stat returns the file info of a file record. Records created before blobs know no size, so
+// the stored file is consulted.
+func (m *FileManager) stat(fileRecord *models.FileRecord) (os.FileInfo, error) {
+	if fileRecord.IsDir() || fileRecord.Hash != "" {
+		return fileRecord, nil
+	}
+	filePath := path.Join(fileRecord.VolumeName, fileRecord.VolumePath)
+	return m.fm.FetchFileInfo(filepath.ToSlash(filePath))
+}
+
+func (m *FileManager) FetchFile(volume *models.Volume, fileName string) (io.ReadCloser, int64, error) {
 	for _, fileRecord := range volume.FileRecords {
 		m.logger.Debug("file record", zap.Any("fr", fileRecord), zap.Any("fn", fileName))
 		if fileRecord.FilePath != fileName {
 			continue
 		}
 
-		fileBytes, err := m.fm.FetchFile(filepath.Join(fileRecord.VolumeName, fileRecord.VolumePath))
+		info, err := m.stat(fileRecord)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
-		err = ioutil.WriteFile(tmpFile.Name(), fileBytes, 0644)
+		reader, err := m.open(fileRecord)
 		if err != nil {
-			m.logger.Error("write file error", zap.Error(err))
-			return nil, err
+			return nil, 0, err
 		}
-		return tmpFile, nil
+		return reader, info.Size(), nil
 	}
 
-	err = errors.New("file not found")
+	err := errors.New("file not found")
 	m.logger.Error("fetch file", zap.String("filename", fileName), zap.Error(err))
-	return nil, err
+	return nil, 0, err
 }
 
-func (m *FileManager) FetchDirectory(volume *models.Volume, directory string) (file *os.File, err error) {
-	zipFile, err := ioutil.TempFile("", "*.zip")
-	if err != nil {
-		return nil, err
-	}
-	defer zipFile.Close()
-
-	archive := zip.NewWriter(zipFile)
-	defer archive.Close()
+func (m *FileManager) FetchDirectory(volume *models.Volume, directory string, w io.Writer) error {
+	archive := zip.NewWriter(w)
 
 	filePath := path.Join(volume.Name, directory)
 	baseDir := filepath.Base(filePath)
 
+	prefix := strings.TrimSuffix(path.Join("/", directory), "/") + "/"
 	for _, fileRecord := range volume.FileRecords {
-		fmt.Println(fileRecord)
-
-		info, err := func() (os.FileInfo, error) {
-			if fileRecord.IsDir() {
-				return fileRecord, nil
-			} else {
-				filePath := path.Join(fileRecord.VolumeName, fileRecord.VolumePath)
-				filePath = filepath.ToSlash(filePath)
-				info, err := m.fm.FetchFileInfo(filePath)
-				if err != nil {
-					return nil, err
-				}
-				return info, err
-			}
-		}()
+		if !strings.HasPrefix(path.Join("/", fileRecord.FilePath), prefix) {
+			continue
+		}
+
+		info, err := m.stat(fileRecord)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		header, err := zip.FileInfoHeader(info)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
+		// entries are named relative to the parent of the directory
 		if baseDir != "" {
-			header.Name = filepath.Join(baseDir, fileRecord.FilePath)
+			header.Name = filepath.Join(baseDir, strings.TrimPrefix(path.Join("/", fileRecord.FilePath), prefix))
 		}
 
 		if info.IsDir() {
@@ -114,24 +116,28 @@ func (m *FileManager) FetchDirectory(volume *models.Volume, directory string) (f
 
 		writer, err := archive.CreateHeader(header)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		if info.IsDir() {
 			continue
 		}
-		fileBytes, err := m.fm.FetchFile(filepath.Join(fileRecord.VolumeName, fileRecord.VolumePath))
-		if err != nil {
-			return nil, err
+		if err := m.copy(writer, fileRecord); err != nil {
+			return err
 		}
-		_, err = io.Copy(writer, bytes.NewReader(fileBytes))
-		if err != nil {
-			return nil, err
-		}
-		file.Close()
 	}
 
-	return zipFile, nil
+	return archive.Close()
+}
+
+func (m *FileManager) copy(w io.Writer, fileRecord *models.FileRecord) error {
+	reader, err := m.open(fileRecord)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(w, reader)
+	return err
 }
 
 func (m *FileManager) IsFileExists(volume, fileName string) bool {
@@ -148,14 +154,28 @@ func (m *FileManager) CreateDirectory(volume, directory string) error {
 	return m.fm.CreateDirectory(filePath)
 }
 
-func (m *FileManager) CreateFile(volume, fileName string, data []byte) error {
+func (m *FileManager) CreateFile(volume, fileName string, data io.Reader) error {
 	filePath := path.Join(volume, fileName)
 	return m.fm.CreateFile(filePath, data)
 }
 
+func (m *FileManager) RemoveVolume(volume string) error {
+	// a directory still holding files fails to be removed, directories of object storage are implicit
+	return m.fm.RemoveFile(volume)
+}
+
+func (m *FileManager) PutBlob(data io.Reader) (string, int64, error) {
+	return m.blobs.Put(data)
+}
+
+func (m *FileManager) DeleteBlob(hash string) error {
+	return m.blobs.Delete(hash)
+}
+
 func NewFileManager(logger *zap.Logger, fm files.FileManager) Storage {
 	return &FileManager{
 		logger: logger.With(zap.String("type", "Storage")),
 		fm:     fm,
+		blobs:  files.NewBlobStore(fm),
 	}
 }
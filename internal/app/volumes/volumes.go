@@ -16,12 +16,29 @@ func CreateInitControllersFn(vc controllers.Controller) InitVolumeGroupFn {
 		r.POST("/volume", vc.CreateVolume)
 
 		r.POST("/volume/:name/file", vc.CreateFile)
+		r.POST("/volume/:name/archive", vc.ExtractArchive)
 		r.DELETE("/volume/:name/file", vc.DeleteFile)
-		//r.POST("/volume/:name/directory", vc.CreateDirectory)
+		r.POST("/volume/:name/directory", vc.CreateDirectory)
+		r.DELETE("/volume/:name/directory", vc.DeleteDirectory)
+		r.POST("/volume/:name/move", vc.Move)
 
 		r.GET("/volume/:name", vc.GetVolume)
 		r.GET("/volume/:name/file", vc.DownloadFile)
 		r.GET("/volume/:name/directory", vc.DownloadDirectory)
+		r.GET("/volume/:name/list", vc.ListDirectory)
+
+		r.POST("/volume/:name/squash", vc.SquashVolume)
+		r.GET("/volume/:name/history", vc.GetHistory)
+		r.GET("/volume/:name/tag", vc.GetTags)
+		r.POST("/volume/:name/tag", vc.CreateTag)
+		r.DELETE("/volume/:name/tag/:tag", vc.DeleteTag)
+
+		r.GET("/volume/:name/diff/:other", vc.DiffVolumes)
+		r.POST("/volume/:name/merge", vc.MergeVolumes)
+
+		r.POST("/blobs/gc", vc.CollectBlobs)
+		r.GET("/volumes/gc", vc.CollectVolumes)
+		r.POST("/volumes/gc", vc.CollectVolumes)
 	}
 }
 
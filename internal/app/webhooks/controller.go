@@ -0,0 +1,203 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/infinity-oj/server-v2/internal/app/problems"
+	"github.com/infinity-oj/server-v2/internal/pkg/sessions"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+type Controller interface {
+	CreateWebhook(c *gin.Context)
+	GetWebhooks(c *gin.Context)
+	DeleteWebhook(c *gin.Context)
+	GetDeliveries(c *gin.Context)
+}
+
+type controller struct {
+	logger         *zap.Logger
+	service        Service
+	problemService problems.Service
+}
+
+// authorize returns the session if it may edit the problem of the path, as webhooks receive
+// every verdict of the problem. Otherwise it aborts the request and returns nil, with 404 if the
+// problem may not be read at all, 401 for anonymous requests and 403 for signed in ones.
+func (wc *controller) authorize(c *gin.Context) *sessions.Session {
+	name := c.Param("name")
+	session := sessions.GetSession(c)
+	principal := &problems.Principal{}
+	if session != nil {
+		principal.AccountID = session.AccountId
+		principal.Roles = session.Roles
+	}
+	_, granted, err := wc.problemService.GetAccess(name, principal)
+	if err != nil {
+		wc.logger.Error("get problem access", zap.String("problem name", name), zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return nil
+	}
+	if granted >= problems.AccessEdit {
+		return session
+	}
+
+	switch {
+	case granted == problems.AccessNone:
+		c.AbortWithStatus(http.StatusNotFound)
+	case session == nil:
+		c.AbortWithStatus(http.StatusUnauthorized)
+	default:
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+	return nil
+}
+
+func (wc *controller) abort(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, ErrProblemNotFound), errors.Is(err, ErrWebhookNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrInvalidURL):
+		status = http.StatusBadRequest
+	default:
+		wc.logger.Error("webhook", zap.Error(err))
+	}
+	c.AbortWithStatusJSON(status, &gin.H{
+		"message": err.Error(),
+	})
+}
+
+func (wc *controller) CreateWebhook(c *gin.Context) {
+	session := wc.authorize(c)
+	if session == nil {
+		return
+	}
+
+	name := c.Param("name")
+	wc.logger.Debug("create webhook",
+		zap.Uint64("account id", session.AccountId),
+		zap.String("problem name", name),
+	)
+	request := struct {
+		URL    string `json:"url" binding:"required,gt=0"`
+		Secret string `json:"secret"`
+	}{}
+
+	if err := c.ShouldBind(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	webhook, err := wc.service.CreateWebhook(name, request.URL, request.Secret)
+	if err != nil {
+		wc.abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhook)
+}
+
+func (wc *controller) GetWebhooks(c *gin.Context) {
+	session := wc.authorize(c)
+	if session == nil {
+		return
+	}
+
+	name := c.Param("name")
+	wc.logger.Debug("get webhooks", zap.String("problem name", name))
+
+	webhooks, err := wc.service.GetWebhooks(name)
+	if err != nil {
+		wc.abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (wc *controller) DeleteWebhook(c *gin.Context) {
+	session := wc.authorize(c)
+	if session == nil {
+		return
+	}
+
+	name := c.Param("name")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	wc.logger.Debug("delete webhook",
+		zap.Uint64("account id", session.AccountId),
+		zap.String("problem name", name),
+		zap.Uint64("webhook id", id),
+	)
+
+	if err := wc.service.DeleteWebhook(name, id); err != nil {
+		wc.abort(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (wc *controller) GetDeliveries(c *gin.Context) {
+	session := wc.authorize(c)
+	if session == nil {
+		return
+	}
+
+	name := c.Param("name")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	request := struct {
+		Page     int `form:"page" binding:"required,gt=0"`
+		PageSize int `form:"pageSize" binding:"required,gt=0,lte=50"`
+	}{}
+
+	if err := c.ShouldBindQuery(&request); err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, gin.H{
+			"msg": errs.Error(),
+		})
+		return
+	}
+
+	deliveries, err := wc.service.GetDeliveries(name, id, request.Page, request.PageSize)
+	if err != nil {
+		wc.abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func NewController(logger *zap.Logger, s Service, ps problems.Service) Controller {
+	return &controller{
+		logger:         logger,
+		service:        s,
+		problemService: ps,
+	}
+}
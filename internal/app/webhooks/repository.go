@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	CreateWebhook(problemId uint64, url, secret string) (*models.Webhook, error)
+	GetWebhook(id uint64) (*models.Webhook, error)
+	GetWebhooksByProblem(problemId uint64) ([]*models.Webhook, error)
+	DeleteWebhook(webhook *models.Webhook) error
+
+	CreateDelivery(delivery *models.WebhookDelivery) error
+	GetDeliveries(webhookId uint64, offset, limit int) ([]*models.WebhookDelivery, error)
+}
+
+type repository struct {
+	logger *zap.Logger
+	db     *gorm.DB
+}
+
+func (m repository) CreateWebhook(problemId uint64, url, secret string) (*models.Webhook, error) {
+	webhook := &models.Webhook{
+		ProblemID: problemId,
+		URL:       url,
+		Secret:    secret,
+		Active:    true,
+	}
+	if err := m.db.Create(webhook).Error; err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (m repository) GetWebhook(id uint64) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	if err := m.db.First(webhook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		m.logger.Error("query webhook failed", zap.Uint64("id", id), zap.Error(err))
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (m repository) GetWebhooksByProblem(problemId uint64) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := m.db.Where("problem_id = ?", problemId).Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (m repository) DeleteWebhook(webhook *models.Webhook) error {
+	return m.db.Delete(webhook).Error
+}
+
+func (m repository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	return m.db.Create(delivery).Error
+}
+
+func (m repository) GetDeliveries(webhookId uint64, offset, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	err := m.db.Where("webhook_id = ?", webhookId).
+		Order("id desc").
+		Offset(offset).Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func NewRepository(logger *zap.Logger, db *gorm.DB) Repository {
+	return &repository{
+		logger: logger.With(zap.String("type", "webhook repository")),
+		db:     db,
+	}
+}
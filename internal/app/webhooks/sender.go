@@ -0,0 +1,58 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the request body, formatted as "sha256=<hex>".
+	SignatureHeader = "X-IOJ-Signature"
+	// EventHeader carries the name of the delivered event.
+	EventHeader = "X-IOJ-Event"
+)
+
+// Sign returns the signature of body with secret, as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post sends body as JSON to url, signed with secret if it is not empty.
+// It returns the status code and body of the response, and an error for non-2xx responses.
+func Post(ctx context.Context, client *http.Client, url, secret, event string, body []byte) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if event != "" {
+		req.Header.Set(EventHeader, event)
+	}
+	if secret != "" {
+		req.Header.Set(SignatureHeader, Sign(secret, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, respBody, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, respBody, nil
+}
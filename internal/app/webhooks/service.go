@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/infinity-oj/server-v2/internal/app/problems"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// EventJudgementFinished is sent when a judgement reaches its final status.
+const EventJudgementFinished = "judgement.finished"
+
+var (
+	ErrProblemNotFound = errors.New("problem not found")
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("webhook url must be absolute http or https url")
+)
+
+// JudgementPayload is the body delivered to webhooks when a judgement finishes.
+type JudgementPayload struct {
+	Event        string             `json:"event"`
+	Problem      string             `json:"problem"`
+	JudgementID  string             `json:"judgement_id"`
+	SubmissionID uint64             `json:"submission_id"`
+	Status       models.JudgeStatus `json:"status"`
+	Score        float64            `json:"score"`
+	Message      string             `json:"msg"`
+	FinishedAt   time.Time          `json:"finished_at"`
+}
+
+type Service interface {
+	CreateWebhook(problemName, url, secret string) (*models.Webhook, error)
+	GetWebhooks(problemName string) ([]*models.Webhook, error)
+	DeleteWebhook(problemName string, id uint64) error
+	GetDeliveries(problemName string, id uint64, page, pageSize int) ([]*models.WebhookDelivery, error)
+
+	// NotifyJudgement delivers the outcome of judgement to webhooks of problem in background.
+	NotifyJudgement(problem *models.Problem, judgement *models.Judgement)
+}
+
+type service struct {
+	logger *zap.Logger
+	o      *Options
+	client *http.Client
+
+	Repository        Repository
+	ProblemRepository problems.Repository
+}
+
+func (s *service) getProblem(problemName string) (*models.Problem, error) {
+	problem, err := s.ProblemRepository.GetProblemByName(problemName)
+	if err != nil {
+		return nil, err
+	}
+	if problem == nil {
+		return nil, ErrProblemNotFound
+	}
+	return problem, nil
+}
+
+func (s *service) getWebhook(problemName string, id uint64) (*models.Webhook, error) {
+	problem, err := s.getProblem(problemName)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := s.Repository.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || webhook.ProblemID != problem.ID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *service) CreateWebhook(problemName, rawURL, secret string) (*models.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	problem, err := s.getProblem(problemName)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repository.CreateWebhook(problem.ID, rawURL, secret)
+}
+
+func (s *service) GetWebhooks(problemName string) ([]*models.Webhook, error) {
+	problem, err := s.getProblem(problemName)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repository.GetWebhooksByProblem(problem.ID)
+}
+
+func (s *service) DeleteWebhook(problemName string, id uint64) error {
+	webhook, err := s.getWebhook(problemName, id)
+	if err != nil {
+		return err
+	}
+	return s.Repository.DeleteWebhook(webhook)
+}
+
+func (s *service) GetDeliveries(problemName string, id uint64, page, pageSize int) ([]*models.WebhookDelivery, error) {
+	webhook, err := s.getWebhook(problemName, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repository.GetDeliveries(webhook.ID, (page-1)*pageSize, pageSize)
+}
+
+func (s *service) NotifyJudgement(problem *models.Problem, judgement *models.Judgement) {
+	if problem == nil {
+		return
+	}
+	webhooks, err := s.Repository.GetWebhooksByProblem(problem.ID)
+	if err != nil {
+		s.logger.Error("get webhooks", zap.Uint64("problem id", problem.ID), zap.Error(err))
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(&JudgementPayload{
+		Event:        EventJudgementFinished,
+		Problem:      problem.Name,
+		JudgementID:  judgement.Name,
+		SubmissionID: judgement.SubmissionID,
+		Status:       judgement.Status,
+		Score:        judgement.Score,
+		Message:      judgement.Msg,
+		FinishedAt:   time.Now(),
+	})
+	if err != nil {
+		s.logger.Error("marshal webhook payload", zap.Error(err))
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if webhook.Active {
+			go s.deliver(webhook, judgement.Name, EventJudgementFinished, body)
+		}
+	}
+}
+
+// deliver posts body to the webhook, retrying with exponential backoff.
+// Every attempt is recorded as a delivery.
+func (s *service) deliver(webhook *models.Webhook, judgementId, event string, body []byte) {
+	backoff := s.o.Backoff
+	for attempt := 1; attempt <= s.o.Retries+1; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), s.o.Timeout)
+		code, _, err := Post(ctx, s.client, webhook.URL, webhook.Secret, event, body)
+		cancel()
+
+		delivery := &models.WebhookDelivery{
+			WebhookID:   webhook.ID,
+			JudgementID: judgementId,
+			Event:       event,
+			Payload:     string(body),
+			Attempt:     attempt,
+			StatusCode:  code,
+			Delivered:   err == nil,
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if err := s.Repository.CreateDelivery(delivery); err != nil {
+			s.logger.Error("create webhook delivery", zap.Uint64("webhook id", webhook.ID), zap.Error(err))
+		}
+		if err == nil {
+			return
+		}
+
+		s.logger.Warn("deliver webhook",
+			zap.Uint64("webhook id", webhook.ID),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+		if attempt <= s.o.Retries {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+func NewService(logger *zap.Logger, o *Options, Repository Repository, ProblemRepository problems.Repository) Service {
+	return &service{
+		logger:            logger.With(zap.String("type", "webhook service")),
+		o:                 o,
+		client:            &http.Client{},
+		Repository:        Repository,
+		ProblemRepository: ProblemRepository,
+	}
+}
@@ -0,0 +1,85 @@
+package webhooks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"go.uber.org/zap"
+)
+
+type memoryRepository struct {
+	Repository
+
+	mutex      sync.Mutex
+	deliveries []*models.WebhookDelivery
+}
+
+func (m *memoryRepository) CreateDelivery(delivery *models.WebhookDelivery) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.deliveries = append(m.deliveries, delivery)
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := ioutil.ReadAll(r.Body)
+		if got := r.Header.Get(SignatureHeader); got != Sign("secret", body) {
+			t.Errorf("bad signature %s", got)
+		}
+		if got := r.Header.Get(EventHeader); got != EventJudgementFinished {
+			t.Errorf("bad event %s", got)
+		}
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	repository := &memoryRepository{}
+	s := NewService(zap.NewNop(), &Options{Timeout: time.Second, Retries: 2, Backoff: time.Millisecond},
+		repository, nil).(*service)
+
+	webhook := &models.Webhook{Model: models.Model{ID: 1}, URL: server.URL, Secret: "secret", Active: true}
+	s.deliver(webhook, "judgement", EventJudgementFinished, []byte(`{"score":100}`))
+
+	if len(repository.deliveries) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(repository.deliveries))
+	}
+	first, second := repository.deliveries[0], repository.deliveries[1]
+	if first.Delivered || first.StatusCode != http.StatusInternalServerError || first.Error == "" {
+		t.Errorf("unexpected first delivery %+v", first)
+	}
+	if !second.Delivered || second.StatusCode != http.StatusOK || second.Attempt != 2 {
+		t.Errorf("unexpected second delivery %+v", second)
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	repository := &memoryRepository{}
+	s := NewService(zap.NewNop(), &Options{Timeout: time.Second, Retries: 1, Backoff: time.Millisecond},
+		repository, nil).(*service)
+
+	s.deliver(&models.Webhook{URL: server.URL}, "judgement", EventJudgementFinished, []byte(`{}`))
+
+	if len(repository.deliveries) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d", len(repository.deliveries))
+	}
+	for _, delivery := range repository.deliveries {
+		if delivery.Delivered {
+			t.Errorf("unexpected delivered %+v", delivery)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/wire"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// Options is configuration of webhook deliveries
+type Options struct {
+	// Timeout bounds a single delivery attempt.
+	Timeout time.Duration `yaml:"timeout"`
+	// Retries is how many times a failed delivery is retried.
+	Retries int `yaml:"retries"`
+	// Backoff is the delay before the first retry, doubled after each attempt.
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+func NewOptions(v *viper.Viper, logger *zap.Logger) (*Options, error) {
+	o := &Options{
+		Timeout: 10 * time.Second,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+	if err := v.UnmarshalKey("webhooks", o); err != nil {
+		return nil, errors.Wrap(err, "unmarshal webhooks option error")
+	}
+	if o.Timeout <= 0 || o.Retries < 0 || o.Backoff < 0 {
+		return nil, errors.New("invalid webhooks options")
+	}
+
+	logger.Info("load webhooks options success",
+		zap.Duration("timeout", o.Timeout),
+		zap.Int("retries", o.Retries),
+		zap.Duration("backoff", o.Backoff),
+	)
+
+	return o, nil
+}
+
+type InitWebhookGroupFn func(r *gin.RouterGroup)
+
+func CreateInitControllersFn(wc Controller) InitWebhookGroupFn {
+	return func(r *gin.RouterGroup) {
+		r.GET("/problem/:name/webhook", wc.GetWebhooks)
+		r.POST("/problem/:name/webhook", wc.CreateWebhook)
+		r.DELETE("/problem/:name/webhook/:id", wc.DeleteWebhook)
+		r.GET("/problem/:name/webhook/:id/delivery", wc.GetDeliveries)
+	}
+}
+
+var ProviderSet = wire.NewSet(CreateInitControllersFn,
+	NewController,
+	NewService,
+	NewRepository,
+	NewOptions,
+)
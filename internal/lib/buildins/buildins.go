@@ -1,24 +1,147 @@
 package buildins
 
 import (
+	"fmt"
+	"sort"
+	"sync"
+
 	"github.com/google/wire"
-	"github.com/infinity-oj/server-v2/internal/lib/handlers"
+	"github.com/infinity-oj/server-v2/internal/app/accounts"
+	"github.com/infinity-oj/server-v2/internal/app/judgements"
+	"github.com/infinity-oj/server-v2/internal/app/ranklists"
+	"github.com/infinity-oj/server-v2/internal/app/submissions"
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"go.uber.org/zap"
+
+	VR "github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
+	VSV "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	VST "github.com/infinity-oj/server-v2/internal/app/volumes/storages"
 )
 
-func All(
-	list *handlers.RankList,
-	result *handlers.Result,
-	constString *handlers.ConstString,
-	constInt *handlers.ConstInt,
-	file *handlers.File,
-	evaluate *handlers.Evaluate,
-	create *handlers.VolumeCreate,
-	read *handlers.VolumeRead,
-	save *handlers.VolumeSave,
-	fetch *handlers.VolumeFetch,
-) []manager.Handler {
-	return []manager.Handler{list, result, constString, constInt, file, evaluate, create, read, save, fetch}
-}
-
-var ProviderSet = wire.NewSet(All)
+// Dependencies holds everything a built-in handler may need to be constructed.
+// Add a field here when a new handler requires another component.
+type Dependencies struct {
+	Logger *zap.Logger
+
+	AccountRepository    accounts.Repository
+	JudgementRepository  judgements.Repository
+	RankListRepository   ranklists.Repository
+	SubmissionRepository submissions.Repository
+
+	VolumeRepository VR.Repository
+	VolumeService    VSV.Service
+	VolumeStorage    VST.Storage
+}
+
+// Factory creates a handler from the shared dependencies.
+type Factory func(deps *Dependencies) manager.Handler
+
+// Compiler prepares properties of a block once, when the graph is built.
+// The returned value is stored in Block.Compiled for the handler to use.
+type Compiler func(definition *scene.BlockDefinition, block *engine.Block) (interface{}, error)
+
+type entry struct {
+	definition *scene.BlockDefinition
+	factory    Factory
+}
+
+var (
+	mutex     sync.RWMutex
+	registry  = make(map[string]*entry)
+	compilers = make(map[string]Compiler)
+)
+
+// Register makes a built-in handler available under the name of its block definition.
+// It is meant to be called from the init function of the package providing the handler,
+// and panics if the same name is registered twice.
+func Register(definition *scene.BlockDefinition, factory Factory) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if definition == nil || definition.Name == "" {
+		panic("buildins: register block without name")
+	}
+	if factory == nil {
+		panic("buildins: register nil factory for " + definition.Name)
+	}
+	if _, dup := registry[definition.Name]; dup {
+		panic(fmt.Sprintf("buildins: register called twice for %s", definition.Name))
+	}
+	registry[definition.Name] = &entry{
+		definition: definition,
+		factory:    factory,
+	}
+}
+
+// RegisterCompiler sets the compiler used for blocks processed by the handler name.
+func RegisterCompiler(name string, compiler Compiler) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if compiler == nil {
+		panic("buildins: register nil compiler for " + name)
+	}
+	if _, dup := compilers[name]; dup {
+		panic(fmt.Sprintf("buildins: register compiler called twice for %s", name))
+	}
+	compilers[name] = compiler
+}
+
+// Compile runs the compiler registered for the handler of block, if any.
+// definition is the block definition the block was created from and may be nil.
+func Compile(definition *scene.BlockDefinition, block *engine.Block) error {
+	mutex.RLock()
+	compiler, ok := compilers[block.HandlerName()]
+	mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	compiled, err := compiler(definition, block)
+	if err != nil {
+		return err
+	}
+	block.Compiled = compiled
+	return nil
+}
+
+// Definitions returns block definitions of all registered handlers, sorted by name.
+func Definitions() []*scene.BlockDefinition {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	var definitions []*scene.BlockDefinition
+	for _, e := range registry {
+		definitions = append(definitions, e.definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+	return definitions
+}
+
+// Definition returns block definition registered with name, or nil if there is none.
+func Definition(name string) *scene.BlockDefinition {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if e, ok := registry[name]; ok {
+		return e.definition
+	}
+	return nil
+}
+
+func All(deps *Dependencies) manager.Handlers {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	handlers := make(manager.Handlers, len(registry))
+	for name, e := range registry {
+		handlers[name] = e.factory(deps)
+	}
+	return handlers
+}
+
+var ProviderSet = wire.NewSet(All, wire.Struct(new(Dependencies), "*"))
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/wire"
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"go.uber.org/zap"
+
+	VS "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+)
+
+// Cache is the result cache of cacheable processes backed by the database.
+// Volume and file inputs are hashed by content, so renaming a volume or
+// re-uploading the same files still hits the cache.
+type Cache struct {
+	logger *zap.Logger
+	repo   Repository
+	vs     VS.Service
+}
+
+func (c *Cache) Get(ctx context.Context, block *engine.Block, inputs models.Slots) (string, *models.Slots, bool) {
+	key, err := Key(block, inputs, c.resolve)
+	if err != nil {
+		c.logger.Debug("process is not cacheable", zap.String("type", block.Type), zap.Error(err))
+		return "", nil, false
+	}
+	if ctx.Err() != nil {
+		return key, nil, false
+	}
+
+	entry, err := c.repo.Get(key)
+	if err != nil {
+		c.logger.Error("get process cache", zap.String("key", key), zap.Error(err))
+		return key, nil, false
+	}
+	if entry == nil {
+		return key, nil, false
+	}
+	return key, &entry.Outputs, true
+}
+
+func (c *Cache) Put(key string, block *engine.Block, outputs *models.Slots) {
+	if err := c.repo.Put(&models.ProcessCache{
+		Key:     key,
+		Type:    block.Type,
+		Outputs: *outputs,
+	}); err != nil {
+		c.logger.Error("put process cache", zap.String("key", key), zap.Error(err))
+	}
+}
+
+// Invalidate removes cached outputs of the process type, or of all processes if it is empty.
+func (c *Cache) Invalidate(processType string) (int64, error) {
+	return c.repo.Delete(processType)
+}
+
+// InvalidateKey removes cached outputs with the key and reports whether there were any.
+func (c *Cache) InvalidateKey(key string) (bool, error) {
+	return c.repo.DeleteKey(key)
+}
+
+// resolve returns content hash of a volume or file slot.
+func (c *Cache) resolve(slot *models.Slot) (string, error) {
+	switch slot.Type {
+	case "volume":
+		volume, err := c.vs.GetVolume(cast.ToString(slot.Value))
+		if err != nil {
+			return "", err
+		}
+		return hashFileRecords(volume.FileRecords), nil
+	case "file":
+		ref := strings.SplitN(cast.ToString(slot.Value), "/", 2)
+		if len(ref) != 2 {
+			return "", errors.Errorf("invalid file reference: %v", slot.Value)
+		}
+		volume, err := c.vs.GetVolume(ref[0])
+		if err != nil {
+			return "", err
+		}
+		filePath := path.Join("/", ref[1])
+		for _, fileRecord := range volume.FileRecords {
+			if path.Join("/", fileRecord.FilePath) == filePath {
+				return contentHash(fileRecord), nil
+			}
+		}
+		return "", errors.Errorf("file not found: %v", slot.Value)
+	}
+	return "", nil
+}
+
+// Resolver returns content hash of a slot referring to stored files, or an empty string for plain values.
+type Resolver func(slot *models.Slot) (string, error)
+
+// Key computes the cache key of a process from its block type, properties and inputs.
+func Key(block *engine.Block, inputs models.Slots, resolve Resolver) (string, error) {
+	type input struct {
+		Type    string      `json:"type"`
+		Value   interface{} `json:"value,omitempty"`
+		Content string      `json:"content,omitempty"`
+	}
+	var resolved []input
+	for _, slot := range inputs {
+		if slot == nil {
+			return "", errors.New("empty input slot")
+		}
+		content, err := resolve(slot)
+		if err != nil {
+			return "", err
+		}
+		in := input{Type: slot.Type, Content: content}
+		if content == "" {
+			in.Value = slot.Value
+		}
+		resolved = append(resolved, in)
+	}
+
+	data, err := json.Marshal(struct {
+		Type       string                 `json:"type"`
+		Handler    string                 `json:"handler"`
+		Properties map[string]interface{} `json:"properties"`
+		Inputs     []input                `json:"inputs"`
+	}{
+		Type:       block.Type,
+		Handler:    block.HandlerName(),
+		Properties: block.Properties,
+		Inputs:     resolved,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hashFileRecords(fileRecords models.FileRecords) string {
+	var entries []string
+	for _, fileRecord := range fileRecords {
+		entries = append(entries, path.Join("/", fileRecord.FilePath)+":"+fileRecord.FileType+":"+contentHash(fileRecord))
+	}
+	sort.Strings(entries)
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// contentHash returns sha256 of the file content, or the stored file name if it is unknown.
+func contentHash(fileRecord *models.FileRecord) string {
+	if hash := fileRecord.ContentHash(); hash != "" {
+		return hash
+	}
+	return fileRecord.VolumeName + "/" + fileRecord.VolumePath
+}
+
+func New(logger *zap.Logger, repo Repository, vs VS.Service) *Cache {
+	return &Cache{
+		logger: logger.With(zap.String("type", "process cache")),
+		repo:   repo,
+		vs:     vs,
+	}
+}
+
+var ProviderSet = wire.NewSet(New, NewRepository, wire.Bind(new(manager.Cache), new(*Cache)))
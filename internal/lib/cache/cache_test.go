@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestKey(t *testing.T) {
+	contents := map[string]string{
+		"v1/main.cpp": "hash-a",
+		"v2/main.cpp": "hash-a",
+		"v3/main.cpp": "hash-b",
+	}
+	resolve := func(slot *models.Slot) (string, error) {
+		if slot.Type == "file" {
+			return contents[slot.Value.(string)], nil
+		}
+		return "", nil
+	}
+	key := func(block *engine.Block, inputs ...*models.Slot) string {
+		k, err := Key(block, inputs, resolve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	compile := &engine.Block{Type: "compile", Properties: map[string]interface{}{"lang": "cpp", "std": 17}}
+	base := key(compile, &models.Slot{Type: "file", Value: "v1/main.cpp"})
+
+	if k := key(&engine.Block{Type: "compile", Properties: map[string]interface{}{"std": 17, "lang": "cpp"}},
+		&models.Slot{Type: "file", Value: "v1/main.cpp"}); k != base {
+		t.Error("key depends on order of properties")
+	}
+	if k := key(compile, &models.Slot{Type: "file", Value: "v2/main.cpp"}); k != base {
+		t.Error("same content in another volume should hit")
+	}
+	if k := key(compile, &models.Slot{Type: "file", Value: "v3/main.cpp"}); k == base {
+		t.Error("different content should miss")
+	}
+	if k := key(&engine.Block{Type: "compile", Properties: map[string]interface{}{"lang": "c"}},
+		&models.Slot{Type: "file", Value: "v1/main.cpp"}); k == base {
+		t.Error("different properties should miss")
+	}
+	if k := key(&engine.Block{Type: "run", Properties: compile.Properties},
+		&models.Slot{Type: "file", Value: "v1/main.cpp"}); k == base {
+		t.Error("different type should miss")
+	}
+	if key(compile, &models.Slot{Type: "string", Value: "a"}) == key(compile, &models.Slot{Type: "string", Value: "b"}) {
+		t.Error("different values should miss")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if got := contentHash(&models.FileRecord{VolumePath: "20210101120000" + hash}); got != hash {
+		t.Errorf("got %s", got)
+	}
+}
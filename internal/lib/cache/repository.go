@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	Get(key string) (*models.ProcessCache, error)
+	Put(entry *models.ProcessCache) error
+	Delete(processType string) (int64, error)
+	DeleteKey(key string) (bool, error)
+}
+
+type repository struct {
+	logger *zap.Logger
+	db     *gorm.DB
+}
+
+func (m repository) Get(key string) (*models.ProcessCache, error) {
+	entry := &models.ProcessCache{}
+	if err := m.db.Where(map[string]interface{}{"key": key}).First(entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (m repository) Put(entry *models.ProcessCache) error {
+	return m.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"outputs", "updated_at"}),
+	}).Create(entry).Error
+}
+
+// Delete removes entries of the process type, or all entries if it is empty.
+func (m repository) Delete(processType string) (int64, error) {
+	db := m.db.Session(&gorm.Session{AllowGlobalUpdate: true})
+	if processType != "" {
+		db = db.Where(map[string]interface{}{"type": processType})
+	}
+	result := db.Delete(&models.ProcessCache{})
+	return result.RowsAffected, result.Error
+}
+
+func (m repository) DeleteKey(key string) (bool, error) {
+	result := m.db.Where(map[string]interface{}{"key": key}).Delete(&models.ProcessCache{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func NewRepository(logger *zap.Logger, db *gorm.DB) Repository {
+	return &repository{
+		logger: logger.With(zap.String("type", "process cache repository")),
+		db:     db,
+	}
+}
@@ -1,11 +1,16 @@
 package dispatcher
 
 import (
+	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
 
 	"github.com/google/wire"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
 
 	"github.com/spf13/cast"
 
@@ -13,6 +18,7 @@ import (
 	"github.com/infinity-oj/server-v2/internal/app/problems"
 	"github.com/infinity-oj/server-v2/internal/app/programs"
 	"github.com/infinity-oj/server-v2/internal/app/submissions"
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
 
 	"github.com/infinity-oj/server-v2/internal/lib/scheduler"
 
@@ -20,52 +26,144 @@ import (
 	"go.uber.org/zap"
 )
 
+// Options is configuration of dispatcher
+type Options struct {
+	// Timeout bounds how long a judgement may run before it is canceled.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func NewOptions(v *viper.Viper, logger *zap.Logger) (*Options, error) {
+	o := &Options{
+		Timeout: 30 * time.Minute,
+	}
+	if err := v.UnmarshalKey("dispatcher", o); err != nil {
+		return nil, errors.Wrap(err, "unmarshal dispatcher option error")
+	}
+	if o.Timeout <= 0 {
+		return nil, errors.New("dispatcher timeout must be positive")
+	}
+
+	logger.Info("load dispatcher options success", zap.Duration("timeout", o.Timeout))
+
+	return o, nil
+}
+
 type dispatcher struct {
 	c      chan *models.Judgement
+	o      *Options
 	logger *zap.Logger
-	br     blueprints.Repository
-	pr     problems.Repository
-	sr     submissions.Repository
-	jr     judgements.Repository
-	pgr    programs.Repository
+
+	mutex   *sync.Mutex
+	cancels map[string]context.CancelFunc
+
+	br  blueprints.Repository
+	pr  problems.Repository
+	sr  submissions.Repository
+	jr  judgements.Repository
+	pgr programs.Repository
+
+	ws webhooks.Service
 }
 
 func (d *dispatcher) PushJudgement(judgement *models.Judgement) {
 	d.c <- judgement
 }
 
+func (d *dispatcher) CancelJudgement(judgementId string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	cancel, ok := d.cancels[judgementId]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (d *dispatcher) track(judgementId string, cancel context.CancelFunc) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.cancels[judgementId] = cancel
+}
+
+func (d *dispatcher) untrack(judgementId string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if cancel, ok := d.cancels[judgementId]; ok {
+		cancel()
+		delete(d.cancels, judgementId)
+	}
+}
+
 func (d *dispatcher) execute(s *scheduler.Scheduler) {
 	d.logger.Debug("execute runtime",
 		zap.String("judgement id", s.Runtime.Judgement.Name),
 	)
 	judgement := s.Runtime.Judgement
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.o.Timeout)
+	d.track(judgement.Name, cancel)
+	defer d.untrack(judgement.Name)
+
 	judgement.Status = models.Running
 	if err := d.jr.Update(judgement); err != nil {
 		d.logger.Error("update judgement", zap.Error(err))
 	}
-	go s.Execute()
+	go s.Execute(ctx)
 	code := <-s.OnFinish()
 	d.logger.Debug("finish runtime",
 		zap.String("judgement id", s.Runtime.Judgement.Name),
 		zap.Int("return code", code),
+		zap.Error(s.Err()),
 	)
 	judgement = s.Runtime.Judgement
-	switch judgement.Score {
-	case -1:
-		judgement.Status = models.Finished
-		break
-	case 0:
-		judgement.Status = models.WrongAnswer
-		break
-	case 100:
-		judgement.Status = models.Accepted
-		break
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		judgement.Status = models.Canceled
+		judgement.Msg = "User cancel"
+		judgement.Score = -1
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		judgement.Status = models.SystemError
+		judgement.Msg = fmt.Sprintf("judgement timeout after %s", d.o.Timeout)
+	case s.Err() != nil:
+		judgement.Status = models.SystemError
+		judgement.Msg = s.Err().Error()
 	default:
-		judgement.Status = models.PartiallyCorrect
+		switch judgement.Score {
+		case -1:
+			judgement.Status = models.Finished
+			break
+		case 0:
+			judgement.Status = models.WrongAnswer
+			break
+		case 100:
+			judgement.Status = models.Accepted
+			break
+		default:
+			judgement.Status = models.PartiallyCorrect
+		}
 	}
 	if err := d.jr.Update(judgement); err != nil {
 		d.logger.Error("update judgement", zap.Error(err))
 	}
+	d.record(s.Runtime, judgement)
+	d.ws.NotifyJudgement(s.Runtime.Problem, judgement)
+}
+
+// record counts the finished judgement of a submission into the statistics of its problem.
+func (d *dispatcher) record(runtime *scheduler.Runtime, judgement *models.Judgement) {
+	if runtime.Problem == nil || runtime.Submission == nil || judgement.Status == models.Canceled {
+		return
+	}
+	err := d.pr.RecordJudgement(runtime.Problem.ID, runtime.Submission.SubmitterId,
+		judgement.Status, judgement.Score, time.Now())
+	if err != nil {
+		d.logger.Error("record judgement statistics",
+			zap.String("judgement id", judgement.Name),
+			zap.Uint64("problem id", runtime.Problem.ID),
+			zap.Error(err),
+		)
+	}
 }
 
 func (d *dispatcher) run() {
@@ -80,6 +178,12 @@ func (d *dispatcher) run() {
 		})
 		d.logger.Debug("get judgement", zap.Any("judgement", judgement))
 
+		if latest, err := d.jr.GetJudgement(judgement.Name); err == nil && latest != nil &&
+			latest.Status == models.Canceled {
+			d.logger.Debug("skip canceled judgement", zap.String("judgement id", judgement.Name))
+			continue
+		}
+
 		// get blueprint
 		blueprint, err := d.br.GetBlueprint(judgement.BlueprintId)
 		if err != nil {
@@ -159,17 +263,21 @@ func GetDispatcher() judgements.Dispatcher {
 	return instance
 }
 
-func New(logger *zap.Logger, pr problems.Repository, sr submissions.Repository, jr judgements.Repository,
-	br blueprints.Repository, pgr programs.Repository) judgements.Dispatcher {
+func New(logger *zap.Logger, o *Options, pr problems.Repository, sr submissions.Repository, jr judgements.Repository,
+	br blueprints.Repository, pgr programs.Repository, ws webhooks.Service) judgements.Dispatcher {
 	once.Do(func() {
 		instance = &dispatcher{
-			c:      make(chan *models.Judgement),
-			logger: logger.With(zap.String("scope", "dispatcher")),
-			br:     br,
-			pr:     pr,
-			sr:     sr,
-			jr:     jr,
-			pgr:    pgr,
+			c:       make(chan *models.Judgement),
+			o:       o,
+			logger:  logger.With(zap.String("scope", "dispatcher")),
+			mutex:   &sync.Mutex{},
+			cancels: make(map[string]context.CancelFunc),
+			br:      br,
+			pr:      pr,
+			sr:      sr,
+			jr:      jr,
+			pgr:     pgr,
+			ws:      ws,
 		}
 
 		go instance.run()
@@ -177,4 +285,4 @@ func New(logger *zap.Logger, pr problems.Repository, sr submissions.Repository,
 	return instance
 }
 
-var ProviderSet = wire.NewSet(New)
+var ProviderSet = wire.NewSet(New, NewOptions)
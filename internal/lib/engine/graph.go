@@ -12,14 +12,28 @@ type Graph struct {
 type Block struct {
 	Id         int
 	Type       string
+	Handler    string
+	Cacheable  bool
 	Properties map[string]interface{}
 
-	Inputs []int
-	Output [][]int
+	Inputs     []int
+	InputNames []string
+	Output     [][]int
+
+	// Compiled holds properties prepared by the handler when the graph was built.
+	Compiled interface{}
 
 	Status string
 }
 
+// HandlerName returns name of the handler processing the block.
+func (b *Block) HandlerName() string {
+	if b.Handler != "" {
+		return b.Handler
+	}
+	return b.Type
+}
+
 type Port struct {
 	Id   int
 	Slot int
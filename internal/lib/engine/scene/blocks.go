@@ -17,6 +17,11 @@ type BlockDefinition struct {
 	Family      string  `json:"family"`
 	Description string  `json:"description"`
 	Fields      []Field `json:"fields"`
+
+	// Handler is name of the built-in handler processing the block, if it differs from Name.
+	Handler string `json:"handler,omitempty"`
+	// Cacheable marks blocks whose outputs depend only on their properties and inputs.
+	Cacheable bool `json:"cacheable,omitempty"`
 }
 
 func NewBlocksDefinition(jsonStr string) []*BlockDefinition {
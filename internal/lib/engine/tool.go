@@ -47,12 +47,16 @@ func NewGraphByScene(bs []*scene.BlockDefinition, s *scene.Scene) (*Graph, error
 
 	for _, v := range s.Blocks {
 
-		inputCounts := 0
+		handler := ""
+		cacheable := false
+		var inputNames []string
 		outputCounts := 0
 		if b, ok := blockMap[v.Name]; ok {
+			handler = b.Handler
+			cacheable = b.Cacheable
 			for _, field := range b.Fields {
 				if field.Attr == "input" {
-					inputCounts++
+					inputNames = append(inputNames, field.Name)
 				}
 				if field.Attr == "output" {
 					outputCounts++
@@ -61,10 +65,12 @@ func NewGraphByScene(bs []*scene.BlockDefinition, s *scene.Scene) (*Graph, error
 		}
 
 		var inputs []int
-		for i := 0; i < inputCounts; i++ {
+		var names []string
+		for i := range inputNames {
 			for _, link := range s.Links {
 				if link.TargetID == v.ID && link.TargetSlot == i {
 					inputs = append(inputs, link.ID)
+					names = append(names, inputNames[i])
 				}
 			}
 		}
@@ -75,6 +81,9 @@ func NewGraphByScene(bs []*scene.BlockDefinition, s *scene.Scene) (*Graph, error
 		}
 
 		block := graph.AddBlock(v.ID, v.Name, nil, inputs, outputs)
+		block.Handler = handler
+		block.Cacheable = cacheable
+		block.InputNames = names
 
 		for k, attr := range v.Attributes["property"] {
 			if attr.Value == nil {
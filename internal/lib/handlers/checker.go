@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+// compareFunc compares the answer with the contestant's output and returns
+// whether they match together with a short message explaining the result.
+type compareFunc func(answer, output []byte, properties map[string]interface{}) (bool, string)
+
+// Checker compares two file or bytes slots, like the standard checkers of testlib.
+// The first input is the expected answer and the second one is the output to check.
+type Checker struct {
+	compare compareFunc
+	fetch   *VolumeFetch
+}
+
+func (c *Checker) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	if len(process.Inputs) != 2 {
+		return errors.New("checker expects answer and output")
+	}
+
+	answer, err := c.read(ctx, process.Inputs[0])
+	if err != nil {
+		return errors.Wrap(err, "read answer")
+	}
+	output, err := c.read(ctx, process.Inputs[1])
+	if err != nil {
+		return errors.Wrap(err, "read output")
+	}
+
+	verdict := models.WrongAnswer
+	ok, message := c.compare(answer, output, process.Properties)
+	if ok {
+		verdict = models.Accepted
+	}
+
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "string",
+			Value: string(verdict),
+		},
+		&models.Slot{
+			Type:  "string",
+			Value: message,
+		},
+	}
+	return nil
+}
+
+func (c *Checker) read(ctx context.Context, slot *models.Slot) ([]byte, error) {
+	if slot == nil {
+		return nil, errors.New("empty slot")
+	}
+	if slot.Type == "file" {
+		return c.fetch.fetch(ctx, cast.ToString(slot.Value))
+	}
+	return []byte(cast.ToString(slot.Value)), nil
+}
+
+func NewChecker(compare compareFunc, fetch *VolumeFetch) *Checker {
+	return &Checker{compare: compare, fetch: fetch}
+}
+
+// compareExact requires both sides to be byte-for-byte identical.
+func compareExact(answer, output []byte, _ map[string]interface{}) (bool, string) {
+	if bytes.Equal(answer, output) {
+		return true, fmt.Sprintf("ok %d bytes", len(answer))
+	}
+
+	answerLines := strings.Split(string(answer), "\n")
+	outputLines := strings.Split(string(output), "\n")
+	for i := 0; i < len(answerLines) || i < len(outputLines); i++ {
+		switch {
+		case i >= len(outputLines):
+			return false, fmt.Sprintf("wrong answer unexpected end of file - expected %d lines, found %d",
+				len(answerLines), len(outputLines))
+		case i >= len(answerLines):
+			return false, fmt.Sprintf("wrong answer extra lines - expected %d lines, found %d",
+				len(answerLines), len(outputLines))
+		case answerLines[i] != outputLines[i]:
+			return false, fmt.Sprintf("wrong answer %s lines differ - expected: '%s', found: '%s'",
+				ordinal(i+1), compress(answerLines[i]), compress(outputLines[i]))
+		}
+	}
+	return false, "wrong answer files differ"
+}
+
+// compareTokens compares sequences of whitespace separated tokens.
+func compareTokens(answer, output []byte, _ map[string]interface{}) (bool, string) {
+	answerTokens := tokens(answer)
+	outputTokens := tokens(output)
+
+	for i, expected := range answerTokens {
+		if i >= len(outputTokens) {
+			return false, fmt.Sprintf("wrong answer unexpected end of file - expected %d tokens, found %d",
+				len(answerTokens), len(outputTokens))
+		}
+		if expected != outputTokens[i] {
+			return false, fmt.Sprintf("wrong answer %s words differ - expected: '%s', found: '%s'",
+				ordinal(i+1), compress(expected), compress(outputTokens[i]))
+		}
+	}
+	if len(outputTokens) > len(answerTokens) {
+		return false, fmt.Sprintf("wrong answer extra tokens - expected %d tokens, found %d",
+			len(answerTokens), len(outputTokens))
+	}
+	return true, fmt.Sprintf("ok %d tokens", len(answerTokens))
+}
+
+// compareFloats compares sequences of floating-point numbers. A number is
+// accepted if its absolute or relative error does not exceed the configured epsilon.
+func compareFloats(answer, output []byte, properties map[string]interface{}) (bool, string) {
+	absEps := 1e-6
+	if v, ok := properties["abs_eps"]; ok {
+		absEps = cast.ToFloat64(v)
+	}
+	relEps := 1e-6
+	if v, ok := properties["rel_eps"]; ok {
+		relEps = cast.ToFloat64(v)
+	}
+
+	answerTokens := tokens(answer)
+	outputTokens := tokens(output)
+
+	for i, token := range answerTokens {
+		if i >= len(outputTokens) {
+			return false, fmt.Sprintf("wrong answer unexpected end of file - expected %d numbers, found %d",
+				len(answerTokens), len(outputTokens))
+		}
+		expected, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return false, fmt.Sprintf("answer %s element is not a double: '%s'", ordinal(i+1), compress(token))
+		}
+		found, err := strconv.ParseFloat(outputTokens[i], 64)
+		if err != nil {
+			return false, fmt.Sprintf("wrong answer expected double, found '%s'", compress(outputTokens[i]))
+		}
+		if !floatEquals(expected, found, absEps, relEps) {
+			return false, fmt.Sprintf("wrong answer %s numbers differ - expected: '%.10f', found: '%.10f', error = '%.10f'",
+				ordinal(i+1), expected, found, math.Abs(expected-found))
+		}
+	}
+	if len(outputTokens) > len(answerTokens) {
+		return false, fmt.Sprintf("wrong answer extra tokens - expected %d numbers, found %d",
+			len(answerTokens), len(outputTokens))
+	}
+	return true, fmt.Sprintf("ok %d numbers", len(answerTokens))
+}
+
+func floatEquals(expected, found, absEps, relEps float64) bool {
+	if math.IsNaN(expected) || math.IsNaN(found) {
+		return math.IsNaN(expected) && math.IsNaN(found)
+	}
+	if math.IsInf(expected, 0) || math.IsInf(found, 0) {
+		return expected == found
+	}
+	diff := math.Abs(expected - found)
+	return diff <= absEps || diff <= relEps*math.Abs(expected)
+}
+
+// compareLinesIgnoreCase compares line by line ignoring case and trailing spaces.
+// Trailing empty lines on both sides are ignored.
+func compareLinesIgnoreCase(answer, output []byte, _ map[string]interface{}) (bool, string) {
+	answerLines := lines(answer)
+	outputLines := lines(output)
+
+	for i, expected := range answerLines {
+		if i >= len(outputLines) {
+			return false, fmt.Sprintf("wrong answer unexpected end of file - expected %d lines, found %d",
+				len(answerLines), len(outputLines))
+		}
+		if !strings.EqualFold(expected, outputLines[i]) {
+			return false, fmt.Sprintf("wrong answer %s lines differ - expected: '%s', found: '%s'",
+				ordinal(i+1), compress(expected), compress(outputLines[i]))
+		}
+	}
+	if len(outputLines) > len(answerLines) {
+		return false, fmt.Sprintf("wrong answer extra lines - expected %d lines, found %d",
+			len(answerLines), len(outputLines))
+	}
+	return true, fmt.Sprintf("ok %d lines", len(answerLines))
+}
+
+func tokens(data []byte) []string {
+	return strings.Fields(string(data))
+}
+
+func lines(data []byte) []string {
+	var res []string
+	for _, line := range strings.Split(string(data), "\n") {
+		res = append(res, strings.TrimRight(line, " \t\r"))
+	}
+	for len(res) > 0 && res[len(res)-1] == "" {
+		res = res[:len(res)-1]
+	}
+	return res
+}
+
+// compress shortens long strings for messages, like testlib does.
+func compress(s string) string {
+	const limit = 64
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit/2-2] + "..." + s[len(s)-limit/2+1:]
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+func init() {
+	checkers := []struct {
+		name        string
+		title       string
+		description string
+		properties  []scene.Field
+		compare     compareFunc
+	}{
+		{
+			name:        "checker/exact",
+			title:       "Exact checker",
+			description: "Compare output with answer byte by byte",
+			compare:     compareExact,
+		},
+		{
+			name:        "checker/tokens",
+			title:       "Token checker",
+			description: "Compare sequences of tokens ignoring whitespace",
+			compare:     compareTokens,
+		},
+		{
+			name:        "checker/float",
+			title:       "Float checker",
+			description: "Compare sequences of floating-point numbers with absolute or relative error",
+			properties: []scene.Field{
+				{Name: "abs_eps", Label: "Absolute epsilon", Type: "number", Attr: "property"},
+				{Name: "rel_eps", Label: "Relative epsilon", Type: "number", Attr: "property"},
+			},
+			compare: compareFloats,
+		},
+		{
+			name:        "checker/lines_ci",
+			title:       "Case-insensitive line checker",
+			description: "Compare lines ignoring case and trailing whitespace",
+			compare:     compareLinesIgnoreCase,
+		},
+	}
+
+	for _, c := range checkers {
+		compare := c.compare
+		fields := append(c.properties,
+			scene.Field{Name: "answer", Type: "file", Attr: "input"},
+			scene.Field{Name: "output", Type: "file", Attr: "input"},
+			scene.Field{Name: "verdict", Type: "string", Attr: "output"},
+			scene.Field{Name: "message", Type: "string", Attr: "output"},
+		)
+		buildins.Register(&scene.BlockDefinition{
+			Name:        c.name,
+			Title:       c.title,
+			Family:      "Checkers",
+			Description: c.description,
+			Fields:      fields,
+		}, func(deps *buildins.Dependencies) manager.Handler {
+			return NewChecker(compare, NewVolumeFetch(deps.JudgementRepository, deps.VolumeService, deps.VolumeStorage))
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckers(t *testing.T) {
+	type test struct {
+		name       string
+		compare    compareFunc
+		answer     string
+		output     string
+		properties map[string]interface{}
+		want       bool
+		message    string
+	}
+
+	tests := []test{
+		{name: "exact ok", compare: compareExact, answer: "1 2\n", output: "1 2\n", want: true, message: "ok"},
+		{name: "exact trailing space", compare: compareExact, answer: "1 2\n", output: "1 2 \n", message: "1st lines differ"},
+		{name: "exact missing line", compare: compareExact, answer: "1\n2", output: "1", message: "unexpected end of file"},
+		{name: "tokens ok", compare: compareTokens, answer: "1 2\n3", output: " 1\n2   3\n\n", want: true, message: "ok 3 tokens"},
+		{name: "tokens differ", compare: compareTokens, answer: "a b c", output: "a b d", message: "3rd words differ - expected: 'c', found: 'd'"},
+		{name: "tokens extra", compare: compareTokens, answer: "a", output: "a b", message: "extra tokens"},
+		{name: "float abs", compare: compareFloats, answer: "1.0 2.0", output: "1.0000001 2", want: true, message: "ok 2 numbers"},
+		{name: "float rel", compare: compareFloats, answer: "1000000", output: "1000000.5", want: true},
+		{name: "float differ", compare: compareFloats, answer: "1.0", output: "1.1", message: "1st numbers differ"},
+		{name: "float custom eps", compare: compareFloats, answer: "1.0", output: "1.1",
+			properties: map[string]interface{}{"abs_eps": 0.2}, want: true},
+		{name: "float not a number", compare: compareFloats, answer: "1.0", output: "abc", message: "expected double"},
+		{name: "lines ok", compare: compareLinesIgnoreCase, answer: "Yes\nNO\n", output: "yes  \nno\n\n", want: true, message: "ok 2 lines"},
+		{name: "lines differ", compare: compareLinesIgnoreCase, answer: "yes\nno", output: "yes\nyes", message: "2nd lines differ"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, message := tc.compare([]byte(tc.answer), []byte(tc.output), tc.properties)
+			if got != tc.want {
+				t.Fatalf("got %v, want %v: %s", got, tc.want, message)
+			}
+			if !strings.Contains(message, tc.message) {
+				t.Fatalf("message %q does not contain %q", message, tc.message)
+			}
+		})
+	}
+}
+
+func TestOrdinal(t *testing.T) {
+	for n, want := range map[int]string{1: "1st", 2: "2nd", 3: "3rd", 4: "4th", 11: "11th", 12: "12th", 21: "21st", 102: "102nd"} {
+		if got := ordinal(n); got != want {
+			t.Errorf("ordinal(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
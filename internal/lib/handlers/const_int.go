@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
@@ -10,11 +14,7 @@ import (
 type ConstInt struct {
 }
 
-func (c *ConstInt) IsMatched(tp string) bool {
-	return tp == "const_int"
-}
-
-func (c *ConstInt) Work(pr *manager.ProcessRuntime) error {
+func (c *ConstInt) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
 	process := pr.Process
 	str, ok := process.Properties["value"]
 	if !ok {
@@ -32,3 +32,18 @@ func (c *ConstInt) Work(pr *manager.ProcessRuntime) error {
 func NewConstInt() *ConstInt {
 	return &ConstInt{}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "const_int",
+		Title:       "Integer",
+		Family:      "Constants",
+		Description: "Output a constant integer",
+		Fields: []scene.Field{
+			{Name: "value", Label: "Value", Type: "number", Attr: "property"},
+			{Name: "value", Type: "int", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewConstInt()
+	})
+}
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
@@ -9,11 +13,7 @@ import (
 type ConstString struct {
 }
 
-func (c *ConstString) IsMatched(tp string) bool {
-	return tp == "const_string"
-}
-
-func (c *ConstString) Work(pr *manager.ProcessRuntime) error {
+func (c *ConstString) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
 	process := pr.Process
 	str, ok := process.Properties["value"]
 	if !ok {
@@ -31,3 +31,18 @@ func (c *ConstString) Work(pr *manager.ProcessRuntime) error {
 func NewConstString() *ConstString {
 	return &ConstString{}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "const_string",
+		Title:       "String",
+		Family:      "Constants",
+		Description: "Output a constant string",
+		Fields: []scene.Field{
+			{Name: "value", Label: "Value", Type: "string", Attr: "property"},
+			{Name: "value", Type: "string", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewConstString()
+	})
+}
@@ -1,53 +1,400 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
 	"reflect"
-
-	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"strconv"
+	"strings"
 
 	"github.com/PaesslerAG/gval"
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
+	"github.com/spf13/cast"
 )
 
-type Evaluate struct {
-}
+// language is the expression language of evaluate blocks, gval.Full with helper functions.
+var language = gval.Full(
+	gval.Function("min", fnMin),
+	gval.Function("max", fnMax),
+	gval.Function("sum", fnSum),
+	gval.Function("avg", fnAvg),
+	gval.Function("round", fnRound),
+	gval.Function("clamp", fnClamp),
+	gval.Function("lower", fnLower),
+	gval.Function("upper", fnUpper),
+	gval.Function("trim", fnTrim),
+	gval.Function("contains", fnContains),
+	gval.Function("replace", fnReplace),
+	gval.Function("split", fnSplit),
+	gval.Function("join", fnJoin),
+	gval.Function("len", fnLen),
+	gval.Function("sprintf", fnSprintf),
+	gval.Function("jsonpath", fnJSONPath),
+)
 
-func (e *Evaluate) IsMatched(tp string) bool {
-	return tp == "basic/evaluate"
+// expression is the compiled form of an evaluate block.
+type expression struct {
+	evaluable gval.Evaluable
+	outputs   []scene.Field
 }
 
-func (e *Evaluate) Work(pr *manager.ProcessRuntime) error {
-	process := pr.Process
-	exp, ok := process.Properties["exp"]
+func newExpression(exp interface{}, outputs []scene.Field) (*expression, error) {
+	expStr, ok := exp.(string)
 	if !ok {
-		return errors.New("no expression")
+		if exp == nil {
+			return nil, errors.New("no expression")
+		}
+		return nil, errors.New("expression is not string")
 	}
-	expStr, ok := exp.(string)
+	evaluable, err := language.NewEvaluable(expStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse expression")
+	}
+	return &expression{evaluable: evaluable, outputs: outputs}, nil
+}
+
+// evaluate runs the expression. With a single output the result is used as is,
+// with several outputs the expression must return an object keyed by output names.
+func (e *expression) evaluate(ctx context.Context, variables map[string]interface{}) (models.Slots, error) {
+	value, err := e.evaluable(ctx, variables)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.outputs) == 1 {
+		return models.Slots{newSlot(e.outputs[0], value)}, nil
+	}
+
+	values, ok := value.(map[string]interface{})
 	if !ok {
-		return errors.New("expression is not string")
+		return nil, errors.Errorf("expression should return an object with %d outputs, got %T", len(e.outputs), value)
+	}
+	var slots models.Slots
+	for _, output := range e.outputs {
+		v, ok := values[output.Name]
+		if !ok {
+			return nil, errors.Errorf("output %s is missing", output.Name)
+		}
+		slots = append(slots, newSlot(output, v))
+	}
+	return slots, nil
+}
+
+func newSlot(field scene.Field, value interface{}) *models.Slot {
+	slotType := field.Type
+	if slotType == "" || slotType == "any" {
+		slotType = "any"
+		if value != nil {
+			slotType = reflect.TypeOf(value).String()
+		}
+	}
+	return &models.Slot{
+		Type:  slotType,
+		Value: value,
+	}
+}
+
+func outputFields(definition *scene.BlockDefinition) []scene.Field {
+	var outputs []scene.Field
+	if definition != nil {
+		for _, field := range definition.Fields {
+			if field.Attr == "output" {
+				outputs = append(outputs, field)
+			}
+		}
+	}
+	if len(outputs) == 0 {
+		outputs = append(outputs, scene.Field{Name: "result", Type: "any", Attr: "output"})
+	}
+	return outputs
+}
+
+func compileEvaluate(definition *scene.BlockDefinition, block *engine.Block) (interface{}, error) {
+	return newExpression(block.Properties["exp"], outputFields(definition))
+}
+
+type Evaluate struct {
+}
+
+func (e *Evaluate) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	block := pr.Block()
+
+	var exp *expression
+	if block != nil {
+		exp, _ = block.Compiled.(*expression)
+	}
+	if exp == nil {
+		var err error
+		exp, err = newExpression(process.Properties["exp"], outputFields(buildins.Definition(process.Type)))
+		if err != nil {
+			return err
+		}
 	}
 
 	var inputs []interface{}
 	for _, v := range process.Inputs {
 		inputs = append(inputs, v.Value)
 	}
-
-	value, err := gval.Evaluate(expStr, map[string]interface{}{
+	variables := map[string]interface{}{
 		"inputs": inputs,
-	})
+	}
+	if block != nil {
+		for i, name := range block.InputNames {
+			if i < len(inputs) {
+				variables[name] = inputs[i]
+			}
+		}
+	}
+
+	outputs, err := exp.evaluate(ctx, variables)
 	if err != nil {
 		return err
 	}
-	process.Outputs = models.Slots{
-		{
-			Type:  reflect.TypeOf(value).String(),
-			Value: value,
-		},
-	}
+	process.Outputs = outputs
 	return nil
 }
 
 func NewEvaluateHandler() *Evaluate {
 	return &Evaluate{}
 }
+
+// numbers flattens arguments and arrays among them into a list of numbers.
+func numbers(arguments []interface{}) ([]float64, error) {
+	var res []float64
+	for _, argument := range arguments {
+		if list, ok := argument.([]interface{}); ok {
+			nested, err := numbers(list)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, nested...)
+			continue
+		}
+		v, err := cast.ToFloat64E(argument)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+func fnMin(arguments ...interface{}) (interface{}, error) {
+	values, err := numbers(arguments)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("min of nothing")
+	}
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Min(res, v)
+	}
+	return res, nil
+}
+
+func fnMax(arguments ...interface{}) (interface{}, error) {
+	values, err := numbers(arguments)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("max of nothing")
+	}
+	res := values[0]
+	for _, v := range values[1:] {
+		res = math.Max(res, v)
+	}
+	return res, nil
+}
+
+func fnSum(arguments ...interface{}) (interface{}, error) {
+	values, err := numbers(arguments)
+	if err != nil {
+		return nil, err
+	}
+	res := 0.0
+	for _, v := range values {
+		res += v
+	}
+	return res, nil
+}
+
+func fnAvg(arguments ...interface{}) (interface{}, error) {
+	values, err := numbers(arguments)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("avg of nothing")
+	}
+	res := 0.0
+	for _, v := range values {
+		res += v
+	}
+	return res / float64(len(values)), nil
+}
+
+func fnRound(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) != 1 && len(arguments) != 2 {
+		return nil, errors.New("round expects a number and optional digits")
+	}
+	x, err := cast.ToFloat64E(arguments[0])
+	if err != nil {
+		return nil, err
+	}
+	digits := 0
+	if len(arguments) == 2 {
+		if digits, err = cast.ToIntE(arguments[1]); err != nil {
+			return nil, err
+		}
+	}
+	p := math.Pow(10, float64(digits))
+	return math.Round(x*p) / p, nil
+}
+
+func fnClamp(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) != 3 {
+		return nil, errors.New("clamp expects a number, lower and upper bounds")
+	}
+	values, err := numbers(arguments)
+	if err != nil {
+		return nil, err
+	}
+	return math.Max(values[1], math.Min(values[2], values[0])), nil
+}
+
+func fnLower(s string) string {
+	return strings.ToLower(s)
+}
+
+func fnUpper(s string) string {
+	return strings.ToUpper(s)
+}
+
+func fnTrim(s string) string {
+	return strings.TrimSpace(s)
+}
+
+func fnContains(s, substr string) bool {
+	return strings.Contains(s, substr)
+}
+
+func fnReplace(s, old, new string) string {
+	return strings.ReplaceAll(s, old, new)
+}
+
+func fnSplit(s, sep string) []interface{} {
+	var res []interface{}
+	for _, v := range strings.Split(s, sep) {
+		res = append(res, v)
+	}
+	return res
+}
+
+func fnJoin(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) != 2 {
+		return nil, errors.New("join expects an array and a separator")
+	}
+	list, ok := arguments[0].([]interface{})
+	if !ok {
+		return nil, errors.Errorf("join expects an array, got %T", arguments[0])
+	}
+	var elements []string
+	for _, v := range list {
+		elements = append(elements, cast.ToString(v))
+	}
+	return strings.Join(elements, cast.ToString(arguments[1])), nil
+}
+
+func fnLen(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) != 1 {
+		return nil, errors.New("len expects one argument")
+	}
+	switch v := arguments[0].(type) {
+	case string:
+		return float64(len(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, errors.Errorf("len of %T", arguments[0])
+}
+
+func fnSprintf(arguments ...interface{}) (interface{}, error) {
+	if len(arguments) == 0 {
+		return nil, errors.New("sprintf expects a format")
+	}
+	format, ok := arguments[0].(string)
+	if !ok {
+		return nil, errors.New("format is not string")
+	}
+	return fmt.Sprintf(format, arguments[1:]...), nil
+}
+
+// fnJSONPath selects a value by a path like "$.cases[0].score". A string value is parsed as JSON first.
+func fnJSONPath(value interface{}, path string) (interface{}, error) {
+	if s, ok := value.(string); ok {
+		if err := json.Unmarshal([]byte(s), &value); err != nil {
+			return nil, errors.Wrap(err, "parse json")
+		}
+	}
+
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	path = strings.ReplaceAll(path, "[", ".[")
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			continue
+		}
+		if strings.HasPrefix(key, "[") && strings.HasSuffix(key, "]") {
+			index, err := strconv.Atoi(key[1 : len(key)-1])
+			if err != nil {
+				return nil, errors.Errorf("invalid index %s", key)
+			}
+			list, ok := value.([]interface{})
+			if !ok || index < 0 || index >= len(list) {
+				return nil, errors.Errorf("index %d out of range", index)
+			}
+			value = list[index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("%s is not an object key", key)
+		}
+		if value, ok = object[key]; !ok {
+			return nil, errors.Errorf("unknown key %s", key)
+		}
+	}
+	return value, nil
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "basic/evaluate",
+		Title:       "Evaluate",
+		Family:      "Basic",
+		Description: "Evaluate an expression over inputs",
+		Fields: []scene.Field{
+			{Name: "exp", Label: "Expression", Type: "string", Attr: "property"},
+			{Name: "a", Type: "any", Attr: "input"},
+			{Name: "b", Type: "any", Attr: "input"},
+			{Name: "c", Type: "any", Attr: "input"},
+			{Name: "d", Type: "any", Attr: "input"},
+			{Name: "result", Type: "any", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewEvaluateHandler()
+	})
+	buildins.RegisterCompiler("basic/evaluate", compileEvaluate)
+}
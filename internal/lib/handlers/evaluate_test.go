@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+)
+
+func TestExpression(t *testing.T) {
+	type test struct {
+		name    string
+		exp     string
+		outputs []string
+		want    []interface{}
+		wantErr bool
+	}
+
+	variables := map[string]interface{}{
+		"score":  "{\"cases\":[{\"score\":30},{\"score\":70}]}",
+		"name":   "  Alice ",
+		"inputs": []interface{}{1.0, 5.0, 3.0},
+	}
+
+	tests := []test{
+		{name: "min", exp: "min(inputs)", want: []interface{}{1.0}},
+		{name: "max", exp: "max(2, inputs)", want: []interface{}{5.0}},
+		{name: "sum", exp: "sum(inputs, 1)", want: []interface{}{10.0}},
+		{name: "avg", exp: "avg(inputs)", want: []interface{}{3.0}},
+		{name: "round", exp: "round(2 / 3, 2)", want: []interface{}{0.67}},
+		{name: "clamp", exp: "clamp(150, 0, 100)", want: []interface{}{100.0}},
+		{name: "strings", exp: "upper(trim(name)) + len(split(\"a,b\", \",\"))", want: []interface{}{"ALICE2"}},
+		{name: "jsonpath", exp: "jsonpath(score, \"$.cases[1].score\")", want: []interface{}{70.0}},
+		{name: "named outputs", exp: "{\"total\": sum(inputs), \"passed\": contains(name, \"Ali\")}",
+			outputs: []string{"total", "passed"}, want: []interface{}{9.0, true}},
+		{name: "missing output", exp: "{\"total\": 1}", outputs: []string{"total", "passed"}, wantErr: true},
+		{name: "unknown key", exp: "jsonpath(score, \"cases[0].time\")", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			definition := &scene.BlockDefinition{}
+			for _, name := range tc.outputs {
+				definition.Fields = append(definition.Fields, scene.Field{Name: name, Type: "any", Attr: "output"})
+			}
+			exp, err := newExpression(tc.exp, outputFields(definition))
+			if err != nil {
+				t.Fatal(err)
+			}
+			slots, err := exp.evaluate(context.Background(), variables)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error %v", err)
+			}
+			var got []interface{}
+			for _, slot := range slots {
+				got = append(got, slot.Value)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExpressionSyntaxError(t *testing.T) {
+	if _, err := newExpression("min(1, ", nil); err == nil {
+		t.Fatal("expected syntax error")
+	}
+}
@@ -1,6 +1,10 @@
 package handlers
 
 import (
+	"context"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
@@ -9,11 +13,7 @@ import (
 type File struct {
 }
 
-func (f File) IsMatched(tp string) bool {
-	return tp == "basic/file"
-}
-
-func (f File) Work(pr *manager.ProcessRuntime) error {
+func (f File) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
 	process := pr.Process
 	url, ok := process.Properties["url"]
 	if !ok {
@@ -31,3 +31,18 @@ func (f File) Work(pr *manager.ProcessRuntime) error {
 func NewFileHandler() *File {
 	return &File{}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "basic/file",
+		Title:       "File",
+		Family:      "Basic",
+		Description: "Output a file reference",
+		Fields: []scene.Field{
+			{Name: "url", Label: "URL", Type: "string", Attr: "property"},
+			{Name: "file", Type: "file", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewFileHandler()
+	})
+}
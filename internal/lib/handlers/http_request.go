@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+)
+
+const defaultRequestTimeout = 10 * time.Second
+
+// HttpRequest posts input slots as a JSON object keyed by input names to a URL.
+// The body is signed with HMAC-SHA256 of the secret property, if set.
+type HttpRequest struct {
+	client *http.Client
+}
+
+func (h *HttpRequest) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	url := cast.ToString(process.Properties["url"])
+	if url == "" {
+		return errors.New("no url")
+	}
+
+	timeout := defaultRequestTimeout
+	if v := cast.ToDuration(process.Properties["timeout"]); v > 0 {
+		timeout = v
+	}
+
+	payload := map[string]interface{}{
+		"judgement_id": pr.Judgement.Name,
+	}
+	var names []string
+	if block := pr.Block(); block != nil {
+		names = block.InputNames
+	}
+	for i, input := range process.Inputs {
+		name := "input" + cast.ToString(i)
+		if i < len(names) {
+			name = names[i]
+		}
+		payload[name] = input.Value
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	code, respBody, err := webhooks.Post(ctx, h.client, url, cast.ToString(process.Properties["secret"]), "", body)
+	if err != nil {
+		return errors.Wrap(err, "http request")
+	}
+
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "number",
+			Value: code,
+		},
+		&models.Slot{
+			Type:  "string",
+			Value: string(respBody),
+		},
+	}
+	return nil
+}
+
+func NewHttpRequest(client *http.Client) *HttpRequest {
+	return &HttpRequest{client: client}
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "http_request",
+		Title:       "HTTP request",
+		Family:      "Results",
+		Description: "POST inputs as JSON to a URL, signed with HMAC-SHA256 of the secret",
+		Fields: []scene.Field{
+			{Name: "url", Label: "URL", Type: "string", Attr: "property"},
+			{Name: "secret", Label: "Secret", Type: "string", Attr: "property"},
+			{Name: "timeout", Label: "Timeout, like 5s", Type: "string", Attr: "property"},
+			{Name: "a", Type: "any", Attr: "input"},
+			{Name: "b", Type: "any", Attr: "input"},
+			{Name: "c", Type: "any", Attr: "input"},
+			{Name: "d", Type: "any", Attr: "input"},
+			{Name: "status", Type: "number", Attr: "output"},
+			{Name: "body", Type: "string", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewHttpRequest(&http.Client{})
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/app/webhooks"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+)
+
+func TestHttpRequest(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if got := r.Header.Get(webhooks.SignatureHeader); got != webhooks.Sign("secret", body) {
+			t.Errorf("bad signature %s", got)
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	pr := &manager.ProcessRuntime{
+		Judgement: &models.Judgement{Name: "judgement"},
+		Process: &models.Process{
+			Properties: map[string]interface{}{"url": server.URL, "secret": "secret"},
+			Inputs:     models.Slots{{Type: "number", Value: 100}},
+		},
+	}
+	if err := NewHttpRequest(server.Client()).Work(context.Background(), pr); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload["judgement_id"] != "judgement" || payload["input0"] != 100.0 {
+		t.Errorf("unexpected payload %v", payload)
+	}
+	outputs := pr.Process.Outputs
+	if len(outputs) != 2 || outputs[0].Value != http.StatusOK || outputs[1].Value != "ok" {
+		t.Errorf("unexpected outputs %v", outputs)
+	}
+
+	pr.Process.Properties["url"] = server.URL + "/%zz"
+	if err := NewHttpRequest(server.Client()).Work(context.Background(), pr); err == nil {
+		t.Error("expected error for invalid url")
+	}
+}
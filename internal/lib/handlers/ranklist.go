@@ -1,31 +1,39 @@
 package handlers
 
 import (
-	"github.com/infinity-oj/server-v2/internal/app/accounts"
-	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"context"
 
+	"github.com/infinity-oj/server-v2/internal/app/accounts"
 	"github.com/infinity-oj/server-v2/internal/app/ranklists"
+	"github.com/infinity-oj/server-v2/internal/app/submissions"
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 )
 
+// RankList records a metric of the submission in a ranklist. Each account has a single
+// record per key, which is replaced according to the configured policy.
 type RankList struct {
 	rr ranklists.Repository
 	ar accounts.Repository
+	sr submissions.Repository
 }
 
-func (r RankList) IsMatched(tp string) bool {
-	return tp == "ranklist"
-}
+func (r RankList) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-func (r RankList) Work(pr *manager.ProcessRuntime) error {
 	process := pr.Process
 	iRankListID, ok := process.Properties["ranklistID"]
 	if !ok {
 		return errors.New("ranklist id is not found")
 	}
 	rankListID := cast.ToUint64(iRankListID)
-	rl, err := r.rr.GetRankList(rankListID)
+	rl, err := r.rr.GetRankListMetrics(rankListID)
 	if err != nil {
 		return err
 	}
@@ -33,36 +41,90 @@ func (r RankList) Work(pr *manager.ProcessRuntime) error {
 		return errors.New("ranklist is not found")
 	}
 
-	iAccountID, ok := process.Properties["accountID"]
+	iKey, ok := process.Properties["metric_key"]
 	if !ok {
-		return errors.New("account id is not found")
+		return errors.New("key is not found")
 	}
-	accountID := cast.ToUint64(iAccountID)
+	key := cast.ToString(iKey)
 
-	account, err := r.ar.GetAccountById(accountID)
+	policy, err := ranklists.ParsePolicy(cast.ToString(process.Properties["policy"]))
 	if err != nil {
 		return err
 	}
-	if rl == nil {
-		return errors.New("account is not found")
+
+	judgement := pr.Judgement
+	submissionID := judgement.SubmissionID
+	if submissionID == 0 {
+		submissionID = cast.ToUint64(judgement.Args["submission"])
 	}
 
-	iKey, ok := process.Properties["metric_key"]
-	if !ok {
-		return errors.New("key is not found")
+	accountID := cast.ToUint64(process.Properties["accountID"])
+	if accountID == 0 {
+		if submissionID == 0 {
+			return errors.New("judgement has no submission to resolve account")
+		}
+		submission, err := r.sr.GetSubmissionById(submissionID)
+		if err != nil {
+			return err
+		}
+		if submission == nil {
+			return errors.New("submission is not found")
+		}
+		accountID = submission.SubmitterId
 	}
-	key := cast.ToString(iKey)
 
-	value := process.Inputs[0].Value
+	account, err := r.ar.GetAccountById(accountID)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		return errors.New("account is not found")
+	}
 
-	_, err = r.rr.CreateRankListRecord(rl, account, key, value)
+	accepted := true
+	if len(process.Inputs) > 1 && process.Inputs[1] != nil {
+		switch v := process.Inputs[1].Value.(type) {
+		case string:
+			accepted = models.JudgeStatus(v) == models.Accepted
+		default:
+			accepted = cast.ToBool(v)
+		}
+	}
 
+	_, err = r.rr.UpsertRankListRecord(rl, &models.RankListRecord{
+		AccountID:    account.ID,
+		Key:          key,
+		Value:        cast.ToFloat64(process.Inputs[0].Value),
+		SubmissionID: submissionID,
+		JudgementID:  judgement.ID,
+		Accepted:     accepted,
+	}, policy)
 	return err
 }
 
-func NewRankList(rr ranklists.Repository, ar accounts.Repository) *RankList {
+func NewRankList(rr ranklists.Repository, ar accounts.Repository, sr submissions.Repository) *RankList {
 	return &RankList{
 		rr: rr,
 		ar: ar,
+		sr: sr,
 	}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "ranklist",
+		Title:       "Ranklist",
+		Family:      "Results",
+		Description: "Record a metric in a ranklist, keeping one record per account and key",
+		Fields: []scene.Field{
+			{Name: "ranklistID", Label: "Ranklist ID", Type: "number", Attr: "property"},
+			{Name: "accountID", Label: "Account ID (submitter by default)", Type: "number", Attr: "property"},
+			{Name: "metric_key", Label: "Metric key", Type: "string", Attr: "property"},
+			{Name: "policy", Label: "Policy (best, latest or first_accepted)", Type: "string", Attr: "property"},
+			{Name: "value", Type: "number", Attr: "input"},
+			{Name: "verdict", Type: "string", Attr: "input"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewRankList(deps.RankListRepository, deps.AccountRepository, deps.SubmissionRepository)
+	})
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"context"
+
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/spf13/cast"
 )
@@ -10,11 +14,7 @@ type Result struct {
 	jr judgements.Repository
 }
 
-func (r *Result) IsMatched(tp string) bool {
-	return tp == "result"
-}
-
-func (r *Result) Work(pr *manager.ProcessRuntime) error {
+func (r *Result) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
 	pr.Mutex.Lock()
 	defer pr.Mutex.Unlock()
 	v := pr.Process.Inputs[0].Value
@@ -26,3 +26,17 @@ func (r *Result) Work(pr *manager.ProcessRuntime) error {
 func NewResult(jr judgements.Repository) *Result {
 	return &Result{jr: jr}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "result",
+		Title:       "Result",
+		Family:      "Results",
+		Description: "Set score of the judgement",
+		Fields: []scene.Field{
+			{Name: "score", Type: "number", Attr: "input"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewResult(deps.JudgementRepository)
+	})
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+)
+
+const (
+	defaultScriptSteps  = 1000000
+	defaultScriptMemory = 64 << 20
+)
+
+// scriptModules are the only modules visible to scripts besides the inputs.
+// Scripts have no load statement, file system or network access.
+var scriptModules = starlark.StringDict{
+	"json": starlarkjson.Module,
+	"math": starlarkmath.Module,
+}
+
+// scriptOptions allows top-level loops and reassignment, which scoring scripts commonly need.
+var scriptOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// script is the compiled form of a script block.
+type script struct {
+	src       string
+	inputs    []string
+	program   *starlark.Program
+	outputs   []scene.Field
+	maxSteps  uint64
+	maxMemory uint64
+}
+
+func newScript(src interface{}, properties map[string]interface{}, inputs []string, outputs []scene.Field) (*script, error) {
+	srcStr, ok := src.(string)
+	if !ok {
+		if src == nil {
+			return nil, errors.New("no script")
+		}
+		return nil, errors.New("script is not string")
+	}
+
+	predeclared := map[string]bool{"inputs": true}
+	for name := range scriptModules {
+		predeclared[name] = true
+	}
+	for _, name := range inputs {
+		predeclared[name] = true
+	}
+	_, program, err := starlark.SourceProgramOptions(scriptOptions, "script.star", srcStr, func(name string) bool {
+		return predeclared[name]
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "parse script")
+	}
+
+	s := &script{
+		src:       srcStr,
+		inputs:    inputs,
+		program:   program,
+		outputs:   outputs,
+		maxSteps:  defaultScriptSteps,
+		maxMemory: defaultScriptMemory,
+	}
+	if v, ok := properties["max_steps"]; ok && cast.ToUint64(v) > 0 {
+		s.maxSteps = cast.ToUint64(v)
+	}
+	if v, ok := properties["max_memory"]; ok && cast.ToUint64(v) > 0 {
+		s.maxMemory = cast.ToUint64(v) << 20
+	}
+	return s, nil
+}
+
+// run executes the script with variables in a script process and reads output slots from its
+// globals. The script fails once it exceeds its steps or its memory, and is killed once ctx is
+// done.
+func (s *script) run(ctx context.Context, variables map[string]interface{}) (models.Slots, error) {
+	for name, v := range variables {
+		if _, err := toStarlark(v); err != nil {
+			return nil, errors.Wrapf(err, "input %s", name)
+		}
+	}
+	values, err := runScriptProcess(ctx, &scriptRequest{
+		Source:    s.src,
+		Inputs:    s.inputs,
+		Outputs:   s.outputs,
+		MaxSteps:  s.maxSteps,
+		MaxMemory: s.maxMemory,
+		Variables: variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var slots models.Slots
+	for i, output := range s.outputs {
+		slots = append(slots, newSlot(output, values[i]))
+	}
+	return slots, nil
+}
+
+// exec executes the script with variables in this process, bounded by its steps only, and
+// returns the values of its outputs.
+func (s *script) exec(variables map[string]interface{}) ([]interface{}, error) {
+	predeclared := starlark.StringDict{}
+	for name, module := range scriptModules {
+		predeclared[name] = module
+	}
+	for name, v := range variables {
+		value, err := toStarlark(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "input %s", name)
+		}
+		predeclared[name] = value
+	}
+
+	thread := &starlark.Thread{
+		Name:  "script",
+		Print: func(*starlark.Thread, string) {},
+	}
+	thread.SetMaxExecutionSteps(s.maxSteps)
+
+	globals, err := s.program.Init(thread, predeclared)
+	if err != nil {
+		return nil, errors.Wrap(err, "run script")
+	}
+
+	var values []interface{}
+	for _, output := range s.outputs {
+		value, ok := globals[output.Name]
+		if !ok {
+			return nil, errors.Errorf("output %s is not assigned", output.Name)
+		}
+		v, err := fromStarlark(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "output %s", output.Name)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func toStarlark(v interface{}) (starlark.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return starlark.MakeInt64(cast.ToInt64(v)), nil
+	case float32, float64:
+		return starlark.Float(cast.ToFloat64(v)), nil
+	case []interface{}:
+		var elems []starlark.Value
+		for _, e := range v {
+			value, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, value)
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, k := range keys {
+			value, err := toStarlark(v[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), value); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, errors.Errorf("unsupported type %T", v)
+}
+
+func fromStarlark(v starlark.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		f, _ := new(big.Float).SetInt(v.BigInt()).Float64()
+		return f, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.Indexable:
+		var res []interface{}
+		for i := 0; i < v.Len(); i++ {
+			e, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, e)
+		}
+		return res, nil
+	case *starlark.Dict:
+		res := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, errors.Errorf("dict key %s is not string", item[0])
+			}
+			e, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			res[k] = e
+		}
+		return res, nil
+	}
+	return nil, errors.Errorf("unsupported type %s", v.Type())
+}
+
+func inputFields(definition *scene.BlockDefinition) []string {
+	var inputs []string
+	if definition != nil {
+		for _, field := range definition.Fields {
+			if field.Attr == "input" {
+				inputs = append(inputs, field.Name)
+			}
+		}
+	}
+	return inputs
+}
+
+func compileScript(definition *scene.BlockDefinition, block *engine.Block) (interface{}, error) {
+	return newScript(block.Properties["script"], block.Properties, inputFields(definition), outputFields(definition))
+}
+
+// Script runs a Starlark script with input slots bound to variables named after the
+// input fields. Output slots are read from global variables named after the output fields.
+type Script struct {
+}
+
+func (s *Script) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	block := pr.Block()
+
+	var sc *script
+	if block != nil {
+		sc, _ = block.Compiled.(*script)
+	}
+	if sc == nil {
+		definition := buildins.Definition(process.Type)
+		var err error
+		sc, err = newScript(process.Properties["script"], process.Properties, inputFields(definition), outputFields(definition))
+		if err != nil {
+			return err
+		}
+	}
+
+	var inputs []interface{}
+	for _, v := range process.Inputs {
+		inputs = append(inputs, v.Value)
+	}
+	variables := map[string]interface{}{
+		"inputs": inputs,
+	}
+	if block != nil {
+		for i, name := range block.InputNames {
+			if i < len(inputs) {
+				variables[name] = inputs[i]
+			}
+		}
+	}
+
+	outputs, err := sc.run(ctx, variables)
+	if err != nil {
+		return err
+	}
+	process.Outputs = outputs
+	return nil
+}
+
+func NewScriptHandler() *Script {
+	return &Script{}
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "basic/script",
+		Title:       "Script",
+		Family:      "Basic",
+		Description: "Run a Starlark script over inputs, assign outputs as global variables",
+		Fields: []scene.Field{
+			{Name: "script", Label: "Script", Type: "string", Attr: "property"},
+			{Name: "max_steps", Label: "Max steps", Type: "number", Attr: "property"},
+			{Name: "max_memory", Label: "Max memory (MiB)", Type: "number", Attr: "property"},
+			{Name: "a", Type: "any", Attr: "input"},
+			{Name: "b", Type: "any", Attr: "input"},
+			{Name: "c", Type: "any", Attr: "input"},
+			{Name: "d", Type: "any", Attr: "input"},
+			{Name: "result", Type: "any", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewScriptHandler()
+	})
+	buildins.RegisterCompiler("basic/script", compileScript)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// limitMemory lets the process map at most max bytes of data beyond what it has mapped already,
+// so an allocation past it fails however large it is.
+func limitMemory(max uint64) error {
+	used, err := dataSize()
+	if err != nil {
+		return errors.Wrap(err, "limit script memory")
+	}
+	limit := &syscall.Rlimit{Cur: used + max, Max: used + max}
+	if err := syscall.Setrlimit(syscall.RLIMIT_DATA, limit); err != nil {
+		return errors.Wrap(err, "limit script memory")
+	}
+	return nil
+}
+
+// dataSize returns the size of the data segment of the process, which the kernel checks
+// against RLIMIT_DATA.
+func dataSize() (uint64, error) {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmData:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			return kb << 10, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("no VmData in /proc/self/status")
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package handlers
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// limitMemory fails, scripts are only run where their memory is limited.
+func limitMemory(max uint64) error {
+	return errors.Errorf("script memory limit is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/pkg/errors"
+)
+
+// scriptProcessEnv makes the executable serve a single script request instead of starting, so
+// scripts run in a process of their own with its memory limited by the kernel.
+const scriptProcessEnv = "IOJ_SCRIPT_PROCESS"
+
+// scriptGCPercent keeps garbage from counting much against the memory of a script.
+const scriptGCPercent = 20
+
+// scriptRequest is what a script process runs, read from its stdin.
+type scriptRequest struct {
+	Source    string
+	Inputs    []string
+	Outputs   []scene.Field
+	MaxSteps  uint64
+	MaxMemory uint64
+	Variables map[string]interface{}
+}
+
+// scriptResponse holds the values of the outputs, or why the script failed, written to stdout.
+type scriptResponse struct {
+	Values []interface{}
+	Err    string
+}
+
+func init() {
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+
+	if os.Getenv(scriptProcessEnv) != "" {
+		os.Exit(serveScript(os.Stdin, os.Stdout))
+	}
+}
+
+// serveScript runs the script request read from r and writes the response to w. Exceeding the
+// memory limit aborts the process with the runtime failing to allocate.
+func serveScript(r io.Reader, w io.Writer) int {
+	request := &scriptRequest{}
+	if err := gob.NewDecoder(r).Decode(request); err != nil {
+		return 1
+	}
+	response := &scriptResponse{}
+	values, err := execScript(request)
+	if err != nil {
+		response.Err = err.Error()
+	}
+	response.Values = values
+	if err := gob.NewEncoder(w).Encode(response); err != nil {
+		return 1
+	}
+	return 0
+}
+
+func execScript(request *scriptRequest) ([]interface{}, error) {
+	s, err := newScript(request.Source, nil, request.Inputs, request.Outputs)
+	if err != nil {
+		return nil, err
+	}
+	s.maxSteps = request.MaxSteps
+
+	debug.SetGCPercent(scriptGCPercent)
+	if err := limitMemory(request.MaxMemory); err != nil {
+		return nil, err
+	}
+	return s.exec(request.Variables)
+}
+
+// outOfMemory reports whether the runtime aborted the script process as an allocation failed.
+func outOfMemory(stderr string) bool {
+	return strings.Contains(stderr, "out of memory") || strings.Contains(stderr, "cannot allocate memory")
+}
+
+// runScriptProcess runs the request in a script process started from this executable, which is
+// killed once ctx is done.
+func runScriptProcess(ctx context.Context, request *scriptRequest) ([]interface{}, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "script process")
+	}
+	stdin := &bytes.Buffer{}
+	if err := gob.NewEncoder(stdin).Encode(request); err != nil {
+		return nil, errors.Wrap(err, "encode script request")
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, executable)
+	cmd.Env = []string{scriptProcessEnv + "=1", "GOMAXPROCS=1"}
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if outOfMemory(stderr.String()) {
+			return nil, errors.New("memory limit exceeded")
+		}
+		return nil, errors.Wrap(err, "script process")
+	}
+
+	response := &scriptResponse{}
+	if err := gob.NewDecoder(stdout).Decode(response); err != nil {
+		return nil, errors.Wrap(err, "decode script response")
+	}
+	if response.Err != "" {
+		return nil, errors.New(response.Err)
+	}
+	if len(response.Values) != len(request.Outputs) {
+		return nil, errors.New("script process returned wrong number of outputs")
+	}
+	return response.Values, nil
+}
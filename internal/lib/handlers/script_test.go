@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+)
+
+func TestScript(t *testing.T) {
+	type test struct {
+		name       string
+		src        string
+		properties map[string]interface{}
+		outputs    []string
+		want       []interface{}
+		err        string
+	}
+
+	variables := map[string]interface{}{
+		"report": "{\"cases\":[{\"score\":30},{\"score\":70}]}",
+		"inputs": []interface{}{1.0, 2.0},
+	}
+
+	tests := []test{
+		{name: "json report", src: "result = 0\nfor c in json.decode(report)['cases']:\n    result += c['score']\n",
+			want: []interface{}{int64(100)}},
+		{name: "curve", src: "result = math.floor(100 * math.pow(inputs[1] / 4, 0.5))", want: []interface{}{int64(70)}},
+		{name: "named outputs", src: "def f(x):\n    return x * 2\nscore = f(inputs[0])\nmessage = 'ok'\n",
+			outputs: []string{"score", "message"}, want: []interface{}{2.0, "ok"}},
+		{name: "missing output", src: "x = 1", err: "not assigned"},
+		{name: "steps", src: "def f():\n    for i in range(1000000):\n        pass\nf()\nresult = 1\n",
+			properties: map[string]interface{}{"max_steps": 1000}, err: "too many steps"},
+		{name: "memory", src: "def f():\n    return ['x' * 1024 for i in range(1000000)]\nresult = len(f())\n",
+			properties: map[string]interface{}{"max_memory": 1, "max_steps": 100000000}, err: "memory limit exceeded"},
+		{name: "memory in one step", src: "result = len('x' * (1 << 29))\n",
+			properties: map[string]interface{}{"max_memory": 16}, err: "memory limit exceeded"},
+		{name: "no load", src: "load('os', 'system')\nresult = 1\n", err: "load not implemented"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			definition := &scene.BlockDefinition{Fields: []scene.Field{{Name: "report", Attr: "input"}}}
+			for _, name := range tc.outputs {
+				definition.Fields = append(definition.Fields, scene.Field{Name: name, Type: "any", Attr: "output"})
+			}
+			s, err := newScript(tc.src, tc.properties, inputFields(definition), outputFields(definition))
+			if err != nil {
+				t.Fatal(err)
+			}
+			slots, err := s.run(context.Background(), variables)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []interface{}
+			for _, slot := range slots {
+				got = append(got, slot.Value)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,11 @@
 package handlers
 
 import (
+	"context"
+
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/spf13/cast"
@@ -14,11 +18,11 @@ type VolumeCreate struct {
 	vs VS.Service
 }
 
-func (r *VolumeCreate) IsMatched(tp string) bool {
-	return tp == "volume_create"
-}
+func (r *VolumeCreate) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-func (r *VolumeCreate) Work(pr *manager.ProcessRuntime) error {
 	judgement := pr.Judgement
 	v := cast.ToString(judgement.Args["volume"])
 	if v == "" {
@@ -46,3 +50,17 @@ func (r *VolumeCreate) Work(pr *manager.ProcessRuntime) error {
 func NewVolumeCreate(jr judgements.Repository, vs VS.Service) *VolumeCreate {
 	return &VolumeCreate{jr: jr, vs: vs}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_create",
+		Title:       "Create volume",
+		Family:      "Volumes",
+		Description: "Create a working volume for the judgement",
+		Fields: []scene.Field{
+			{Name: "volume", Type: "volume", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeCreate(deps.JudgementRepository, deps.VolumeService)
+	})
+}
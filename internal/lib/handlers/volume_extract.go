@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	VS "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+)
+
+// VolumeExtract extracts a zip file into a new layer on top of a volume.
+type VolumeExtract struct {
+	vs    VS.Service
+	fetch *VolumeFetch
+}
+
+func (r *VolumeExtract) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	v := cast.ToString(process.Inputs[0].Value)
+	if v == "" {
+		return errors.New("missing volume")
+	}
+
+	archive, size, err := r.spool(ctx, cast.ToString(process.Inputs[1].Value))
+	if err != nil {
+		return errors.Wrap(err, "read archive")
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	volume, err := r.vs.ExtractArchive(v, cast.ToString(process.Properties["dir"]), archive, size, false)
+	if err != nil {
+		return err
+	}
+
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "volume",
+			Value: volume.Name,
+		},
+	}
+	return nil
+}
+
+// spool copies the archive into a temporary file, as reading a zip needs random access.
+// The caller closes and removes the file.
+func (r *VolumeExtract) spool(ctx context.Context, vp string) (*os.File, int64, error) {
+	reader, _, err := r.fetch.open(ctx, vp)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	file, err := ioutil.TempFile("", "archive*.zip")
+	if err != nil {
+		return nil, 0, err
+	}
+	size, err := io.Copy(file, &contextReader{ctx: ctx, r: reader})
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, 0, err
+	}
+	return file, size, nil
+}
+
+func NewVolumeExtract(vs VS.Service, fetch *VolumeFetch) *VolumeExtract {
+	return &VolumeExtract{vs: vs, fetch: fetch}
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_extract",
+		Title:       "Extract archive",
+		Family:      "Volumes",
+		Description: "Extract a zip file into a new layer of the volume",
+		Fields: []scene.Field{
+			{Name: "dir", Label: "Directory", Type: "string", Attr: "property"},
+			{Name: "volume", Type: "volume", Attr: "input"},
+			{Name: "archive", Type: "file", Attr: "input"},
+			{Name: "layer", Type: "volume", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeExtract(deps.VolumeService,
+			NewVolumeFetch(deps.JudgementRepository, deps.VolumeService, deps.VolumeStorage))
+	})
+}
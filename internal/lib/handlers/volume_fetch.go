@@ -1,62 +1,108 @@
 package handlers
 
 import (
+	"context"
+	"io"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
 
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
-
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
 	"github.com/spf13/cast"
 
-	VR "github.com/infinity-oj/server-v2/internal/app/volumes/repositories"
-	VS "github.com/infinity-oj/server-v2/internal/app/volumes/storages"
+	VSV "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+	VST "github.com/infinity-oj/server-v2/internal/app/volumes/storages"
 )
 
 type VolumeFetch struct {
-	jr judgements.Repository
-	vr VR.Repository
-	vs VS.Storage
+	jr  judgements.Repository
+	vsv VSV.Service
+	vst VST.Storage
 }
 
-func (r *VolumeFetch) IsMatched(tp string) bool {
-	return tp == "volume_fetch"
+func (r *VolumeFetch) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	bytes, err := r.fetch(ctx, cast.ToString(process.Inputs[0].Value))
+	if err != nil {
+		return err
+	}
+
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "bytes",
+			Value: string(bytes),
+		},
+	}
+	return nil
 }
 
-func (r *VolumeFetch) Work(pr *manager.ProcessRuntime) error {
-	process := pr.Process
-	vp := cast.ToString(process.Inputs[0].Value)
-	tmp := strings.Split(vp, "/")
+// fetch reads content of a file referenced as "<volume>/<filename>".
+func (r *VolumeFetch) fetch(ctx context.Context, vp string) ([]byte, error) {
+	reader, _, err := r.open(ctx, vp)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return ioutil.ReadAll(&contextReader{ctx: ctx, r: reader})
+}
+
+// open opens a file referenced as "<volume>/<filename>" and returns its size.
+// The file is looked up in the volume together with its base layers.
+func (r *VolumeFetch) open(ctx context.Context, vp string) (io.ReadCloser, int64, error) {
+	tmp := strings.SplitN(vp, "/", 2)
+	if len(tmp) != 2 {
+		return nil, 0, errors.Errorf("invalid file reference: %s", vp)
+	}
 
 	volumeName := tmp[0]
 	fileName := filepath.Join("/", tmp[1])
 
-	volume, err := r.vr.GetVolume(volumeName)
+	volume, err := r.vsv.GetVolume(volumeName)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 
-	file, err := r.vs.FetchFile(volume, fileName)
-	if err != nil {
-		return err
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
 	}
 
-	bytes, err := ioutil.ReadFile(file.Name())
-	if err != nil {
-		return err
-	}
+	return r.vst.FetchFile(volume, fileName)
+}
 
-	process.Outputs = models.Slots{
-		&models.Slot{
-			Type:  "bytes",
-			Value: string(bytes),
-		},
+// contextReader stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return nil
+	return r.r.Read(p)
+}
+
+func NewVolumeFetch(jr judgements.Repository, vsv VSV.Service, vst VST.Storage) *VolumeFetch {
+	return &VolumeFetch{jr: jr, vsv: vsv, vst: vst}
 }
 
-func NewVolumeFetch(jr judgements.Repository, vr VR.Repository, vs VS.Storage) *VolumeFetch {
-	return &VolumeFetch{jr: jr, vr: vr, vs: vs}
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_fetch",
+		Title:       "Fetch file",
+		Family:      "Volumes",
+		Description: "Read content of a volume file",
+		Fields: []scene.Field{
+			{Name: "file", Type: "file", Attr: "input"},
+			{Name: "content", Type: "bytes", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeFetch(deps.JudgementRepository, deps.VolumeService, deps.VolumeStorage)
+	})
 }
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	VS "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+)
+
+// VolumeList lists files of a volume directory matching a glob pattern.
+// The output is a list of file references, each usable as a file slot.
+type VolumeList struct {
+	vs VS.Service
+}
+
+func (r *VolumeList) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	process := pr.Process
+	v := cast.ToString(process.Inputs[0].Value)
+	if v == "" {
+		return errors.New("missing volume")
+	}
+
+	fileRecords, err := r.vs.ListFiles(v,
+		cast.ToString(process.Properties["dir"]),
+		cast.ToString(process.Properties["pattern"]),
+		cast.ToBool(process.Properties["recursive"]),
+	)
+	if err != nil {
+		return err
+	}
+
+	files := make([]interface{}, 0, len(fileRecords))
+	for _, fileRecord := range fileRecords {
+		files = append(files, fileReference(v, fileRecord.FilePath))
+	}
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "list",
+			Value: files,
+		},
+	}
+	return nil
+}
+
+// fileReference formats a file slot value as "<volume>/<filename>".
+func fileReference(volume, filePath string) string {
+	return volume + "/" + strings.TrimPrefix(filePath, "/")
+}
+
+func NewVolumeList(vs VS.Service) *VolumeList {
+	return &VolumeList{vs: vs}
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_list",
+		Title:       "List files",
+		Family:      "Volumes",
+		Description: "List files of a volume directory matching a glob pattern",
+		Fields: []scene.Field{
+			{Name: "dir", Label: "Directory", Type: "string", Attr: "property"},
+			{Name: "pattern", Label: "Pattern", Type: "string", Attr: "property"},
+			{Name: "recursive", Label: "Recursive", Type: "bool", Attr: "property"},
+			{Name: "volume", Type: "volume", Attr: "input"},
+			{Name: "files", Type: "list", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeList(deps.VolumeService)
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"io"
+
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
+	"github.com/infinity-oj/server-v2/internal/lib/manager"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	VS "github.com/infinity-oj/server-v2/internal/app/volumes/services"
+)
+
+// VolumePack packs a volume directory into a zip file saved in a new layer of the volume.
+type VolumePack struct {
+	vs VS.Service
+}
+
+func (r *VolumePack) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	process := pr.Process
+	v := cast.ToString(process.Inputs[0].Value)
+	if v == "" {
+		return errors.New("missing volume")
+	}
+	filename := cast.ToString(process.Properties["filename"])
+	if filename == "" {
+		filename = "archive.zip"
+	}
+
+	// the archive is streamed from the directory into the new file without being held in memory
+	reader, writer := io.Pipe()
+	defer reader.Close()
+	go func() {
+		writer.CloseWithError(r.vs.GetDirectory(v, cast.ToString(process.Properties["dir"]), writer))
+	}()
+
+	volume, err := r.vs.CreateFile(v, "/", filename, &contextReader{ctx: ctx, r: reader})
+	if err != nil {
+		return err
+	}
+
+	process.Outputs = models.Slots{
+		&models.Slot{
+			Type:  "file",
+			Value: fileReference(volume.Name, filename),
+		},
+	}
+	return nil
+}
+
+func NewVolumePack(vs VS.Service) *VolumePack {
+	return &VolumePack{vs: vs}
+}
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_pack",
+		Title:       "Pack directory",
+		Family:      "Volumes",
+		Description: "Pack a volume directory into a zip file",
+		Fields: []scene.Field{
+			{Name: "dir", Label: "Directory", Type: "string", Attr: "property"},
+			{Name: "filename", Label: "File name", Type: "string", Attr: "property"},
+			{Name: "volume", Type: "volume", Attr: "input"},
+			{Name: "archive", Type: "file", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumePack(deps.VolumeService)
+	})
+}
@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
-
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"github.com/spf13/cast"
@@ -18,11 +20,7 @@ type VolumeRead struct {
 	vs VS.Service
 }
 
-func (r *VolumeRead) IsMatched(tp string) bool {
-	return tp == "volume_read"
-}
-
-func (r *VolumeRead) Work(pr *manager.ProcessRuntime) error {
+func (r *VolumeRead) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
 	//judgement := pr.Judgement
 	fmt.Println("==============>", pr.Process.Inputs[0].Value)
 	f := cast.ToString(pr.Process.Inputs[0].Value)
@@ -42,3 +40,18 @@ func (r *VolumeRead) Work(pr *manager.ProcessRuntime) error {
 func NewVolumeRead(jr judgements.Repository, vs VS.Service) *VolumeRead {
 	return &VolumeRead{jr: jr, vs: vs}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_read",
+		Title:       "Read volume",
+		Family:      "Volumes",
+		Description: "Pass a volume file reference through",
+		Fields: []scene.Field{
+			{Name: "path", Type: "string", Attr: "input"},
+			{Name: "file", Type: "file", Attr: "output"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeRead(deps.JudgementRepository, deps.VolumeService)
+	})
+}
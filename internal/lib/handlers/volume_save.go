@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/infinity-oj/server-v2/internal/app/judgements"
-
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
+	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 	"github.com/pkg/errors"
 	"github.com/spf13/cast"
@@ -18,11 +20,11 @@ type VolumeSave struct {
 	vs VS.Service
 }
 
-func (r *VolumeSave) IsMatched(tp string) bool {
-	return tp == "volume_save"
-}
+func (r *VolumeSave) Work(ctx context.Context, pr *manager.ProcessRuntime) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-func (r *VolumeSave) Work(pr *manager.ProcessRuntime) error {
 	pr.Mutex.Lock()
 	defer pr.Mutex.Unlock()
 
@@ -51,3 +53,19 @@ func (r *VolumeSave) Work(pr *manager.ProcessRuntime) error {
 func NewVolumeSave(jr judgements.Repository, vs VS.Service) *VolumeSave {
 	return &VolumeSave{jr: jr, vs: vs}
 }
+
+func init() {
+	buildins.Register(&scene.BlockDefinition{
+		Name:        "volume_save",
+		Title:       "Save to volume",
+		Family:      "Volumes",
+		Description: "Copy a file into the working volume",
+		Fields: []scene.Field{
+			{Name: "filename", Label: "File name", Type: "string", Attr: "property"},
+			{Name: "volume", Type: "volume", Attr: "input"},
+			{Name: "file", Type: "file", Attr: "input"},
+		},
+	}, func(deps *buildins.Dependencies) manager.Handler {
+		return NewVolumeSave(deps.JudgementRepository, deps.VolumeService)
+	})
+}
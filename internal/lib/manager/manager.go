@@ -2,6 +2,7 @@ package manager
 
 import (
 	"container/list"
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -10,16 +11,44 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/google/wire"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/infinity-oj/server-v2/pkg/models"
 	"go.uber.org/zap"
 )
 
+// Result is the outcome of a process, either its outputs or the error it failed with.
+type Result struct {
+	Outputs *models.Slots
+	Error   error
+}
+
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "process_cache",
+	Name:      "requests_total",
+	Help:      "Lookups of cacheable processes in the result cache, by block type and result.",
+}, []string{"type", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheRequests)
+}
+
+// Cache stores outputs of cacheable blocks by a hash of their type, properties and inputs.
+type Cache interface {
+	// Get returns the key of the process and its cached outputs if there are any.
+	// An empty key means the process can not be cached.
+	Get(ctx context.Context, block *engine.Block, inputs models.Slots) (key string, outputs *models.Slots, ok bool)
+	Put(key string, block *engine.Block, outputs *models.Slots)
+}
+
 type ProcessRuntime struct {
 	isLocked bool
 	lockedAt time.Time
-	c        chan *models.Slots
+	c        chan *Result
+	once     sync.Once
 	block    *engine.Block
+	cacheKey string
 
 	Mutex     *sync.Mutex
 	Judgement *models.Judgement
@@ -27,7 +56,7 @@ type ProcessRuntime struct {
 }
 
 type ProcessManager interface {
-	Push(judgement *models.Judgement, block *engine.Block, inputs *models.Slots) <-chan *models.Slots
+	Push(ctx context.Context, judgement *models.Judgement, block *engine.Block, inputs *models.Slots) <-chan *Result
 	Fetch(judgementId, processId, processType string, ignoreLock bool) *ProcessRuntime
 	Finish(element *ProcessRuntime, slots *models.Slots) error
 	FinishWithError(element *ProcessRuntime, message string) error
@@ -39,7 +68,13 @@ type manager struct {
 	mutex     *sync.Mutex
 	processes *list.List
 
-	buildIns []Handler
+	buildIns Handlers
+	cache    Cache
+}
+
+// Block returns the graph block the process was created for.
+func (r *ProcessRuntime) Block() *engine.Block {
+	return r.block
 }
 
 func (m *manager) Reserve(element *ProcessRuntime) bool {
@@ -76,12 +111,16 @@ func (m *manager) List() {
 	fmt.Println("==== END ====")
 }
 
+// Handler processes a built-in block. Work is called in its own goroutine and
+// should return as soon as possible once ctx is done.
 type Handler interface {
-	IsMatched(tp string) bool
-	Work(runtime *ProcessRuntime) error
+	Work(ctx context.Context, runtime *ProcessRuntime) error
 }
 
-func (m *manager) Push(judgement *models.Judgement, block *engine.Block, inputs *models.Slots) (c <-chan *models.Slots) {
+// Handlers maps block type to the built-in handler processing it.
+type Handlers map[string]Handler
+
+func (m *manager) Push(ctx context.Context, judgement *models.Judgement, block *engine.Block, inputs *models.Slots) (c <-chan *Result) {
 	process := &models.Process{
 		Model: models.Model{
 			ID:        0,
@@ -103,7 +142,7 @@ func (m *manager) Push(judgement *models.Judgement, block *engine.Block, inputs
 	)
 	runtime := &ProcessRuntime{
 		isLocked: false,
-		c:        make(chan *models.Slots, 1),
+		c:        make(chan *Result, 1),
 		block:    block,
 
 		Mutex:     &sync.Mutex{},
@@ -117,22 +156,81 @@ func (m *manager) Push(judgement *models.Judgement, block *engine.Block, inputs
 		zap.String("process id", runtime.Process.ProcessId),
 		zap.String("process type", runtime.Process.Type),
 	)
-	for _, b := range m.buildIns {
-		if !b.IsMatched(process.Type) {
-			continue
-		}
-		if err := b.Work(runtime); err != nil {
-			m.logger.Error("consume", zap.Error(err))
-		}
-		if err := m.Finish(runtime, &runtime.Process.Outputs); err != nil {
-			m.logger.Error("finish", zap.Error(err))
-		}
+	if block.Cacheable && m.cache != nil {
+		go func() {
+			if !m.lookup(ctx, runtime) {
+				m.dispatch(ctx, runtime)
+			}
+		}()
+		return
+	}
+	m.dispatch(ctx, runtime)
+	return
+}
+
+// lookup finishes the process with cached outputs and reports whether there were any.
+func (m *manager) lookup(ctx context.Context, runtime *ProcessRuntime) bool {
+	block := runtime.block
+	key, outputs, ok := m.cache.Get(ctx, block, runtime.Process.Inputs)
+	if key == "" {
+		return false
+	}
+	if !ok {
+		cacheRequests.WithLabelValues(block.Type, "miss").Inc()
+		runtime.cacheKey = key
+		return false
+	}
+
+	cacheRequests.WithLabelValues(block.Type, "hit").Inc()
+	m.logger.Debug("process cache hit",
+		zap.String("process id", runtime.Process.ProcessId),
+		zap.String("process type", runtime.Process.Type),
+		zap.String("key", key),
+	)
+	if err := m.Finish(runtime, outputs); err != nil {
+		m.logger.Error("finish", zap.Error(err))
+	}
+	return true
+}
+
+// dispatch runs the process with its built-in handler, or queues it for actuators.
+func (m *manager) dispatch(ctx context.Context, runtime *ProcessRuntime) {
+	if b, ok := m.buildIns[runtime.block.HandlerName()]; ok {
+		go m.work(ctx, b, runtime)
 		return
 	}
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
 	m.processes.PushBack(runtime)
-	return
+	m.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		// nobody waits for the process anymore, don't hand it out to actuators
+		m.remove(runtime)
+	}()
+}
+
+func (m *manager) work(ctx context.Context, b Handler, runtime *ProcessRuntime) {
+	if err := b.Work(ctx, runtime); err != nil {
+		m.logger.Error("consume",
+			zap.String("process id", runtime.Process.ProcessId),
+			zap.String("process type", runtime.Process.Type),
+			zap.Error(err),
+		)
+		if err := m.FinishWithError(runtime, err.Error()); err != nil {
+			m.logger.Error("finish with error", zap.Error(err))
+		}
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		if err := m.FinishWithError(runtime, err.Error()); err != nil {
+			m.logger.Error("finish with error", zap.Error(err))
+		}
+		return
+	}
+	if err := m.Finish(runtime, &runtime.Process.Outputs); err != nil {
+		m.logger.Error("finish", zap.Error(err))
+	}
 }
 
 // Fetch returns process with specific process type.
@@ -180,17 +278,34 @@ func (m *manager) Finish(element *ProcessRuntime, outputs *models.Slots) error {
 		zap.String("process id", element.Process.ProcessId),
 		zap.String("process type", element.Process.Type),
 	)
-	m.remove(element)
-	element.c <- outputs
+	if err := m.finish(element, &Result{Outputs: outputs}); err != nil {
+		return err
+	}
+	if element.cacheKey != "" {
+		m.cache.Put(element.cacheKey, element.block, outputs)
+	}
 	return nil
 }
 
 func (m *manager) FinishWithError(element *ProcessRuntime, message string) error {
-	//element.runtime.Judgement.Msg = message
-	//element.runtime.Judgement.Status = models.SystemError
-	//element.runtime.Judgement.Score = 0
-	//
-	//m.FinishRuntime(element.runtime)
+	m.logger.Debug("finish process with error",
+		zap.String("process id", element.Process.ProcessId),
+		zap.String("process type", element.Process.Type),
+		zap.String("message", message),
+	)
+	return m.finish(element, &Result{Error: errors.New(message)})
+}
+
+func (m *manager) finish(element *ProcessRuntime, result *Result) error {
+	m.remove(element)
+	finished := false
+	element.once.Do(func() {
+		element.c <- result
+		finished = true
+	})
+	if !finished {
+		return errors.New("process finished before")
+	}
 	return nil
 }
 
@@ -219,14 +334,14 @@ func (m *manager) remove(element *ProcessRuntime) {
 var instance *manager
 var once sync.Once
 
-func Push(judgement *models.Judgement, block *engine.Block, inputs *models.Slots) <-chan *models.Slots {
+func Push(ctx context.Context, judgement *models.Judgement, block *engine.Block, inputs *models.Slots) <-chan *Result {
 	for ok := instance == nil; ok; ok = instance == nil {
 		<-time.After(time.Second)
 	}
-	return instance.Push(judgement, block, inputs)
+	return instance.Push(ctx, judgement, block, inputs)
 }
 
-func NewManager(logger *zap.Logger, ins []Handler) ProcessManager {
+func NewManager(logger *zap.Logger, ins Handlers, cache Cache) ProcessManager {
 	once.Do(func() {
 		instance = &manager{
 			logger:    logger,
@@ -234,6 +349,7 @@ func NewManager(logger *zap.Logger, ins []Handler) ProcessManager {
 			processes: list.New(),
 
 			buildIns: ins,
+			cache:    cache,
 		}
 	})
 	return instance
@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/infinity-oj/server-v2/internal/lib/engine"
+	"github.com/infinity-oj/server-v2/pkg/models"
+	"go.uber.org/zap"
+)
+
+type handlerFunc func(ctx context.Context, runtime *ProcessRuntime) error
+
+func (f handlerFunc) Work(ctx context.Context, runtime *ProcessRuntime) error {
+	return f(ctx, runtime)
+}
+
+func newTestManager(handlers Handlers) *manager {
+	return &manager{
+		logger:    zap.NewNop(),
+		mutex:     &sync.Mutex{},
+		processes: list.New(),
+		buildIns:  handlers,
+	}
+}
+
+func TestPush(t *testing.T) {
+	m := newTestManager(Handlers{
+		"ok": handlerFunc(func(ctx context.Context, runtime *ProcessRuntime) error {
+			runtime.Process.Outputs = models.Slots{{Type: "int", Value: 1}}
+			return nil
+		}),
+		"fail": handlerFunc(func(ctx context.Context, runtime *ProcessRuntime) error {
+			return errors.New("boom")
+		}),
+		"slow": handlerFunc(func(ctx context.Context, runtime *ProcessRuntime) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+	})
+	judgement := &models.Judgement{Name: "judgement"}
+
+	t.Run("outputs", func(t *testing.T) {
+		result := <-m.Push(context.Background(), judgement, &engine.Block{Type: "ok"}, &models.Slots{})
+		if result.Error != nil || len(*result.Outputs) != 1 {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		result := <-m.Push(context.Background(), judgement, &engine.Block{Type: "fail"}, &models.Slots{})
+		if result.Error == nil || result.Error.Error() != "boom" {
+			t.Fatalf("expected error, got %+v", result)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := m.Push(ctx, judgement, &engine.Block{Type: "slow"}, &models.Slots{})
+		cancel()
+		select {
+		case result := <-c:
+			if result.Error == nil {
+				t.Fatalf("expected error, got %+v", result)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("handler was not canceled")
+		}
+	})
+
+	t.Run("remote canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.Push(ctx, judgement, &engine.Block{Type: "remote"}, &models.Slots{})
+		if m.Fetch("*", "*", "remote", false) == nil {
+			t.Fatal("remote process is not queued")
+		}
+		cancel()
+		for i := 0; i < 100 && m.Fetch("*", "*", "remote", false) != nil; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if m.Fetch("*", "*", "remote", false) != nil {
+			t.Fatal("canceled process is still queued")
+		}
+	})
+}
+
+type memoryCache map[string]*models.Slots
+
+func (c memoryCache) Get(ctx context.Context, block *engine.Block, inputs models.Slots) (string, *models.Slots, bool) {
+	key := block.Type
+	outputs, ok := c[key]
+	return key, outputs, ok
+}
+
+func (c memoryCache) Put(key string, block *engine.Block, outputs *models.Slots) {
+	c[key] = outputs
+}
+
+func TestPushCached(t *testing.T) {
+	var calls int
+	m := newTestManager(Handlers{
+		"ok": handlerFunc(func(ctx context.Context, runtime *ProcessRuntime) error {
+			calls++
+			runtime.Process.Outputs = models.Slots{{Type: "int", Value: calls}}
+			return nil
+		}),
+	})
+	m.cache = memoryCache{}
+	judgement := &models.Judgement{Name: "judgement"}
+
+	for i := 0; i < 2; i++ {
+		result := <-m.Push(context.Background(), judgement, &engine.Block{Type: "ok", Cacheable: true}, &models.Slots{})
+		if result.Error != nil || (*result.Outputs)[0].Value != 1 {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times", calls)
+	}
+
+	result := <-m.Push(context.Background(), judgement, &engine.Block{Type: "ok"}, &models.Slots{})
+	if result.Error != nil || (*result.Outputs)[0].Value != 2 {
+		t.Fatalf("not cacheable block should not hit cache, got %+v", result)
+	}
+}
@@ -1,16 +1,19 @@
 package scheduler
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
 
+	"github.com/infinity-oj/server-v2/internal/lib/buildins"
 	"github.com/infinity-oj/server-v2/internal/lib/manager"
 
 	"github.com/infinity-oj/server-v2/internal/lib/engine/scene"
 
 	"github.com/google/wire"
+	"github.com/pkg/errors"
 
 	"github.com/infinity-oj/server-v2/internal/lib/engine"
 
@@ -32,15 +35,24 @@ type Runtime struct {
 type Scheduler struct {
 	logger *zap.Logger
 	mutex  *sync.Mutex
+	err    error
 
 	Runtime *Runtime
 
 	C chan int
 }
 
-func (s *Scheduler) Execute() {
+// Execute runs the graph until all reachable blocks are done, a process fails or ctx is done.
+// Once any process fails, processes still running are canceled.
+func (s *Scheduler) Execute(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
 	code := 0
 	defer func() {
+		if s.Err() != nil {
+			code = -1
+		}
 		s.C <- code
 	}()
 	s.logger.Debug("scheduler: execution started")
@@ -61,7 +73,8 @@ func (s *Scheduler) Execute() {
 					inputs = append(inputs, data)
 				} else {
 					s.logger.Error("wrong process definition", zap.Int("link id", linkId))
-					code = -1
+					lock.RUnlock()
+					s.fail(errors.Errorf("wrong process definition, link %d has no data", linkId))
 					return
 				}
 			}
@@ -74,16 +87,26 @@ func (s *Scheduler) Execute() {
 				s.logger.Debug("process started", zap.Int("block id", blockId), zap.Any("inputs", inputs))
 
 				select {
-				case outputs := <-manager.Push(s.Runtime.Judgement, block, &inputs):
+				case result := <-manager.Push(ctx, s.Runtime.Judgement, block, &inputs):
+					if result.Error != nil {
+						s.logger.Error("process failed", zap.Int("block id", blockId), zap.Error(result.Error))
+						s.fail(errors.Wrapf(result.Error, "block %d (%s)", blockId, block.Type))
+						cancel()
+						break
+					}
+					outputs := result.Outputs
 					s.logger.Debug("process finished normally", zap.Int("block id", blockId), zap.Any("outputs", outputs))
 
 					if len(block.Output) != len(*outputs) {
-						s.logger.Error(fmt.Sprintf("output slots mismatch, block %d expects %d but %d",
+						err := errors.Errorf("output slots mismatch, block %d expects %d but %d",
 							block.Id,
 							len(block.Output),
 							len(*outputs),
-						))
-						return
+						)
+						s.logger.Error("process failed", zap.Error(err))
+						s.fail(err)
+						cancel()
+						break
 					}
 
 					lock.Lock()
@@ -97,11 +120,9 @@ func (s *Scheduler) Execute() {
 					lock.Unlock()
 					block.Done()
 					trigger <- atomic.AddInt32(&n, 1)
-
-					//case <-time.After(time.Second * 500):
-					//	s.logger.Debug("process timeout after 500s", zap.Int("block id", blockId))
-					//	// 其实这个时候应该是把评测挂起比较好
-					// 其实不应该在这里处理超时。
+				case <-ctx.Done():
+					s.logger.Debug("process canceled", zap.Int("block id", blockId), zap.Error(ctx.Err()))
+					s.fail(ctx.Err())
 				}
 
 				s.logger.Debug("process ended", zap.Int("block id", blockId))
@@ -125,6 +146,21 @@ func (s *Scheduler) OnFinish() <-chan int {
 	return s.C
 }
 
+// Err returns the first error the execution failed with, or nil.
+func (s *Scheduler) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
+
+func (s *Scheduler) fail(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+}
+
 func New(logger *zap.Logger,
 	problem *models.Problem, submission *models.Submission, judgement *models.Judgement,
 	blueprint *models.Blueprint, programs []*models.Program,
@@ -142,13 +178,7 @@ func New(logger *zap.Logger,
 		definition = strings.ReplaceAll(definition, "${privateVolume}", problem.PrivateVolume)
 		definition = strings.ReplaceAll(definition, "${problem_id}", problem.Name)
 	}
-	s := scene.NewScene(definition)
-	//graph, err := engine.NewGraphByDefinition(definition)
-	var bs []*scene.BlockDefinition
-	for _, p := range programs {
-		bs = append(bs, scene.NewBlockDefinition(p.Definition))
-	}
-	graph, err := engine.NewGraphByScene(bs, s)
+	graph, err := Build(definition, programs)
 	if err != nil {
 		logger.Error("parse blueprint definition failed",
 			zap.Uint64("blueprint id", blueprintId),
@@ -173,4 +203,38 @@ func New(logger *zap.Logger,
 	}, nil
 }
 
-var ProviderSet = wire.NewSet(New)
+// Build parses the blueprint definition into a graph of blocks defined by programs and
+// built-in handlers, and compiles properties of blocks whose handlers support it. A built-in
+// handler takes precedence over a program of the same name.
+func Build(definition string, programs []*models.Program) (*engine.Graph, error) {
+	s := scene.NewScene(definition)
+	if s == nil {
+		return nil, errors.New("invalid blueprint definition")
+	}
+
+	var bs []*scene.BlockDefinition
+	for _, p := range programs {
+		if b := scene.NewBlockDefinition(p.Definition); b != nil && buildins.Definition(b.Name) == nil {
+			bs = append(bs, b)
+		}
+	}
+	bs = append(bs, buildins.Definitions()...)
+
+	graph, err := engine.NewGraphByScene(bs, s)
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make(map[string]*scene.BlockDefinition)
+	for _, b := range bs {
+		definitions[b.Name] = b
+	}
+	for _, block := range graph.Blocks {
+		if err := buildins.Compile(definitions[block.Type], block); err != nil {
+			return nil, errors.Wrapf(err, "block %d (%s)", block.Id, block.Type)
+		}
+	}
+	return graph, nil
+}
+
+var ProviderSet = wire.NewSet(New, NewValidator)
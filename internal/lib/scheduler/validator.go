@@ -0,0 +1,28 @@
+package scheduler
+
+import (
+	"github.com/infinity-oj/server-v2/internal/app/blueprints"
+	"github.com/infinity-oj/server-v2/internal/app/programs"
+	"github.com/pkg/errors"
+)
+
+type validator struct {
+	pgr programs.Repository
+}
+
+// Validate builds the graph of definition against the current programs,
+// so malformed blueprints and expressions are rejected before they are saved.
+func (v *validator) Validate(definition string) error {
+	ps, err := v.pgr.GetPrograms()
+	if err != nil {
+		return errors.Wrap(err, "get programs")
+	}
+	if _, err := Build(definition, ps); err != nil {
+		return errors.Wrap(blueprints.ErrInvalidDefinition, err.Error())
+	}
+	return nil
+}
+
+func NewValidator(pgr programs.Repository) blueprints.Validator {
+	return &validator{pgr: pgr}
+}
@@ -40,12 +40,24 @@ func New(o *Options) (*gorm.DB, error) {
 		db = db.Debug()
 	}
 
+	if err := migrateRankListRecords(db); err != nil {
+		return nil, errors.Wrap(err, "migrate rank list records error")
+	}
+	if err := migratePageRevisions(db); err != nil {
+		return nil, errors.Wrap(err, "migrate page revisions error")
+	}
 	db.AutoMigrate(
 		&models.Account{},
 		&models.Credential{},
 		&models.Problem{},
+		&models.ProblemCollaborator{},
+		&models.ProblemCounter{},
+		&models.ProblemSolver{},
 		&models.Page{},
+		&models.PageRevision{},
 		&models.Volume{},
+		&models.VolumeTag{},
+		&models.Blob{},
 		//&models.Role{},
 		&models.Program{},
 		&models.Blueprint{},
@@ -57,9 +69,39 @@ func New(o *Options) (*gorm.DB, error) {
 		&models.RankListRecord{},
 		&models.RankListModel{},
 		&models.RankList{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.ProcessCache{},
 	)
 
 	return db, nil
 }
 
+// migrateRankListRecords prepares rank list records for their unique index, keeping the latest
+// of the records an account has for a key.
+func migrateRankListRecords(db *gorm.DB) error {
+	migrator := db.Migrator()
+	record := &models.RankListRecord{}
+	if !migrator.HasTable(record) || migrator.HasIndex(record, "idx_rank_list_record_key") {
+		return nil
+	}
+	return db.Exec(`DELETE FROM rank_list_records a USING rank_list_records b
+		WHERE a.rank_list_id = b.rank_list_id AND a.account_id = b.account_id AND a.key = b.key AND a.id < b.id`).Error
+}
+
+// migratePageRevisions prepares page revisions for their unique index, renumbering the revisions
+// of each page in the order they were numbered and created.
+func migratePageRevisions(db *gorm.DB) error {
+	migrator := db.Migrator()
+	revision := &models.PageRevision{}
+	if !migrator.HasTable(revision) || migrator.HasIndex(revision, "idx_page_revision_key") {
+		return nil
+	}
+	return db.Exec(`UPDATE page_revisions SET revision = numbered.revision FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY problem_id, locale ORDER BY revision, id) AS revision
+			FROM page_revisions
+		) AS numbered
+		WHERE page_revisions.id = numbered.id AND page_revisions.revision <> numbered.revision`).Error
+}
+
 var ProviderSet = wire.NewSet(New, NewOptions)
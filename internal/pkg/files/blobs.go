@@ -0,0 +1,130 @@
+package files
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+)
+
+const blobDirectory = "blobs"
+
+// BlobStore is a content-addressed layer over a FileManager.
+// Each content is stored once, named by its sha256, under blobs/<first two hex digits>/.
+type BlobStore struct {
+	fm FileManager
+}
+
+// IsHash reports whether s is a hex encoded sha256.
+func IsHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// BlobPath returns the path of the blob relative to the file manager base.
+func BlobPath(hash string) (string, error) {
+	if !IsHash(hash) {
+		return "", errors.New("invalid blob hash: " + hash)
+	}
+	return path.Join(blobDirectory, hash[:2], hash), nil
+}
+
+// Put stores the content read from reader and returns its hash and size. The content is spooled
+// to a temporary file while it is hashed, so memory use does not depend on its size.
+// Content already stored is not written again. Otherwise it is written under a temporary name
+// next to the blob and renamed into place, so a blob is never seen before it is complete.
+func (s *BlobStore) Put(reader io.Reader) (string, int64, error) {
+	tmp, err := ioutil.TempFile("", "blob*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), reader)
+	if err != nil {
+		return "", 0, err
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	blobPath, _ := BlobPath(hash)
+	if _, err := s.fm.FetchFileInfo(blobPath); err == nil {
+		return hash, size, nil
+	}
+
+	dir := path.Dir(blobPath)
+	if _, err := s.fm.FetchFileInfo(dir); os.IsNotExist(err) {
+		if err := s.fm.CreateDirectory(dir); err != nil {
+			// created by a concurrent put
+			if _, statErr := s.fm.FetchFileInfo(dir); statErr != nil {
+				return "", 0, err
+			}
+		}
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	partPath, err := partPath(blobPath)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := s.fm.CreateFile(partPath, tmp); err != nil {
+		s.fm.RemoveFile(partPath)
+		return "", 0, err
+	}
+	// a concurrent put of the same content renames the same bytes into place
+	if err := s.fm.RenameFile(partPath, blobPath); err != nil {
+		s.fm.RemoveFile(partPath)
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+// partPath returns a unique temporary name next to the blob to write its content to.
+func partPath(blobPath string) (string, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(blobPath), "."+path.Base(blobPath)+"."+hex.EncodeToString(suffix)+".part"), nil
+}
+
+func (s *BlobStore) Get(hash string) (io.ReadCloser, error) {
+	blobPath, err := BlobPath(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.fm.FetchFile(blobPath)
+}
+
+func (s *BlobStore) Stat(hash string) (os.FileInfo, error) {
+	blobPath, err := BlobPath(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.fm.FetchFileInfo(blobPath)
+}
+
+// Delete removes the blob. Removing a blob which does not exist is not an error.
+func (s *BlobStore) Delete(hash string) error {
+	blobPath, err := BlobPath(hash)
+	if err != nil {
+		return err
+	}
+	if err := s.fm.RemoveFile(blobPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func NewBlobStore(fm FileManager) *BlobStore {
+	return &BlobStore{fm: fm}
+}
@@ -0,0 +1,58 @@
+package files
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestBlobStore(t *testing.T) {
+	base, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	fm := &LocalFileManager{}
+	fm.SetBase(base)
+	store := NewBlobStore(fm)
+
+	hash, size, err := store.Put(strings.NewReader("1 2\n"))
+	if err != nil || size != 4 {
+		t.Fatalf("put: got %d, %v", size, err)
+	}
+	if again, _, err := store.Put(strings.NewReader("1 2\n")); err != nil || again != hash {
+		t.Fatalf("put same content: got %s, %v, want %s", again, err, hash)
+	}
+	blobPath, _ := BlobPath(hash)
+	if files, err := ioutil.ReadDir(path.Join(base, path.Dir(blobPath))); err != nil || len(files) != 1 {
+		t.Fatalf("blob directory: got %d files, %v, want the blob only", len(files), err)
+	}
+	reader, err := store.Get(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "1 2\n" {
+		t.Fatalf("get: got %q, %v", data, err)
+	}
+	if info, err := store.Stat(hash); err != nil || info.Size() != 4 {
+		t.Fatalf("stat: got %v, %v", info, err)
+	}
+
+	if err := store.Delete(hash); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(hash); err != nil {
+		t.Fatalf("delete twice: %v", err)
+	}
+	if _, err := store.Get(hash); err == nil {
+		t.Fatal("get deleted blob: want error")
+	}
+	if _, err := store.Get("../../etc/passwd"); err == nil {
+		t.Fatal("get invalid hash: want error")
+	}
+}
@@ -2,6 +2,7 @@ package files
 
 import (
 	"errors"
+	"io"
 	"os"
 	"strings"
 
@@ -14,20 +15,26 @@ import (
 type Options struct {
 	Type string `yaml:"type"`
 	Base string `yaml:"base"`
+	// S3 configures the object storage of type s3, in which base is the key prefix.
+	S3 S3Options `yaml:"s3"`
 }
 
 type FileManager interface {
 	SetBase(base string)
 	GetBase() string
-	CreateFile(fileName string, bytes []byte) error
+	CreateFile(fileName string, reader io.Reader) error
 	CreateDirectory(fileName string) error
-	FetchFile(fileName string) ([]byte, error)
+	FetchFile(fileName string) (io.ReadCloser, error)
 	FetchFileInfo(fileName string) (os.FileInfo, error)
+	RemoveFile(fileName string) error
+	// RenameFile moves the file to newName at once, replacing the file there if any.
+	RenameFile(fileName, newName string) error
 	IsFileExists(fileName string) (bool, error)
 	IsDirectoryExists(fileName string) (bool, error)
 	GetFilesAndDirs(dirname string) ([]string, []string, error)
 
-	ArchiveDirectory(fileName string) (file *os.File, err error)
+	// ArchiveDirectory writes a zip archive of the directory to w.
+	ArchiveDirectory(fileName string, w io.Writer) error
 }
 
 func NewOptions(v *viper.Viper, logger *zap.Logger) (*Options, error) {
@@ -53,6 +60,15 @@ func New(o *Options) (FileManager, error) {
 			fm.SetBase(o.Base)
 			return fm, nil
 		}
+	case "s3":
+		{
+			fm, err := NewS3FileManager(&o.S3)
+			if err != nil {
+				return nil, err
+			}
+			fm.SetBase(o.Base)
+			return fm, nil
+		}
 	default:
 		return nil, errors.New("unknown store type: " + o.Type)
 	}
@@ -23,46 +23,33 @@ func (m *LocalFileManager) FetchFileInfo(fileName string) (os.FileInfo, error) {
 	return os.Stat(filePath)
 }
 
-func (m *LocalFileManager) FetchFile(fileName string) ([]byte, error) {
+func (m *LocalFileManager) FetchFile(fileName string) (io.ReadCloser, error) {
 	filePath, err := GetFileAbsPath(m.base, fileName)
 	if err != nil {
 		return nil, err
 	}
-	if exist, err := m.IsFileExists(filePath); err != nil {
-		return nil, err
-	} else {
-		if exist {
-
-			dat, err := ioutil.ReadFile(filePath)
-			if err != nil {
-				return nil, err
-			}
-			return dat, nil
-		} else {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
 			return nil, errors.New("file or directory does not exist")
 		}
+		return nil, err
 	}
+	return file, nil
 }
 
-func (m *LocalFileManager) ArchiveDirectory(fileName string) (file *os.File, err error) {
+func (m *LocalFileManager) ArchiveDirectory(fileName string, w io.Writer) error {
 
 	source, err := GetFileAbsPath(m.base, fileName)
 	if err != nil {
-		return nil, err
-	}
-
-	zipfile, err := ioutil.TempFile("", "*.zip")
-	if err != nil {
-		return nil, err
+		return err
 	}
-	defer zipfile.Close()
 
-	archive := zip.NewWriter(zipfile)
-	defer archive.Close()
+	archive := zip.NewWriter(w)
 
 	info, err := os.Stat(source)
 	if err != nil {
-		return nil, err
+		return err
 
 	}
 
@@ -108,8 +95,11 @@ func (m *LocalFileManager) ArchiveDirectory(fileName string) (file *os.File, err
 		_, err = io.Copy(writer, file)
 		return err
 	})
+	if err != nil {
+		return err
+	}
 
-	return zipfile, err
+	return archive.Close()
 }
 
 func (m *LocalFileManager) FetchFileBytes(fileName string) ([]byte, error) {
@@ -161,21 +151,44 @@ func GetFileAbsPath(base, fileName string) (fileAbsPath string, err error) {
 	return
 }
 
-func (m *LocalFileManager) CreateFile(fileName string, bytes []byte) (err error) {
+func (m *LocalFileManager) CreateFile(fileName string, reader io.Reader) (err error) {
 	filePath, err := GetFileAbsPath(m.base, fileName)
 	if err != nil {
 		return
 	}
-	if exist, err := m.IsFileExists(filePath); err != nil {
-		return err
-	} else {
-		if exist {
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, os.FileMode(0755))
+	if err != nil {
+		if os.IsExist(err) {
 			return errors.New("file or directory exists")
-		} else {
-			err = ioutil.WriteFile(filePath, bytes, os.FileMode(0755))
 		}
+		return err
 	}
-	return
+	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return err
+	}
+	return file.Close()
+}
+
+func (m *LocalFileManager) RemoveFile(fileName string) error {
+	filePath, err := GetFileAbsPath(m.base, fileName)
+	if err != nil {
+		return err
+	}
+	return os.Remove(filePath)
+}
+
+func (m *LocalFileManager) RenameFile(fileName, newName string) error {
+	filePath, err := GetFileAbsPath(m.base, fileName)
+	if err != nil {
+		return err
+	}
+	newPath, err := GetFileAbsPath(m.base, newName)
+	if err != nil {
+		return err
+	}
+	return os.Rename(filePath, newPath)
 }
 
 func (m *LocalFileManager) CreateDirectory(fileName string) (err error) {
@@ -0,0 +1,339 @@
+package files
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Options configures an S3 compatible object storage such as AWS S3 or MinIO.
+type S3Options struct {
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key" mapstructure:"access_key"`
+	SecretKey string `yaml:"secret_key" mapstructure:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl" mapstructure:"use_ssl"`
+}
+
+// S3FileManager stores files as objects of a bucket, keyed by their path under base.
+// Directories are implicit: a directory exists while some object has its path as prefix.
+type S3FileManager struct {
+	client *minio.Client
+	bucket string
+	base   string
+}
+
+func NewS3FileManager(o *S3Options) (*S3FileManager, error) {
+	if o.Endpoint == "" || o.Bucket == "" {
+		return nil, errors.New("s3 endpoint and bucket are required")
+	}
+	client, err := minio.New(o.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(o.AccessKey, o.SecretKey, ""),
+		Secure: o.UseSSL,
+		Region: o.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	exists, err := client.BucketExists(ctx, o.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, o.Bucket, minio.MakeBucketOptions{Region: o.Region}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3FileManager{
+		client: client,
+		bucket: o.Bucket,
+	}, nil
+}
+
+func (m *S3FileManager) SetBase(base string) {
+	m.base = strings.Trim(path.Clean("/"+base), "/")
+}
+
+func (m *S3FileManager) GetBase() string {
+	return m.base
+}
+
+// key returns the object key of fileName, which must stay under base.
+func (m *S3FileManager) key(fileName string) (string, error) {
+	key := strings.TrimPrefix(path.Join("/", m.base, fileName), "/")
+	if m.base != "" && key != m.base && !strings.HasPrefix(key, m.base+"/") {
+		return "", errors.New("escape from base path")
+	}
+	return key, nil
+}
+
+// prefix returns the key prefix of objects in the directory.
+func (m *S3FileManager) prefix(dirname string) (string, error) {
+	key, err := m.key(dirname)
+	if err != nil || key == "" {
+		return key, err
+	}
+	return key + "/", nil
+}
+
+func isNoSuchKey(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+// partSize is the part size of uploads of unknown size, which are buffered in memory part by part.
+const partSize = 16 << 20
+
+func (m *S3FileManager) CreateFile(fileName string, reader io.Reader) error {
+	key, err := m.key(fileName)
+	if err != nil {
+		return err
+	}
+	if exist, err := m.IsFileExists(fileName); err != nil {
+		return err
+	} else if exist {
+		return errors.New("file or directory exists")
+	}
+	_, err = m.client.PutObject(context.Background(), m.bucket, key, reader, readerSize(reader),
+		minio.PutObjectOptions{ContentType: "application/octet-stream", PartSize: partSize})
+	return err
+}
+
+// readerSize returns the number of bytes left in reader if it is known, or -1.
+func readerSize(reader io.Reader) int64 {
+	switch r := reader.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - offset
+	}
+	return -1
+}
+
+// CreateDirectory does nothing but validating the name, as directories only exist through their files.
+func (m *S3FileManager) CreateDirectory(fileName string) error {
+	_, err := m.key(fileName)
+	return err
+}
+
+func (m *S3FileManager) FetchFile(fileName string) (io.ReadCloser, error) {
+	key, err := m.key(fileName)
+	if err != nil {
+		return nil, err
+	}
+	object, err := m.client.GetObject(context.Background(), m.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// the request is sent lazily, stat it to report a missing object here rather than on read
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if isNoSuchKey(err) {
+			return nil, errors.New("file or directory does not exist")
+		}
+		return nil, err
+	}
+	return object, nil
+}
+
+// FetchFileInfo stats the object, or the directory if objects exist under the name.
+// The error satisfies os.IsNotExist if there is neither.
+func (m *S3FileManager) FetchFileInfo(fileName string) (os.FileInfo, error) {
+	key, err := m.key(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		info, err := m.client.StatObject(context.Background(), m.bucket, key, minio.StatObjectOptions{})
+		if err == nil {
+			return objectInfo{name: path.Base(key), size: info.Size, modTime: info.LastModified}, nil
+		}
+		if !isNoSuchKey(err) {
+			return nil, err
+		}
+	}
+
+	exist, err := m.IsDirectoryExists(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, &os.PathError{Op: "stat", Path: fileName, Err: os.ErrNotExist}
+	}
+	return objectInfo{name: path.Base("/" + key), dir: true}, nil
+}
+
+// RemoveFile removes the object. Removing an object which does not exist is not an error.
+func (m *S3FileManager) RemoveFile(fileName string) error {
+	key, err := m.key(fileName)
+	if err != nil {
+		return err
+	}
+	return m.client.RemoveObject(context.Background(), m.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// RenameFile copies the object to newName on the server, where it appears once the copy is
+// complete, and removes the object. Objects are copied in a single request, up to 5 GiB.
+func (m *S3FileManager) RenameFile(fileName, newName string) error {
+	key, err := m.key(fileName)
+	if err != nil {
+		return err
+	}
+	newKey, err := m.key(newName)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if _, err := m.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: m.bucket, Object: newKey},
+		minio.CopySrcOptions{Bucket: m.bucket, Object: key}); err != nil {
+		return err
+	}
+	return m.client.RemoveObject(ctx, m.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (m *S3FileManager) IsFileExists(fileName string) (bool, error) {
+	key, err := m.key(fileName)
+	if err != nil {
+		return false, err
+	}
+	if _, err := m.client.StatObject(context.Background(), m.bucket, key, minio.StatObjectOptions{}); err != nil {
+		if isNoSuchKey(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (m *S3FileManager) IsDirectoryExists(fileName string) (bool, error) {
+	prefix, err := m.prefix(fileName)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for object := range m.client.ListObjects(ctx, m.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true, MaxKeys: 1}) {
+		if object.Err != nil {
+			return false, object.Err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *S3FileManager) GetFilesAndDirs(dirname string) (files []string, dirs []string, err error) {
+	prefix, err := m.prefix(dirname)
+	if err != nil {
+		return nil, nil, err
+	}
+	for object := range m.client.ListObjects(context.Background(), m.bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, nil, object.Err
+		}
+		name := strings.TrimPrefix(object.Key, prefix)
+		if strings.HasSuffix(name, "/") {
+			dirs = append(dirs, strings.TrimSuffix(name, "/"))
+		} else {
+			files = append(files, name)
+		}
+	}
+	return files, dirs, nil
+}
+
+// ArchiveDirectory zips all objects under the directory into w,
+// with entries named relative to the parent of the directory like LocalFileManager does.
+func (m *S3FileManager) ArchiveDirectory(fileName string, w io.Writer) error {
+	prefix, err := m.prefix(fileName)
+	if err != nil {
+		return err
+	}
+
+	archive := zip.NewWriter(w)
+
+	baseDir := path.Base("/" + strings.TrimSuffix(prefix, "/"))
+	ctx := context.Background()
+	for object := range m.client.ListObjects(ctx, m.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		header := &zip.FileHeader{
+			Name:     path.Join(baseDir, strings.TrimPrefix(object.Key, prefix)),
+			Method:   zip.Deflate,
+			Modified: object.LastModified,
+		}
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		reader, err := m.client.GetObject(ctx, m.bucket, object.Key, minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+// objectInfo describes an object or an implicit directory.
+type objectInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (o objectInfo) Name() string {
+	return o.name
+}
+
+func (o objectInfo) Size() int64 {
+	return o.size
+}
+
+func (o objectInfo) Mode() os.FileMode {
+	if o.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (o objectInfo) ModTime() time.Time {
+	return o.modTime
+}
+
+func (o objectInfo) IsDir() bool {
+	return o.dir
+}
+
+func (o objectInfo) Sys() interface{} {
+	return nil
+}
@@ -0,0 +1,301 @@
+package files
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-process stand-in for the part of the S3 API used by S3FileManager.
+type fakeS3 struct {
+	mutex   sync.Mutex
+	buckets map[string]map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := parts[0], ""
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	objects, ok := f.buckets[bucket]
+
+	switch {
+	case key == "" && r.Method == http.MethodPut:
+		f.buckets[bucket] = map[string][]byte{}
+	case !ok:
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+	case key == "" && r.Method == http.MethodHead:
+	case key == "" && r.Method == http.MethodGet:
+		f.list(w, r, objects)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		f.copy(w, r, objects, key)
+	case r.Method == http.MethodPut:
+		data, err := readBody(r)
+		if err != nil {
+			f.error(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		objects[key] = data
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		data, ok := objects[key]
+		if !ok {
+			f.error(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("ETag", `"etag"`)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	}
+}
+
+// copy copies the object named by the copy source header within the bucket to key.
+func (f *fakeS3) copy(w http.ResponseWriter, r *http.Request, objects map[string][]byte, key string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		f.error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	if len(parts) != 2 || f.buckets[parts[0]] == nil {
+		f.error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	data, ok := f.buckets[parts[0]][strings.SplitN(parts[1], "?", 2)[0]]
+	if !ok {
+		f.error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	objects[key] = append([]byte(nil), data...)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified time.Time
+	}{ETag: `"etag"`, LastModified: time.Now().UTC()})
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request, objects map[string][]byte) {
+	type content struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		IsTruncated    bool
+		Contents       []content
+		CommonPrefixes []commonPrefix
+	}{}
+
+	prefix, delimiter := r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter")
+	seen := map[string]bool{}
+	var keys []string
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			if p := prefix + rest[:i+1]; !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: p})
+			}
+			continue
+		}
+		result.Contents = append(result.Contents, content{Key: key, Size: int64(len(objects[key])), LastModified: time.Now().UTC()})
+	}
+	xml.NewEncoder(w).Encode(result)
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+	}{Code: code})
+}
+
+// readBody reads a request body, decoding the aws-chunked encoding of signed streaming uploads.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Header.Get("X-Amz-Content-Sha256") != "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+		return ioutil.ReadAll(r.Body)
+	}
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(line), ";", 2)[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newS3FileManager(t *testing.T, server *httptest.Server) FileManager {
+	fm, err := New(&Options{
+		Type: "s3",
+		Base: "volumes",
+		S3: S3Options{
+			Endpoint:  strings.TrimPrefix(server.URL, "http://"),
+			Region:    "us-east-1",
+			Bucket:    "ioj",
+			AccessKey: "access",
+			SecretKey: "secret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fm
+}
+
+func TestS3FileManager(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{buckets: map[string]map[string][]byte{}})
+	defer server.Close()
+	fm := newS3FileManager(t, server)
+
+	if err := fm.CreateDirectory("v1/cases"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreateFile("v1/cases/1.in", strings.NewReader("1 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreateFile("v1/cases/1.ans", strings.NewReader("3\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fm.CreateFile("v1/cases/1.in", strings.NewReader("1 2\n")); err == nil {
+		t.Fatal("create existing file: want error")
+	}
+	if err := fm.CreateFile("../escape", strings.NewReader("")); err == nil {
+		t.Fatal("create file out of base: want error")
+	}
+
+	reader, err := fm.FetchFile("v1/cases/1.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "1 2\n" {
+		t.Fatalf("fetch file: got %q, %v", data, err)
+	}
+	if _, err := fm.FetchFile("v1/cases/2.in"); err == nil {
+		t.Fatal("fetch missing file: want error")
+	}
+
+	if info, err := fm.FetchFileInfo("v1/cases/1.ans"); err != nil || info.Size() != 2 || info.IsDir() {
+		t.Fatalf("file info: got %v, %v", info, err)
+	}
+	if info, err := fm.FetchFileInfo("v1/cases"); err != nil || !info.IsDir() {
+		t.Fatalf("directory info: got %v, %v", info, err)
+	}
+	if _, err := fm.FetchFileInfo("v2"); !os.IsNotExist(err) {
+		t.Fatalf("missing info: got %v, want not exist", err)
+	}
+
+	if exist, err := fm.IsFileExists("v1/cases/1.in"); err != nil || !exist {
+		t.Fatalf("file exists: got %v, %v", exist, err)
+	}
+	if exist, err := fm.IsFileExists("v1/cases/2.in"); err != nil || exist {
+		t.Fatalf("missing file exists: got %v, %v", exist, err)
+	}
+
+	files, dirs, err := fm.GetFilesAndDirs("v1")
+	if err != nil || len(files) != 0 || len(dirs) != 1 || dirs[0] != "cases" {
+		t.Fatalf("files and dirs: got %v, %v, %v", files, dirs, err)
+	}
+
+	var archive bytes.Buffer
+	if err := fm.ArchiveDirectory("v1", &archive); err != nil {
+		t.Fatal(err)
+	}
+	zipReader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zipReader.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "v1/cases/1.ans,v1/cases/1.in" {
+		t.Fatalf("archive entries: got %v", names)
+	}
+
+	if err := fm.RemoveFile("v1/cases/1.in"); err != nil {
+		t.Fatal(err)
+	}
+	if exist, _ := fm.IsFileExists("v1/cases/1.in"); exist {
+		t.Fatal("removed file exists")
+	}
+}
+
+func TestS3BlobStore(t *testing.T) {
+	fake := &fakeS3{buckets: map[string]map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	store := NewBlobStore(newS3FileManager(t, server))
+
+	hash, size, err := store.Put(strings.NewReader("1 2\n"))
+	if err != nil || size != 4 {
+		t.Fatalf("put: got %d, %v", size, err)
+	}
+	blobPath, _ := BlobPath(hash)
+	var keys []string
+	for key := range fake.buckets["ioj"] {
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != "volumes/"+blobPath {
+		t.Fatalf("objects: got %v, want the blob only", keys)
+	}
+
+	reader, err := store.Get(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "1 2\n" {
+		t.Fatalf("get: got %q, %v", data, err)
+	}
+}
@@ -59,3 +59,15 @@ func New() *Session {
 		ExpTime: time.Now().Add(12 * time.Hour),
 	}
 }
+
+// HasRole reports whether the session holds any of the roles.
+func (sd *Session) HasRole(roles ...string) bool {
+	for _, role := range sd.Roles {
+		for _, r := range roles {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+package models
+
+// Blob is a file content stored once by its sha256 and shared by file records of all volumes.
+// RefCount is the number of file records referencing it, a blob with no references may be collected.
+type Blob struct {
+	Model
+
+	Hash     string `json:"hash" gorm:"uniqueIndex;size:64"`
+	Size     int64  `json:"size"`
+	RefCount int64  `json:"ref_count" gorm:"index"`
+}
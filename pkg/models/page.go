@@ -7,6 +7,11 @@ type Page struct {
 
 	Locale string `json:"locale"`
 
+	PageContent
+}
+
+// PageContent is what a problem page shows, kept by every revision of the page.
+type PageContent struct {
 	Title       string `json:"title" gorm:"not null"` // title
 	Description string `json:"description"`
 
@@ -15,3 +20,18 @@ type Page struct {
 	Example      string `json:"example"`
 	HintAndLimit string `json:"hint_and_limit"`
 }
+
+// PageRevision is a saved version of the page of a problem in a locale. Revisions outlive the
+// page, so the history of a deleted page is kept.
+type PageRevision struct {
+	Model
+
+	ProblemId uint64 `json:"problemId" gorm:"uniqueIndex:idx_page_revision_key"`
+	Locale    string `json:"locale" gorm:"uniqueIndex:idx_page_revision_key"`
+	Revision  int    `json:"revision" gorm:"uniqueIndex:idx_page_revision_key"`
+	CreatedBy uint64 `json:"created_by"`
+	// Deleted marks the revision recording the deletion of the page, its content is empty.
+	Deleted bool `json:"deleted"`
+
+	PageContent
+}
@@ -1,5 +1,23 @@
 package models
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// States of a problem. Only published problems are listed and take submissions, archived ones
+// can still be read.
+const (
+	ProblemDraft     = "draft"
+	ProblemReview    = "review"
+	ProblemPublished = "published"
+	ProblemArchived  = "archived"
+)
+
 type Problem struct {
 	Model
 
@@ -7,8 +25,62 @@ type Problem struct {
 	Title       string `json:"title"`
 	BlueprintID uint64
 
+	Tags Tags `json:"tags" gorm:"type:json;not null;default:'[]'"`
+	// Difficulty rates the problem, zero if unrated.
+	Difficulty int    `json:"difficulty"`
+	Source     string `json:"source"`
+
+	// problems created before states existed are published
+	State string `json:"state" gorm:"not null;default:published"`
+	// PublishAt schedules a published problem, which stays hidden until then.
+	PublishAt *time.Time `json:"publish_at"`
+	OwnerID   uint64     `json:"owner_id"`
+
 	PublicVolume  string `json:"public_volume"`
 	PrivateVolume string `json:"-"`
 
 	RankLists []RankList `json:"rank_lists"`
 }
+
+// Tags classify a problem, in lower case and sorted.
+type Tags []string
+
+func (tags *Tags) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*tags = Tags{}
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), tags)
+	case []byte:
+		return json.Unmarshal(v, tags)
+	default:
+		return errors.New(fmt.Sprint("Failed to unmarshal json value:", value))
+	}
+}
+
+func (tags Tags) Value() (driver.Value, error) {
+	if tags == nil {
+		tags = Tags{}
+	}
+	jsonBytes, err := json.Marshal(tags)
+	return string(jsonBytes), err
+}
+
+// IsPublished reports whether the problem is published at the time.
+func (p *Problem) IsPublished(now time.Time) bool {
+	return p.State == ProblemPublished && (p.PublishAt == nil || !p.PublishAt.After(now))
+}
+
+// IsReadable reports whether everyone may read the problem at the time.
+func (p *Problem) IsReadable(now time.Time) bool {
+	return p.IsPublished(now) || p.State == ProblemArchived
+}
+
+// ProblemCollaborator grants an account editing a problem besides its owner.
+type ProblemCollaborator struct {
+	Model
+
+	ProblemID uint64 `json:"problem_id" gorm:"uniqueIndex:idx_problem_collaborator"`
+	AccountID uint64 `json:"account_id" gorm:"uniqueIndex:idx_problem_collaborator"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// ProblemCounter is one statistic of a problem, such as the submissions with a verdict or made
+// on a day, counted up as judgements finish.
+type ProblemCounter struct {
+	Model
+
+	ProblemID uint64 `json:"problem_id" gorm:"uniqueIndex:idx_problem_counter"`
+	Kind      string `json:"kind" gorm:"uniqueIndex:idx_problem_counter"`
+	Name      string `json:"name" gorm:"uniqueIndex:idx_problem_counter"`
+	Count     int64  `json:"count"`
+}
+
+// ProblemSolver records that an account solved a problem, so each solver is counted once.
+type ProblemSolver struct {
+	Model
+
+	ProblemID uint64 `json:"problem_id" gorm:"uniqueIndex:idx_problem_solver"`
+	AccountID uint64 `json:"account_id" gorm:"uniqueIndex:idx_problem_solver"`
+}
@@ -0,0 +1,10 @@
+package models
+
+// ProcessCache holds outputs of a cacheable process, keyed by hash of its type, properties and inputs.
+type ProcessCache struct {
+	Model
+
+	Key     string `json:"key" gorm:"uniqueIndex"`
+	Type    string `json:"type" gorm:"index"`
+	Outputs Slots  `json:"outputs" gorm:"type:json"`
+}
@@ -22,11 +22,15 @@ type RankListModel struct {
 
 type RankListRecord struct {
 	Model
-	RankListID uint64 `json:"-"`
+	RankListID uint64 `json:"-" gorm:"uniqueIndex:idx_rank_list_record_key"`
 
-	AccountID uint64  `json:"-"`
+	AccountID uint64  `json:"-" gorm:"uniqueIndex:idx_rank_list_record_key"`
 	Account   Account `json:"account"`
 
-	Key   string  `json:"key"`
+	Key   string  `json:"key" gorm:"uniqueIndex:idx_rank_list_record_key"`
 	Value float64 `json:"value"`
+
+	SubmissionID uint64 `json:"submission_id"`
+	JudgementID  uint64 `json:"judgement_id"`
+	Accepted     bool   `json:"accepted"`
 }
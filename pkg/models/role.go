@@ -6,3 +6,13 @@ type Role struct {
 	AccountId uint64 `json:"submitterId"`
 	Name      string `json:"name"`
 }
+
+// Role names granted to accounts.
+const (
+	// RoleAdmin administers the whole system.
+	RoleAdmin = "admin"
+	// RoleSetter prepares problems, setters and admins are staff.
+	RoleSetter = "setter"
+	// RoleJudge is held by accounts of the judging pipeline.
+	RoleJudge = "judge"
+)
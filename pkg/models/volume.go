@@ -20,6 +20,10 @@ type FileRecord struct {
 
 	VolumeName string `json:"volume"`
 	VolumePath string `json:"volumePath"`
+
+	// Hash references the content blob, records created before blobs use VolumeName and VolumePath.
+	Hash     string `json:"hash,omitempty"`
+	FileSize int64  `json:"size,omitempty"`
 }
 
 type Volume struct {
@@ -29,6 +33,10 @@ type Volume struct {
 	CreatedBy   uint64      `json:"created_by"`
 	Name        string      `json:"name"`
 	FileRecords FileRecords `json:"file_records" gorm:"type:json"`
+
+	// SquashedFrom is the bottom layer of the volume before it was squashed, which it still
+	// counts as, or zero if the volume was never squashed.
+	SquashedFrom uint64 `json:"squashed_from,omitempty" gorm:"not null;default:0"`
 }
 
 func (fileRecords *FileRecords) Scan(value interface{}) error {
@@ -50,8 +58,21 @@ func (f FileRecord) Name() string {
 	return strings.TrimPrefix(f.FilePath, string(filepath.Separator))
 }
 
+// ContentHash returns the sha256 of the file content, which is the blob hash or the suffix of
+// the stored file name for records created before blobs, or empty if it is unknown.
+func (f FileRecord) ContentHash() string {
+	if f.Hash != "" {
+		return f.Hash
+	}
+	const hashLength = 64
+	if p := f.VolumePath; len(p) >= hashLength {
+		return p[len(p)-hashLength:]
+	}
+	return ""
+}
+
 func (f FileRecord) Size() int64 {
-	return 0
+	return f.FileSize
 }
 
 func (f FileRecord) Mode() os.FileMode {
@@ -69,3 +90,13 @@ func (f FileRecord) IsDir() bool {
 func (f FileRecord) Sys() interface{} {
 	return nil
 }
+
+// VolumeTag names a volume layer, so the content at that point can be referenced by the tag
+// wherever a volume name is expected. Moving a tag points it at another layer.
+type VolumeTag struct {
+	Model
+
+	Name      string `json:"name" gorm:"uniqueIndex"`
+	VolumeID  uint64 `json:"volume_id" gorm:"index"`
+	CreatedBy uint64 `json:"created_by"`
+}
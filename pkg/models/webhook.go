@@ -0,0 +1,26 @@
+package models
+
+// Webhook is a subscription of an external receiver to judgement outcomes of a problem.
+type Webhook struct {
+	Model
+
+	ProblemID uint64 `json:"problem_id" gorm:"index"`
+	URL       string `json:"url"`
+	Secret    string `json:"-"`
+	Active    bool   `json:"active"`
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	Model
+
+	WebhookID   uint64 `json:"webhook_id" gorm:"index"`
+	JudgementID string `json:"judgement_id"`
+	Event       string `json:"event"`
+	Payload     string `json:"payload"`
+
+	Attempt    int    `json:"attempt"`
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	Delivered  bool   `json:"delivered"`
+}
@@ -0,0 +1,9 @@
+//go:build tools
+// +build tools
+
+// Package tools pins the code generators run by the Makefile, so their requirements are kept.
+package tools
+
+import (
+	_ "github.com/google/wire/cmd/wire"
+)